@@ -2,8 +2,12 @@ package cmd
 
 import (
 	"autocert/internal/backup"
+	"autocert/internal/cert"
+	"autocert/internal/config"
+	"autocert/internal/deploy"
 	"autocert/internal/logger"
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -25,9 +29,13 @@ var importCmd = &cobra.Command{
 	Short: "导入证书和配置",
 	Long: `从压缩包导入证书、私钥和相关配置。
 
+也可以从 HashiCorp Vault 读取证书，使用该证书 deploy 配置中的 vault 目标。
+
 示例:
   autocert import certs.tar.gz
-  autocert import certs.zip --restore-schedule`,
+  autocert import certs.zip --restore-schedule
+  autocert import --from-vault --cert-name example.com
+  autocert import --from-vault --cert-name example.com --vault-path tls/example.com`,
 	RunE: runImport,
 }
 
@@ -36,6 +44,9 @@ var (
 	exportFormat    string
 	exportDomain    string
 	restoreSchedule bool
+	fromVault       bool
+	importCertName  string
+	vaultPath       string
 )
 
 func init() {
@@ -49,6 +60,9 @@ func init() {
 
 	// import 命令参数
 	importCmd.Flags().BoolVar(&restoreSchedule, "restore-schedule", true, "是否恢复定时任务")
+	importCmd.Flags().BoolVar(&fromVault, "from-vault", false, "从 Vault 读取证书")
+	importCmd.Flags().StringVar(&importCertName, "cert-name", "", "从 Vault 导入时的证书名称（证书目录名）")
+	importCmd.Flags().StringVar(&vaultPath, "vault-path", "", "Vault 中的密钥路径（默认使用 deploy 配置）")
 }
 
 func runExport(cmd *cobra.Command, args []string) error {
@@ -77,6 +91,10 @@ func runExport(cmd *cobra.Command, args []string) error {
 }
 
 func runImport(cmd *cobra.Command, args []string) error {
+	if fromVault {
+		return importFromVault()
+	}
+
 	if len(args) == 0 {
 		return fmt.Errorf("请指定要导入的文件")
 	}
@@ -104,3 +122,44 @@ func runImport(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// importFromVault 从 Vault 读取证书并写入证书目录
+func importFromVault() error {
+	if importCertName == "" {
+		return fmt.Errorf("从 Vault 导入时必须指定 --cert-name")
+	}
+	lineage, err := cert.NewLineage(importCertName)
+	if err != nil {
+		return err
+	}
+
+	var vaultCfg *config.VaultConfig
+	for _, target := range config.GetCertificateConfig(importCertName).Deploy {
+		if strings.EqualFold(target.Type, "vault") {
+			vaultCfg = &target.Vault
+			break
+		}
+	}
+	if vaultCfg == nil {
+		// 未配置 vault 目标时使用环境变量中的地址和 Token
+		vaultCfg = &config.VaultConfig{}
+	}
+	if vaultPath != "" {
+		vaultCfg.Path = vaultPath
+	}
+
+	logger.Info("开始从 Vault 导入证书", "certName", importCertName, "path", vaultCfg.Path)
+
+	fullchain, key, err := deploy.FetchFromVault(*vaultCfg)
+	if err != nil {
+		return fmt.Errorf("从 Vault 读取证书失败: %w", err)
+	}
+
+	if err := lineage.Write(fullchain, key); err != nil {
+		return fmt.Errorf("写入证书失败: %w", err)
+	}
+
+	logger.Info("从 Vault 导入完成", "certName", importCertName, "dir", lineage.Dir)
+	fmt.Printf("✓ 证书 %s 已从 Vault 导入到 %s\n", importCertName, lineage.Dir)
+	return nil
+}
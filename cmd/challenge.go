@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"autocert/internal/cert"
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var challengeCmd = &cobra.Command{
+	Use:   "challenge",
+	Short: "安装共享的 ACME 挑战配置",
+	Long: `为 Web 服务器安装共享的 ACME 挑战配置，从 AutoCert 的挑战目录（acme.webroot）
+提供 /.well-known/acme-challenge/，使已有站点都可以通过 webroot 方式验证。
+
+Nginx 写入 snippets/autocert-acme.conf，指定 --include 时在所有 HTTP server 块中引用，
+server 块中的 return 会移到 location / 中，使挑战路径不被重定向。
+Apache 写入全局的 Alias 配置（autocert-acme.conf），对所有 VirtualHost 生效。
+
+使用 webroot 方式申请证书时会自动安装该配置，此命令用于提前安装或在已有站点中引用。
+
+示例:
+  autocert challenge --nginx
+  autocert challenge --nginx --include
+  autocert challenge --nginx --include --plan
+  autocert challenge --apache`,
+	RunE: runChallenge,
+}
+
+var (
+	challengeNginx   bool
+	challengeApache  bool
+	challengeInclude bool
+	challengePlan    bool
+)
+
+func init() {
+	rootCmd.AddCommand(challengeCmd)
+
+	challengeCmd.Flags().BoolVar(&challengeNginx, "nginx", false, "配置 Nginx")
+	challengeCmd.Flags().BoolVar(&challengeApache, "apache", false, "配置 Apache")
+	challengeCmd.Flags().BoolVar(&challengeInclude, "include", false, "在还没有引用挑战配置的 HTTP server 块中添加 include（Nginx）")
+	challengeCmd.Flags().BoolVar(&challengePlan, "plan", false, "只输出将修改的文件（unified diff）和命令，不写入文件")
+}
+
+func runChallenge(cmd *cobra.Command, args []string) error {
+	var webServerType cert.WebServerType
+	switch {
+	case challengeNginx && challengeApache:
+		return fmt.Errorf("--nginx 和 --apache 只能指定一个")
+	case challengeNginx:
+		webServerType = cert.WebServerNginx
+	case challengeApache:
+		webServerType = cert.WebServerApache
+	default:
+		return fmt.Errorf("必须指定 Web 服务器类型: --nginx 或 --apache")
+	}
+
+	logger.Info("安装 ACME 挑战配置", "webServer", webServerType, "dir", config.GetChallengeDir())
+
+	plan, err := cert.ConfigureChallenge(webServerType, challengeInclude, challengePlan)
+	if err != nil {
+		return fmt.Errorf("安装 ACME 挑战配置失败: %w", err)
+	}
+	if challengePlan {
+		fmt.Print(plan.Diff())
+		return nil
+	}
+
+	fmt.Printf("✓ %s 已从 %s 提供 /.well-known/acme-challenge/\n", webServerType, config.GetChallengeDir())
+	return nil
+}
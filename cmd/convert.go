@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"autocert/internal/cert"
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "将已有证书转换为其他格式",
+	Long: `将已有证书转换为 PKCS#12、DER、JKS 或组合 PEM 格式。
+
+未指定 --format 时，按配置文件中该证书的 formats 配置生成所有格式。
+
+支持的格式:
+  pkcs12    PKCS#12 / PFX（私钥 + 完整证书链），Windows/IIS 使用
+  der       DER 编码的叶子证书
+  jks       Java KeyStore（需要密码）
+  combined  私钥 + 完整证书链的 PEM，HAProxy 使用
+
+示例:
+  autocert convert --cert-name example.com --format pkcs12 --password secret
+  autocert convert --cert-name example.com --format jks --password changeit --output /opt/app/keystore.jks
+  autocert convert --cert-name example.com_san`,
+	RunE: runConvert,
+}
+
+var (
+	convertCertName string
+	convertFormat   string
+	convertPassword string
+	convertOutput   string
+	convertAlias    string
+)
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+
+	convertCmd.Flags().StringVar(&convertCertName, "cert-name", "", "证书名称（证书目录名）")
+	convertCmd.Flags().StringVar(&convertFormat, "format", "", "输出格式 (pkcs12, der, jks, combined)")
+	convertCmd.Flags().StringVar(&convertPassword, "password", "", "PKCS#12 / JKS 密码")
+	convertCmd.Flags().StringVarP(&convertOutput, "output", "o", "", "输出文件路径（默认写入证书目录）")
+	convertCmd.Flags().StringVar(&convertAlias, "alias", "", "JKS 条目别名（默认为证书名称）")
+
+	convertCmd.MarkFlagRequired("cert-name")
+}
+
+func runConvert(cmd *cobra.Command, args []string) error {
+	logger.Info("开始转换证书格式", "certName", convertCertName, "format", convertFormat)
+
+	lineage, err := cert.OpenLineage(convertCertName)
+	if err != nil {
+		return err
+	}
+
+	// 未指定格式时使用配置文件中的格式
+	if convertFormat == "" {
+		formats := config.GetCertificateConfig(convertCertName).Formats
+		if len(formats) == 0 {
+			return fmt.Errorf("证书 %s 未配置输出格式，请使用 --format 指定", convertCertName)
+		}
+		if err := lineage.ConvertAll(formats); err != nil {
+			return fmt.Errorf("证书格式转换失败: %w", err)
+		}
+		fmt.Printf("✓ 证书 %s 已按配置生成 %d 种格式\n", convertCertName, len(formats))
+		return nil
+	}
+
+	outPath, err := lineage.Convert(config.OutputFormatConfig{
+		Type:     convertFormat,
+		Path:     convertOutput,
+		Password: convertPassword,
+		Alias:    convertAlias,
+	})
+	if err != nil {
+		return fmt.Errorf("证书格式转换失败: %w", err)
+	}
+
+	fmt.Printf("✓ 证书已转换: %s\n", outPath)
+	return nil
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"autocert/internal/cert"
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var deployCmd = &cobra.Command{
+	Use:   "deploy",
+	Short: "将证书部署到配置的目标位置",
+	Long: `按配置文件中该证书的 deploy 配置，将证书文件复制到应用自己的目录，
+设置所有者和权限，并执行可选的重载命令。
+
+每次签发或续期后会自动执行部署，此命令用于手动重新部署。
+
+示例:
+  autocert deploy --cert-name example.com`,
+	RunE: runDeploy,
+}
+
+var deployCertName string
+
+func init() {
+	rootCmd.AddCommand(deployCmd)
+
+	deployCmd.Flags().StringVar(&deployCertName, "cert-name", "", "证书名称（证书目录名）")
+	deployCmd.MarkFlagRequired("cert-name")
+}
+
+func runDeploy(cmd *cobra.Command, args []string) error {
+	logger.Info("开始部署证书", "certName", deployCertName)
+
+	lineage, err := cert.OpenLineage(deployCertName)
+	if err != nil {
+		return err
+	}
+
+	targets := config.GetCertificateConfig(deployCertName).Deploy
+	if len(targets) == 0 {
+		return fmt.Errorf("证书 %s 未配置部署目标", deployCertName)
+	}
+
+	if err := lineage.Deploy(targets); err != nil {
+		return fmt.Errorf("部署证书失败: %w", err)
+	}
+
+	fmt.Printf("✓ 证书 %s 已部署到 %d 个目标\n", deployCertName, len(targets))
+	return nil
+}
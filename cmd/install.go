@@ -28,7 +28,22 @@ var installCmd = &cobra.Command{
   autocert install --domain sub.example.com --email admin@example.com --nginx
   
   # 混合域名（主域名+泛域名）
-  autocert install --domains "example.com,*.example.com" --email admin@example.com --nginx --dns`,
+  autocert install --domains "example.com,*.example.com" --email admin@example.com --nginx --dns
+
+  # 只查看将修改的配置，不申请证书
+  autocert install --domain example.com --email admin@example.com --nginx --plan
+
+  # 反向代理到本地服务
+  autocert install --domain app.example.com --email admin@example.com --nginx --template proxy --upstream http://127.0.0.1:3000
+
+  # 在 HAProxy 中终止 TLS
+  autocert install --domain example.com --email admin@example.com --haproxy
+
+  # 从已有的 Nginx 站点配置中发现域名
+  autocert install --from-nginx --site /etc/nginx/sites-enabled/example.com --email admin@example.com
+
+  # 邮件服务器证书（standalone 验证），同时配置 Postfix 和 Dovecot
+  autocert install --domain mail.example.com --email admin@example.com --standalone --postfix --dovecot`,
 	RunE: runInstall,
 }
 
@@ -42,6 +57,20 @@ var (
 	nginx        bool
 	apache       bool
 	iis          bool
+	haproxy      bool
+	traefik      bool
+	lighttpd     bool
+	postfix      bool   // 同时配置 Postfix
+	dovecot      bool   // 同时配置 Dovecot
+	redirect     bool   // 是否将 HTTP 重定向到 HTTPS
+	siteTemplate string // 站点模板
+	upstream     string // 站点模板使用的上游地址
+	tlsProfile   string // TLS 安全配置
+	hsts         bool   // 启用 HSTS
+	planOnly     bool   // 只输出将执行的修改
+	fromNginx    bool   // 从 Nginx 配置中发现域名
+	fromApache   bool   // 从 Apache 配置中发现域名
+	siteFile     string // 只从该配置文件中发现域名
 )
 
 func init() {
@@ -50,6 +79,9 @@ func init() {
 	// 域名参数
 	installCmd.Flags().StringVarP(&domain, "domain", "d", "", "要申请证书的单个域名")
 	installCmd.Flags().StringVar(&domains, "domains", "", "多个域名，用逗号分隔 (例: example.com,www.example.com,*.example.com)")
+	installCmd.Flags().BoolVar(&fromNginx, "from-nginx", false, "从 Nginx 配置的 server_name 中发现域名（同时配置 Nginx）")
+	installCmd.Flags().BoolVar(&fromApache, "from-apache", false, "从 Apache 配置的 ServerName/ServerAlias 中发现域名（同时配置 Apache）")
+	installCmd.Flags().StringVar(&siteFile, "site", "", "与 --from-nginx/--from-apache 一起使用，只读取该站点配置文件")
 	installCmd.Flags().StringVarP(&email, "email", "e", "", "用于 Let's Encrypt 账户的邮箱地址 (必需)")
 
 	// 验证模式
@@ -61,12 +93,30 @@ func init() {
 	installCmd.Flags().BoolVar(&nginx, "nginx", false, "配置 Nginx")
 	installCmd.Flags().BoolVar(&apache, "apache", false, "配置 Apache")
 	installCmd.Flags().BoolVar(&iis, "iis", false, "配置 IIS")
+	installCmd.Flags().BoolVar(&haproxy, "haproxy", false, "配置 HAProxy")
+	installCmd.Flags().BoolVar(&traefik, "traefik", false, "配置 Traefik（文件提供者）")
+	installCmd.Flags().BoolVar(&lighttpd, "lighttpd", false, "配置 lighttpd")
+	installCmd.Flags().BoolVar(&postfix, "postfix", false, "同时为 Postfix 配置该证书")
+	installCmd.Flags().BoolVar(&dovecot, "dovecot", false, "同时为 Dovecot 配置该证书")
+	installCmd.Flags().BoolVar(&redirect, "redirect", true, "将 HTTP 重定向到 HTTPS")
+	installCmd.Flags().StringVar(&siteTemplate, "template", "", "站点模板 (static, php, proxy, redirect) 或自定义模板文件路径")
+	installCmd.Flags().StringVar(&upstream, "upstream", "", "上游地址：proxy 为代理地址，php 为 PHP-FPM 地址，redirect 为跳转目标")
+	installCmd.Flags().StringVar(&tlsProfile, "tls-profile", "", "TLS 安全配置 (modern, intermediate, old)，默认 intermediate")
+	installCmd.Flags().BoolVar(&hsts, "hsts", false, "启用 HSTS (Strict-Transport-Security)")
+	installCmd.Flags().BoolVar(&planOnly, "plan", false, "只输出将修改的文件（unified diff）、符号链接和命令，不申请证书也不写入文件")
 
 	// 标记必需参数
 	installCmd.MarkFlagRequired("email")
 }
 
 func runInstall(cmd *cobra.Command, args []string) error {
+	if fromNginx || fromApache {
+		return installFromConfig()
+	}
+	if siteFile != "" {
+		return fmt.Errorf("--site 需要与 --from-nginx 或 --from-apache 一起使用")
+	}
+
 	// 解析域名列表
 	domainList, err := parseDomains()
 	if err != nil {
@@ -112,6 +162,34 @@ func installCertificate(domainList []string) error {
 		certManager.SetWebServer(cert.WebServerApache)
 	} else if iis {
 		certManager.SetWebServer(cert.WebServerIIS)
+	} else if haproxy {
+		certManager.SetWebServer(cert.WebServerHAProxy)
+	} else if traefik {
+		certManager.SetWebServer(cert.WebServerTraefik)
+	} else if lighttpd {
+		certManager.SetWebServer(cert.WebServerLighttpd)
+	}
+
+	var services []string
+	if postfix {
+		services = append(services, cert.ServicePostfix)
+	}
+	if dovecot {
+		services = append(services, cert.ServiceDovecot)
+	}
+	certManager.SetServices(services)
+
+	certManager.SetRedirect(redirect)
+	certManager.SetTemplate(siteTemplate, upstream)
+	certManager.SetSecurity(tlsProfile, hsts)
+
+	if planOnly {
+		plan, err := certManager.Plan()
+		if err != nil {
+			return fmt.Errorf("生成配置计划失败: %w", err)
+		}
+		fmt.Print(plan.Diff())
+		return nil
 	}
 
 	// 申请并安装证书
@@ -129,6 +207,63 @@ func installCertificate(domainList []string) error {
 	return nil
 }
 
+// installFromConfig 从已有的 Web 服务器配置中发现域名
+// 只发现一个站点时直接申请，发现多个站点时列出每个站点对应的安装命令
+func installFromConfig() error {
+	if fromNginx && fromApache {
+		return fmt.Errorf("--from-nginx 和 --from-apache 只能指定一个")
+	}
+	if domain != "" || domains != "" {
+		return fmt.Errorf("--from-nginx/--from-apache 不能与 --domain 或 --domains 同时使用")
+	}
+
+	webServerType, flag := cert.WebServerNginx, "--nginx"
+	if fromApache {
+		webServerType, flag = cert.WebServerApache, "--apache"
+	}
+	nginx, apache = fromNginx, fromApache
+
+	sites, err := cert.DiscoverSites(webServerType, siteFile)
+	if err != nil {
+		return fmt.Errorf("发现域名失败: %w", err)
+	}
+	if len(sites) == 0 {
+		return fmt.Errorf("%s 配置中没有可以申请证书的域名", webServerType)
+	}
+
+	fmt.Printf("从 %s 配置中发现 %d 个站点:\n", webServerType, len(sites))
+	for i, site := range sites {
+		fmt.Printf("  %d. %s\n", i+1, strings.Join(site.Domains, ", "))
+		fmt.Printf("     来源: %s\n", strings.Join(site.Sources, ", "))
+		if len(site.Skipped) > 0 {
+			fmt.Printf("     跳过: %s\n", strings.Join(site.Skipped, ", "))
+		}
+	}
+	fmt.Println()
+
+	if len(sites) > 1 {
+		fmt.Println("每个站点申请一张证书，使用 --site 指定站点配置文件，或分别执行:")
+		for _, site := range sites {
+			command := fmt.Sprintf("autocert install --domains %s --email %s %s", strings.Join(site.Domains, ","), email, flag)
+			for _, d := range site.Domains {
+				if strings.HasPrefix(d, "*.") {
+					command += " --dns"
+					break
+				}
+			}
+			fmt.Printf("  %s\n", command)
+		}
+		return nil
+	}
+
+	domainList := sites[0].Domains
+	logger.Info("开始安装证书", "domains", domainList, "email", email, "source", strings.Join(sites[0].Sources, ", "))
+	if err := validateInstallFlags(domainList); err != nil {
+		return fmt.Errorf("参数验证失败: %w", err)
+	}
+	return installCertificate(domainList)
+}
+
 // parseDomains 解析域名列表
 func parseDomains() ([]string, error) {
 	var domainList []string
@@ -187,9 +322,9 @@ func validateDomainName(domain string) error {
 }
 
 func validateInstallFlags(domainList []string) error {
-	// 验证至少指定了一种 Web 服务器
-	if !nginx && !apache && !iis {
-		return fmt.Errorf("必须指定至少一种 Web 服务器类型: --nginx, --apache, 或 --iis")
+	// 验证至少指定了一种 Web 服务器，只配置邮件服务器时可以不指定
+	if !nginx && !apache && !iis && !haproxy && !traefik && !lighttpd && !postfix && !dovecot {
+		return fmt.Errorf("必须指定至少一种 Web 服务器类型: --nginx, --apache, --haproxy, --traefik, --lighttpd 或 --iis（只用于邮件服务器时指定 --postfix 或 --dovecot）")
 	}
 
 	// 验证只指定了一种 Web 服务器
@@ -203,6 +338,15 @@ func validateInstallFlags(domainList []string) error {
 	if iis {
 		count++
 	}
+	if haproxy {
+		count++
+	}
+	if traefik {
+		count++
+	}
+	if lighttpd {
+		count++
+	}
 	if count > 1 {
 		return fmt.Errorf("只能指定一种 Web 服务器类型")
 	}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"autocert/internal/cert"
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var exportK8sCmd = &cobra.Command{
+	Use:   "export-k8s",
+	Short: "生成 Kubernetes TLS Secret 清单",
+	Long: `根据已有证书生成 kubernetes.io/tls 类型的 Secret 清单。
+
+命令行参数会覆盖配置文件中该证书的 kubernetes 配置；未指定 --output 时输出到标准输出。
+
+示例:
+  autocert export-k8s --cert-name example.com
+  autocert export-k8s --cert-name example.com --namespace ingress --name example-tls --label team=web
+  autocert export-k8s --cert-name example.com --output ./gitops/secrets/example-tls.yaml`,
+	RunE: runExportK8s,
+}
+
+var (
+	k8sCertName  string
+	k8sNamespace string
+	k8sName      string
+	k8sLabels    []string
+	k8sOutput    string
+)
+
+func init() {
+	rootCmd.AddCommand(exportK8sCmd)
+
+	exportK8sCmd.Flags().StringVar(&k8sCertName, "cert-name", "", "证书名称（证书目录名）")
+	exportK8sCmd.Flags().StringVarP(&k8sNamespace, "namespace", "n", "", "Secret 命名空间")
+	exportK8sCmd.Flags().StringVar(&k8sName, "name", "", "Secret 名称")
+	exportK8sCmd.Flags().StringArrayVarP(&k8sLabels, "label", "l", nil, "Secret 标签，key=value 形式，可重复指定")
+	exportK8sCmd.Flags().StringVarP(&k8sOutput, "output", "o", "", "输出文件路径（默认输出到标准输出）")
+
+	exportK8sCmd.MarkFlagRequired("cert-name")
+}
+
+func runExportK8s(cmd *cobra.Command, args []string) error {
+	lineage, err := cert.OpenLineage(k8sCertName)
+	if err != nil {
+		return err
+	}
+
+	k8sConfig := config.GetCertificateConfig(k8sCertName).Kubernetes
+	if k8sNamespace != "" {
+		k8sConfig.Namespace = k8sNamespace
+	}
+	if k8sName != "" {
+		k8sConfig.Name = k8sName
+	}
+	k8sConfig.Labels = append(k8sConfig.Labels, k8sLabels...)
+
+	if k8sOutput != "" {
+		if err := lineage.WriteKubernetesSecret(k8sConfig, k8sOutput); err != nil {
+			return fmt.Errorf("生成 Secret 清单失败: %w", err)
+		}
+		fmt.Printf("✓ Secret 清单已写入: %s\n", k8sOutput)
+		return nil
+	}
+
+	manifest, err := lineage.KubernetesSecret(k8sConfig)
+	if err != nil {
+		return fmt.Errorf("生成 Secret 清单失败: %w", err)
+	}
+
+	logger.Debug("输出 Secret 清单到标准输出", "certName", k8sCertName)
+	_, err = os.Stdout.Write(manifest)
+	return err
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -49,7 +50,18 @@ var scheduleCmd = &cobra.Command{
 var scheduleInstallCmd = &cobra.Command{
 	Use:   "install",
 	Short: "安装定时任务",
-	RunE:  runScheduleInstall,
+	Long: `安装证书自动续期定时任务。
+
+--schedule 支持 cron 表达式（5 个字段或 @daily 等简写）和 systemd 日历表达式。
+使用 systemd timer 时 cron 表达式会转换为 OnCalendar，并通过 systemd-analyze calendar 校验；
+使用 cron 时只支持 cron 表达式。
+
+示例:
+  autocert schedule install
+  autocert schedule install --schedule "30 3 * * 1-5"
+  autocert schedule install --schedule "*-*-* 04,16:00:00" --randomized-delay 30m
+  autocert schedule install --randomized-delay 0`,
+	RunE: runScheduleInstall,
 }
 
 var scheduleRemoveCmd = &cobra.Command{
@@ -69,6 +81,9 @@ var (
 	renewAll     bool
 	statusDomain string
 	taskName     string
+
+	scheduleExpr  string
+	scheduleDelay time.Duration
 )
 
 func init() {
@@ -90,6 +105,8 @@ func init() {
 
 	// schedule 命令参数
 	scheduleInstallCmd.Flags().StringVar(&taskName, "name", "autocert-renew", "任务名称")
+	scheduleInstallCmd.Flags().StringVar(&scheduleExpr, "schedule", scheduler.DefaultSchedule, "执行时间，cron 表达式或 systemd 日历表达式")
+	scheduleInstallCmd.Flags().DurationVar(&scheduleDelay, "randomized-delay", scheduler.DefaultRandomizedDelay, "systemd timer 的随机延迟（RandomizedDelaySec），0 表示不延迟")
 	scheduleRemoveCmd.Flags().StringVar(&taskName, "name", "autocert-renew", "任务名称")
 }
 
@@ -118,7 +135,11 @@ func runStatus(cmd *cobra.Command, args []string) error {
 }
 
 func runScheduleInstall(cmd *cobra.Command, args []string) error {
-	logger.Info("安装定时任务", "taskName", taskName)
+	logger.Info("安装定时任务", "taskName", taskName, "schedule", scheduleExpr)
+
+	if scheduleDelay < 0 {
+		return fmt.Errorf("--randomized-delay 不能为负数")
+	}
 
 	// 获取当前执行文件路径
 	execPath, err := os.Executable()
@@ -127,16 +148,15 @@ func runScheduleInstall(cmd *cobra.Command, args []string) error {
 	}
 
 	// 创建调度器
-	sched := scheduler.NewScheduler()
+	sched := scheduler.NewScheduler(scheduler.Options{RandomizedDelay: scheduleDelay})
 
-	// 安装任务（每日凌晨2点检查）
-	schedule := "0 2 * * *" // cron 格式
-	if err := sched.Install(taskName, execPath, schedule); err != nil {
+	// 安装任务
+	if err := sched.Install(taskName, execPath, scheduleExpr); err != nil {
 		return fmt.Errorf("安装定时任务失败: %w", err)
 	}
 
 	fmt.Printf("✓ 定时任务 '%s' 安装成功\n", taskName)
-	fmt.Println("任务将在每日凌晨2点自动检查并续期证书")
+	fmt.Printf("任务将按 %s 自动检查并续期证书\n", scheduleExpr)
 
 	return nil
 }
@@ -145,7 +165,7 @@ func runScheduleRemove(cmd *cobra.Command, args []string) error {
 	logger.Info("删除定时任务", "taskName", taskName)
 
 	// 创建调度器
-	sched := scheduler.NewScheduler()
+	sched := scheduler.NewScheduler(scheduler.Options{})
 
 	// 删除任务
 	if err := sched.Remove(taskName); err != nil {
@@ -160,7 +180,7 @@ func runScheduleList(cmd *cobra.Command, args []string) error {
 	logger.Info("列出定时任务")
 
 	// 创建调度器
-	sched := scheduler.NewScheduler()
+	sched := scheduler.NewScheduler(scheduler.Options{})
 
 	// 获取任务列表
 	tasks, err := sched.List()
@@ -175,11 +195,11 @@ func runScheduleList(cmd *cobra.Command, args []string) error {
 
 	// 显示任务列表
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "任务名称\t状态\t下次运行\t上次运行")
-	fmt.Fprintln(w, "--------\t----\t--------\t--------")
+	fmt.Fprintln(w, "任务名称\t状态\t执行时间\t下次运行\t上次运行")
+	fmt.Fprintln(w, "--------\t----\t--------\t--------\t--------")
 
 	for _, task := range tasks {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", task.Name, task.Status, task.NextRun, task.LastRun)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", task.Name, task.Status, task.Schedule, task.NextRun, task.LastRun)
 	}
 
 	w.Flush()
@@ -230,6 +250,7 @@ func showDomainStatus(domain string) error {
 		fmt.Printf("所有域名: %v\n", certInfo.Domains)
 	}
 	fmt.Printf("证书路径: %s\n", certInfo.CertPath)
+	fmt.Printf("完整证书链: %s\n", certInfo.FullchainPath)
 	fmt.Printf("私钥路径: %s\n", certInfo.KeyPath)
 	fmt.Printf("到期时间: %s\n", certInfo.ExpiryDate.Format("2006-01-02 15:04:05"))
 	fmt.Printf("剩余天数: %d 天\n", certInfo.DaysLeft)
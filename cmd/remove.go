@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"autocert/internal/cert"
+	"autocert/internal/logger"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var removeCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "删除证书及其 Web 服务器配置",
+	Long: `删除证书之前先删除 Web 服务器中为该证书生成的配置，测试并重载成功后再删除证书目录。
+
+- Nginx、Apache：删除 AutoCert 生成的站点配置和 sites-enabled 中的链接
+- lighttpd：删除 autocert.d 中的证书配置
+- HAProxy：从 crt 目录或 crt-list 中删除证书
+- Traefik：从动态配置中删除证书条目
+
+其他配置仍引用该证书时拒绝删除，避免 Web 服务器重载失败。
+
+示例:
+  autocert remove --cert-name example.com_san --nginx
+  autocert remove --cert-name example.com_san --nginx --plan
+  autocert remove --cert-name mail.example.com --cert-only`,
+	RunE: runRemove,
+}
+
+var (
+	removeCertName string
+	removeNginx    bool
+	removeApache   bool
+	removeHAProxy  bool
+	removeTraefik  bool
+	removeLighttpd bool
+	removeCertOnly bool
+	removeKeep     bool
+	removePlan     bool
+)
+
+func init() {
+	rootCmd.AddCommand(removeCmd)
+
+	removeCmd.Flags().StringVar(&removeCertName, "cert-name", "", "证书名称（证书目录名）")
+	removeCmd.Flags().BoolVar(&removeNginx, "nginx", false, "删除 Nginx 中的站点配置")
+	removeCmd.Flags().BoolVar(&removeApache, "apache", false, "删除 Apache 中的站点配置")
+	removeCmd.Flags().BoolVar(&removeHAProxy, "haproxy", false, "从 HAProxy 中删除证书")
+	removeCmd.Flags().BoolVar(&removeTraefik, "traefik", false, "从 Traefik 动态配置中删除证书")
+	removeCmd.Flags().BoolVar(&removeLighttpd, "lighttpd", false, "删除 lighttpd 中的证书配置")
+	removeCmd.Flags().BoolVar(&removeCertOnly, "cert-only", false, "只删除证书文件，不修改 Web 服务器配置")
+	removeCmd.Flags().BoolVar(&removeKeep, "keep-cert", false, "只删除 Web 服务器配置，保留证书文件")
+	removeCmd.Flags().BoolVar(&removePlan, "plan", false, "只输出将删除的文件和执行的命令，不做任何修改")
+	removeCmd.MarkFlagRequired("cert-name")
+}
+
+func runRemove(cmd *cobra.Command, args []string) error {
+	var types []cert.WebServerType
+	for _, f := range []struct {
+		set bool
+		typ cert.WebServerType
+	}{
+		{removeNginx, cert.WebServerNginx},
+		{removeApache, cert.WebServerApache},
+		{removeHAProxy, cert.WebServerHAProxy},
+		{removeTraefik, cert.WebServerTraefik},
+		{removeLighttpd, cert.WebServerLighttpd},
+	} {
+		if f.set {
+			types = append(types, f.typ)
+		}
+	}
+
+	switch {
+	case len(types) > 1:
+		return fmt.Errorf("只能指定一种 Web 服务器类型")
+	case len(types) == 0 && !removeCertOnly:
+		return fmt.Errorf("必须指定 Web 服务器类型: --nginx, --apache, --haproxy, --traefik 或 --lighttpd（不修改 Web 服务器配置时指定 --cert-only）")
+	case len(types) > 0 && removeCertOnly:
+		return fmt.Errorf("--cert-only 不能与 Web 服务器类型同时使用")
+	case removeCertOnly && removeKeep:
+		return fmt.Errorf("--cert-only 和 --keep-cert 不能同时使用")
+	}
+
+	opts := cert.RemoveOptions{Site: len(types) > 0, KeepCert: removeKeep}
+	if opts.Site {
+		opts.WebServer = types[0]
+	}
+
+	if removePlan {
+		plan, err := cert.PlanRemoveCertificate(removeCertName, opts)
+		if err != nil {
+			return fmt.Errorf("生成删除计划失败: %w", err)
+		}
+		fmt.Print(plan.Diff())
+		return nil
+	}
+
+	logger.Info("开始删除证书", "certName", removeCertName)
+	if err := cert.RemoveCertificate(removeCertName, opts); err != nil {
+		return err
+	}
+
+	if removeKeep {
+		fmt.Printf("✓ 已删除证书 %s 的 Web 服务器配置，证书文件已保留\n", removeCertName)
+	} else {
+		fmt.Printf("✓ 证书 %s 已删除\n", removeCertName)
+	}
+	return nil
+}
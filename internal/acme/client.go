@@ -18,6 +18,7 @@ import (
 	"github.com/go-acme/lego/v4/challenge/http01"
 	"github.com/go-acme/lego/v4/challenge/tlsalpn01"
 	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/providers/http/webroot"
 	"github.com/go-acme/lego/v4/registration"
 )
 
@@ -134,9 +135,11 @@ func NewClient(cfg *ClientConfig) (*Client, error) {
 // SetHTTPChallenge 设置 HTTP-01 挑战
 func (c *Client) SetHTTPChallenge() error {
 	if c.webroot != "" {
-		// 使用 webroot 模式
-		provider := http01.NewProviderServer("", c.httpPort)
-		// 注意：lego 的 webroot provider 需要额外配置
+		// 使用 webroot 模式：验证文件写入 <webroot>/.well-known/acme-challenge/，由 Web 服务器提供
+		provider, err := webroot.NewHTTPProvider(c.webroot)
+		if err != nil {
+			return fmt.Errorf("创建 webroot 验证失败: %w", err)
+		}
 		return c.client.Challenge.SetHTTP01Provider(provider)
 	}
 
@@ -175,6 +178,26 @@ func (c *Client) ObtainCertificate(domains []string) (*certificate.Resource, err
 	return certificates, nil
 }
 
+// ObtainCertificateForCSR 使用已有的 CSR 和私钥获取证书
+// 返回的 Certificate 为叶子证书在前的完整证书链
+func (c *Client) ObtainCertificateForCSR(csr *x509.CertificateRequest, privateKey crypto.PrivateKey) (*certificate.Resource, error) {
+	logger.Info("开始申请证书", "domains", csr.DNSNames)
+
+	request := certificate.ObtainForCSRRequest{
+		CSR:        csr,
+		PrivateKey: privateKey,
+		Bundle:     true,
+	}
+
+	certificates, err := c.client.Certificate.ObtainForCSR(request)
+	if err != nil {
+		return nil, fmt.Errorf("获取证书失败: %w", err)
+	}
+
+	logger.Info("证书申请成功", "domains", csr.DNSNames)
+	return certificates, nil
+}
+
 // RenewCertificate 续期证书
 func (c *Client) RenewCertificate(cert *certificate.Resource) (*certificate.Resource, error) {
 	logger.Info("开始续期证书", "domains", cert.Domain)
@@ -188,57 +211,25 @@ func (c *Client) RenewCertificate(cert *certificate.Resource) (*certificate.Reso
 	return newCert, nil
 }
 
-// SaveCertificate 保存证书到指定目录
-func (c *Client) SaveCertificate(cert *certificate.Resource, certDir string) error {
+// SaveMetadata 保存证书元数据到证书目录
+// 证书和私钥文件由 cert 包按统一布局写入
+func (c *Client) SaveMetadata(cert *certificate.Resource, certDir string) error {
 	if err := os.MkdirAll(certDir, 0755); err != nil {
 		return fmt.Errorf("创建证书目录失败: %w", err)
 	}
 
-	// 保存证书
-	certPath := filepath.Join(certDir, "cert.pem")
-	if err := os.WriteFile(certPath, cert.Certificate, 0644); err != nil {
-		return fmt.Errorf("保存证书失败: %w", err)
-	}
-
-	// 保存私钥
-	keyPath := filepath.Join(certDir, "key.pem")
-	if err := os.WriteFile(keyPath, cert.PrivateKey, 0600); err != nil {
-		return fmt.Errorf("保存私钥失败: %w", err)
-	}
-
-	// 保存证书链（如果有）
-	if cert.IssuerCertificate != nil {
-		chainPath := filepath.Join(certDir, "chain.pem")
-		if err := os.WriteFile(chainPath, cert.IssuerCertificate, 0644); err != nil {
-			return fmt.Errorf("保存证书链失败: %w", err)
-		}
-	}
-
-	// 保存完整链（证书 + 中间证书）
-	fullchainPath := filepath.Join(certDir, "fullchain.pem")
-	fullchain := append(cert.Certificate, cert.IssuerCertificate...)
-	if err := os.WriteFile(fullchainPath, fullchain, 0644); err != nil {
-		return fmt.Errorf("保存完整证书链失败: %w", err)
-	}
-
-	// 保存证书元数据
 	metaPath := filepath.Join(certDir, "cert.json")
 	meta := map[string]interface{}{
 		"domain":        cert.Domain,
 		"certURL":       cert.CertURL,
 		"certStableURL": cert.CertStableURL,
 	}
-	metaData, _ := json.MarshalIndent(meta, "", "  ")
-	if err := os.WriteFile(metaPath, metaData, 0644); err != nil {
-		logger.Warn("保存证书元数据失败", "error", err)
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
 	}
 
-	logger.Info("证书保存完成",
-		"certPath", certPath,
-		"keyPath", keyPath,
-		"domain", cert.Domain)
-
-	return nil
+	return os.WriteFile(metaPath, metaData, 0644)
 }
 
 // loadOrCreateUser 加载或创建用户
@@ -0,0 +1,110 @@
+package cert
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"autocert/internal/webserver"
+	"fmt"
+	"os"
+)
+
+// challengeDir HTTP-01 验证文件目录
+// 命令行指定了 --webroot 时沿用网站根目录，否则使用配置的 acme.webroot
+func (m *Manager) challengeDir() string {
+	if m.webrootPath != "" {
+		return m.webrootPath
+	}
+	return config.GetChallengeDir()
+}
+
+// prepareChallenge 选择 HTTP-01 验证方式，返回 ACME 写入验证文件的目录，为空时使用内置的 80 端口服务器
+// 命令行指定了 --webroot 时由用户保证该目录可以访问；否则只有 Web 服务器的挑战配置对所有域名生效时才使用挑战目录
+func (m *Manager) prepareChallenge() (string, error) {
+	if m.webrootPath != "" {
+		if err := os.MkdirAll(m.webrootPath, 0755); err != nil {
+			return "", fmt.Errorf("创建挑战目录失败: %w", err)
+		}
+		return m.webrootPath, nil
+	}
+
+	c, ok := m.configurator.(webserver.ChallengeConfigurator)
+	if !ok {
+		logger.Info("Web 服务器不提供 ACME 挑战目录，使用内置的 HTTP 服务器验证", "port", 80)
+		return "", nil
+	}
+
+	cfg := m.webServerConfig()
+	if err := applyChallenge(c, cfg, false); err != nil {
+		return "", err
+	}
+	ready, err := c.ChallengeReady(cfg)
+	if err != nil {
+		return "", fmt.Errorf("检查 ACME 挑战配置失败: %w", err)
+	}
+	if !ready {
+		logger.Warn("Web 服务器没有为所有域名提供 ACME 挑战目录，使用内置的 HTTP 服务器验证（需要 80 端口空闲）",
+			"domains", m.domains, "hint", "autocert challenge --include")
+		return "", nil
+	}
+	return cfg.ChallengeDir, nil
+}
+
+// applyChallenge 创建挑战目录并写入挑战配置，只在有修改时测试和重载，失败时回滚
+func applyChallenge(c webserver.ChallengeConfigurator, cfg *webserver.Config, include bool) error {
+	if err := os.MkdirAll(cfg.ChallengeDir, 0755); err != nil {
+		return fmt.Errorf("创建挑战目录失败: %w", err)
+	}
+
+	changed, err := c.ConfigureChallenge(cfg, include)
+	if err != nil {
+		return rollbackConfigurator(c, err, false)
+	}
+	if !changed {
+		logger.Info("ACME 挑战配置已是最新", "dir", cfg.ChallengeDir)
+		return nil
+	}
+	if err := c.Test(); err != nil {
+		return rollbackConfigurator(c, fmt.Errorf("配置测试失败: %w", err), false)
+	}
+	if err := c.Reload(); err != nil {
+		return rollbackConfigurator(c, fmt.Errorf("重载配置失败: %w", err), true)
+	}
+	logger.Info("Web 服务器已提供 ACME 挑战目录", "dir", cfg.ChallengeDir)
+	return nil
+}
+
+// planChallenge 计算 prepareChallenge 将执行的修改
+func (m *Manager) planChallenge() (*webserver.Plan, error) {
+	c, ok := m.configurator.(webserver.ChallengeConfigurator)
+	if m.challengeType != ChallengeWebroot || !ok || m.webrootPath != "" {
+		return nil, nil
+	}
+	return c.PlanChallenge(m.webServerConfig(), false)
+}
+
+// ConfigureChallenge 为 Web 服务器安装共享的 ACME 挑战配置，不需要证书
+// include 为 true 时在已有的 HTTP 站点中引用（Nginx）；plan 为 true 时只返回将执行的修改
+func ConfigureChallenge(webServerType WebServerType, include, plan bool) (*webserver.Plan, error) {
+	configurator, err := webserver.NewConfigurator(webServerType.String())
+	if err != nil {
+		return nil, err
+	}
+	c, ok := configurator.(webserver.ChallengeConfigurator)
+	if !ok {
+		return nil, fmt.Errorf("%s 不支持共享的 ACME 挑战配置", webServerType)
+	}
+
+	webServer := config.GetWebServerConfig()
+	cfg := &webserver.Config{
+		Type:         webServerType.String(),
+		ConfigPath:   webServer.ConfigPath,
+		Binary:       webServer.Binary,
+		ChallengeDir: config.GetChallengeDir(),
+		Reload:       globalReloadConfig(),
+	}
+
+	if plan {
+		return c.PlanChallenge(cfg, include)
+	}
+	return nil, applyChallenge(c, cfg, include)
+}
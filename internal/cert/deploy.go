@@ -0,0 +1,58 @@
+package cert
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/deploy"
+	"autocert/internal/logger"
+	"fmt"
+)
+
+// Deploy 将证书部署到配置的所有目标
+// 单个目标失败不会影响其他目标，所有失败汇总后返回
+func (l *Lineage) Deploy(targets []config.DeployTargetConfig) error {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	src, err := l.deploySource()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, target := range targets {
+		if err := deploy.Apply(src, target); err != nil {
+			logger.Error("证书部署失败", "certName", l.Name, "target", deploy.Describe(target), "error", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d 个部署目标失败", failed)
+	}
+	return nil
+}
+
+// deploySource 构造部署所需的证书信息
+func (l *Lineage) deploySource() (*deploy.Source, error) {
+	certs, err := l.LoadCertificates()
+	if err != nil {
+		return nil, err
+	}
+	leaf := certs[0]
+
+	return &deploy.Source{
+		Name:    l.Name,
+		Dir:     l.Dir,
+		Domains: leaf.DNSNames,
+		Serial:  leaf.SerialNumber.Text(16),
+		Expiry:  leaf.NotAfter,
+		Files: map[string]string{
+			deploy.FileCert:      l.CertPath(),
+			deploy.FileChain:     l.ChainPath(),
+			deploy.FileFullchain: l.FullchainPath(),
+			deploy.FileKey:       l.KeyPath(),
+			deploy.FileCombined:  l.CombinedPath(),
+		},
+	}, nil
+}
@@ -0,0 +1,28 @@
+package cert
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/webserver"
+	"fmt"
+)
+
+// DiscoverSites 从已有的 Web 服务器配置中发现站点，每个站点建议申请一张证书
+// site 不为空时只读取该配置文件
+func DiscoverSites(webServerType WebServerType, site string) ([]webserver.DiscoveredSite, error) {
+	configurator, err := webserver.NewConfigurator(webServerType.String())
+	if err != nil {
+		return nil, err
+	}
+	d, ok := configurator.(webserver.SiteDiscoverer)
+	if !ok {
+		return nil, fmt.Errorf("%s 不支持从配置中发现域名", webServerType)
+	}
+
+	webServer := config.GetWebServerConfig()
+	cfg := &webserver.Config{
+		Type:       webServerType.String(),
+		ConfigPath: webServer.ConfigPath,
+		Binary:     webServer.Binary,
+	}
+	return webserver.DiscoverSites(d, cfg, site)
+}
@@ -0,0 +1,165 @@
+package cert
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	keystore "github.com/pavlo-v-chernykh/keystore-go/v4"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// 支持的额外输出格式
+const (
+	FormatPKCS12   = "pkcs12"   // PKCS#12 / PFX，包含私钥和完整证书链
+	FormatDER      = "der"      // DER 编码的叶子证书
+	FormatJKS      = "jks"      // Java KeyStore
+	FormatCombined = "combined" // 私钥 + 完整证书链的 PEM
+)
+
+// defaultFormatFileNames 各格式在证书目录中的默认文件名
+var defaultFormatFileNames = map[string]string{
+	FormatPKCS12:   "cert.p12",
+	FormatDER:      "cert.der",
+	FormatJKS:      "keystore.jks",
+	FormatCombined: CombinedFileName,
+}
+
+// normalizeFormat 规范化格式名称
+func normalizeFormat(format string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "pkcs12", "p12", "pfx":
+		return FormatPKCS12, nil
+	case "der", "cer":
+		return FormatDER, nil
+	case "jks", "keystore":
+		return FormatJKS, nil
+	case "combined", "pem-combined":
+		return FormatCombined, nil
+	default:
+		return "", fmt.Errorf("不支持的证书格式: %s", format)
+	}
+}
+
+// Convert 将证书转换为指定格式，返回生成的文件路径
+func (l *Lineage) Convert(f config.OutputFormatConfig) (string, error) {
+	format, err := normalizeFormat(f.Type)
+	if err != nil {
+		return "", err
+	}
+
+	outPath := f.Path
+	if outPath == "" {
+		outPath = filepath.Join(l.Dir, defaultFormatFileNames[format])
+	}
+
+	certs, err := l.LoadCertificates()
+	if err != nil {
+		return "", err
+	}
+
+	var data []byte
+	switch format {
+	case FormatPKCS12:
+		data, err = l.encodePKCS12(certs, f.Password)
+	case FormatDER:
+		data = certs[0].Raw
+	case FormatJKS:
+		alias := f.Alias
+		if alias == "" {
+			alias = l.Name
+		}
+		data, err = l.encodeJKS(certs, alias, f.Password)
+	case FormatCombined:
+		data, err = os.ReadFile(l.CombinedPath())
+	}
+	if err != nil {
+		return "", fmt.Errorf("生成 %s 格式失败: %w", format, err)
+	}
+
+	// 包含私钥的文件只允许所有者读取
+	perm := os.FileMode(0600)
+	if format == FormatDER {
+		perm = 0644
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(outPath, data, perm); err != nil {
+		return "", fmt.Errorf("写入 %s 失败: %w", outPath, err)
+	}
+
+	logger.Info("证书格式转换完成", "certName", l.Name, "format", format, "path", outPath)
+	return outPath, nil
+}
+
+// ConvertAll 按配置生成所有额外格式
+func (l *Lineage) ConvertAll(formats []config.OutputFormatConfig) error {
+	var failed []string
+	for _, f := range formats {
+		if _, err := l.Convert(f); err != nil {
+			logger.Error("证书格式转换失败", "certName", l.Name, "format", f.Type, "error", err)
+			failed = append(failed, f.Type)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("以下格式生成失败: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// encodePKCS12 生成 PKCS#12 文件
+func (l *Lineage) encodePKCS12(certs []*x509.Certificate, password string) ([]byte, error) {
+	key, err := l.LoadPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return pkcs12.Modern.Encode(key, certs[0], certs[1:], password)
+}
+
+// encodeJKS 生成 Java KeyStore 文件
+func (l *Lineage) encodeJKS(certs []*x509.Certificate, alias, password string) ([]byte, error) {
+	if password == "" {
+		return nil, fmt.Errorf("JKS 格式必须设置密码")
+	}
+
+	key, err := l.LoadPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make([]keystore.Certificate, 0, len(certs))
+	for _, c := range certs {
+		chain = append(chain, keystore.Certificate{Type: "X509", Content: c.Raw})
+	}
+
+	ks := keystore.New()
+	entry := keystore.PrivateKeyEntry{
+		CreationTime:     time.Now(),
+		PrivateKey:       keyDER,
+		CertificateChain: chain,
+	}
+	if err := ks.SetPrivateKeyEntry(alias, entry, []byte(password)); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := ks.Store(&buf, []byte(password)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
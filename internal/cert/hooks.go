@@ -0,0 +1,73 @@
+package cert
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/hook"
+	"autocert/internal/logger"
+	"strings"
+	"time"
+)
+
+// runHooks 执行指定时机的全局钩子和证书专属钩子
+// 全局钩子先执行；任一钩子失败都会返回错误，但不会阻止后续钩子执行
+func (m *Manager) runHooks(event hook.Event) error {
+	var firstErr error
+	env := m.hookEnv(event)
+
+	for _, hooks := range m.hookConfigs() {
+		if err := hook.Run(event, hookCommand(hooks, event), env, hooks.Timeout); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// hookConfigs 获取全局钩子和证书专属钩子配置
+func (m *Manager) hookConfigs() []config.HooksConfig {
+	var result []config.HooksConfig
+	if config.AppConfig != nil {
+		result = append(result, config.AppConfig.Hooks)
+	}
+	result = append(result, config.GetCertificateConfig(m.getDirName()).Hooks)
+	return result
+}
+
+// hookCommand 获取指定时机的钩子命令
+func hookCommand(hooks config.HooksConfig, event hook.Event) string {
+	switch event {
+	case hook.EventPre:
+		return hooks.PreHook
+	case hook.EventPost:
+		return hooks.PostHook
+	case hook.EventDeploy:
+		return hooks.DeployHook
+	default:
+		return ""
+	}
+}
+
+// hookEnv 构造传递给钩子的环境变量
+func (m *Manager) hookEnv(event hook.Event) map[string]string {
+	l := m.lineage()
+	env := map[string]string{
+		"AUTOCERT_HOOK":           string(event),
+		"AUTOCERT_CERT_NAME":      l.Name,
+		"AUTOCERT_LINEAGE":        l.Dir,
+		"AUTOCERT_DOMAINS":        strings.Join(m.domains, " "),
+		"AUTOCERT_CERT_PATH":      l.CertPath(),
+		"AUTOCERT_CHAIN_PATH":     l.ChainPath(),
+		"AUTOCERT_FULLCHAIN_PATH": l.FullchainPath(),
+		"AUTOCERT_KEY_PATH":       l.KeyPath(),
+	}
+
+	// 证书存在时提供序列号和到期时间（pre 钩子中为旧证书的信息）
+	if certs, err := l.LoadCertificates(); err == nil {
+		env["AUTOCERT_SERIAL"] = certs[0].SerialNumber.Text(16)
+		env["AUTOCERT_EXPIRY"] = certs[0].NotAfter.UTC().Format(time.RFC3339)
+	} else {
+		logger.Debug("证书文件不存在，钩子环境变量中不包含序列号", "certName", l.Name)
+	}
+
+	return env
+}
@@ -0,0 +1,130 @@
+package cert
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// k8sSecret kubernetes.io/tls 类型的 Secret 清单
+type k8sSecret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Type       string            `yaml:"type"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type k8sMetadata struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// invalidK8sNameChars Secret 名称中不允许出现的字符
+var invalidK8sNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// KubernetesSecret 生成 kubernetes.io/tls Secret 清单（YAML）
+func (l *Lineage) KubernetesSecret(cfg config.KubernetesConfig) ([]byte, error) {
+	fullchain, err := os.ReadFile(l.FullchainPath())
+	if err != nil {
+		return nil, fmt.Errorf("读取完整证书链失败: %w", err)
+	}
+	key, err := os.ReadFile(l.KeyPath())
+	if err != nil {
+		return nil, fmt.Errorf("读取私钥失败: %w", err)
+	}
+	certs, err := parseCertificateChain(fullchain)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{
+		"app.kubernetes.io/managed-by": "autocert",
+	}
+	for _, label := range cfg.Labels {
+		k, v, ok := strings.Cut(label, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("无效的标签 %q，应为 key=value 形式", label)
+		}
+		labels[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	name := cfg.Name
+	if name == "" {
+		name = k8sSecretName(l.Name)
+	}
+
+	secret := k8sSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Type:       "kubernetes.io/tls",
+		Metadata: k8sMetadata{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+			Annotations: map[string]string{
+				"autocert/cert-name": l.Name,
+				"autocert/serial":    certs[0].SerialNumber.Text(16),
+				"autocert/expiry":    certs[0].NotAfter.UTC().Format(time.RFC3339),
+			},
+		},
+		Data: map[string]string{
+			"tls.crt": base64.StdEncoding.EncodeToString(fullchain),
+			"tls.key": base64.StdEncoding.EncodeToString(key),
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(secret); err != nil {
+		return nil, fmt.Errorf("生成 Secret 清单失败: %w", err)
+	}
+	enc.Close()
+
+	return buf.Bytes(), nil
+}
+
+// WriteKubernetesSecret 生成 Secret 清单并写入文件
+func (l *Lineage) WriteKubernetesSecret(cfg config.KubernetesConfig, output string) error {
+	manifest, err := l.KubernetesSecret(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return err
+	}
+	// 清单中包含私钥
+	if err := writeFileAtomic(output, manifest, 0600); err != nil {
+		return fmt.Errorf("写入 Secret 清单失败: %w", err)
+	}
+
+	logger.Info("Kubernetes Secret 清单已生成", "certName", l.Name, "output", output)
+	return nil
+}
+
+// k8sSecretName 根据证书名称生成合法的 Secret 名称
+// 例如 *.example.com -> wildcard.example.com-tls，example.com_san -> example.com-san-tls
+func k8sSecretName(certName string) string {
+	name := strings.ToLower(certName)
+	name = strings.ReplaceAll(name, "*", "wildcard")
+	name = invalidK8sNameChars.ReplaceAllString(name, "-")
+	name = strings.Trim(name, ".-")
+	return name + "-tls"
+}
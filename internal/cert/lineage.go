@@ -0,0 +1,332 @@
+package cert
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 证书目录（lineage）中的文件布局
+const (
+	CertFileName      = "cert.pem"      // 仅叶子证书
+	ChainFileName     = "chain.pem"     // 中间证书链（不含叶子证书）
+	FullchainFileName = "fullchain.pem" // 叶子证书 + 中间证书链，Web 服务器应引用此文件
+	KeyFileName       = "key.pem"       // 私钥
+	CombinedFileName  = "combined.pem"  // 私钥 + 完整证书链（HAProxy 等使用）
+)
+
+// Lineage 一个证书的目录及其文件
+type Lineage struct {
+	Name string // 证书名称（即证书目录名）
+	Dir  string
+}
+
+// NewLineage 根据证书名称创建 Lineage，名称必须是证书目录下的一级目录名
+func NewLineage(name string) (*Lineage, error) {
+	if err := validateCertName(name); err != nil {
+		return nil, err
+	}
+	return &Lineage{
+		Name: name,
+		Dir:  filepath.Join(config.GetCertDir(), name),
+	}, nil
+}
+
+// OpenLineage 打开已存在的证书目录
+func OpenLineage(name string) (*Lineage, error) {
+	l, err := NewLineage(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(l.FullchainPath()); err != nil {
+		return nil, fmt.Errorf("证书 %s 不存在: %w", name, err)
+	}
+	return l, nil
+}
+
+// validateCertName 证书名称只能是证书目录下的一级目录名，不能包含路径分隔符或 ..
+func validateCertName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("证书名称不能为空")
+	case strings.ContainsAny(name, `/\`) || name == "." || name == "..":
+		return fmt.Errorf("无效的证书名称: %s", name)
+	}
+	return nil
+}
+
+// CertPath 叶子证书路径
+func (l *Lineage) CertPath() string {
+	return filepath.Join(l.Dir, CertFileName)
+}
+
+// ChainPath 中间证书链路径
+func (l *Lineage) ChainPath() string {
+	return filepath.Join(l.Dir, ChainFileName)
+}
+
+// FullchainPath 完整证书链路径
+func (l *Lineage) FullchainPath() string {
+	return filepath.Join(l.Dir, FullchainFileName)
+}
+
+// KeyPath 私钥路径
+func (l *Lineage) KeyPath() string {
+	return filepath.Join(l.Dir, KeyFileName)
+}
+
+// CombinedPath 私钥 + 完整证书链路径
+func (l *Lineage) CombinedPath() string {
+	return filepath.Join(l.Dir, CombinedFileName)
+}
+
+// Write 按标准布局写入证书文件
+// chainPEM 为叶子证书在前的 PEM 证书链，keyPEM 为 PEM 格式私钥
+func (l *Lineage) Write(chainPEM, keyPEM []byte) error {
+	certs, err := parseCertificateChain(chainPEM)
+	if err != nil {
+		return err
+	}
+
+	// 写入前先校验，避免用无效的证书覆盖可用的旧证书
+	if err := verifyChain(certs, keyPEM); err != nil {
+		return fmt.Errorf("证书链校验失败: %w", err)
+	}
+
+	leafPEM := encodeCertificates(certs[:1])
+	intermediatesPEM := encodeCertificates(certs[1:])
+	fullchainPEM := encodeCertificates(certs)
+
+	combinedPEM := make([]byte, 0, len(keyPEM)+len(fullchainPEM))
+	combinedPEM = append(combinedPEM, keyPEM...)
+	combinedPEM = append(combinedPEM, fullchainPEM...)
+
+	if err := os.MkdirAll(l.Dir, 0755); err != nil {
+		return fmt.Errorf("创建证书目录失败: %w", err)
+	}
+
+	files := []struct {
+		path string
+		data []byte
+		perm os.FileMode
+	}{
+		{l.KeyPath(), keyPEM, 0600},
+		{l.CombinedPath(), combinedPEM, 0600},
+		{l.CertPath(), leafPEM, 0644},
+		{l.ChainPath(), intermediatesPEM, 0644},
+		{l.FullchainPath(), fullchainPEM, 0644},
+	}
+
+	for _, f := range files {
+		if err := writeFileAtomic(f.path, f.data, f.perm); err != nil {
+			return fmt.Errorf("写入 %s 失败: %w", filepath.Base(f.path), err)
+		}
+	}
+
+	logger.Debug("证书文件写入完成", "dir", l.Dir, "chainLength", len(certs))
+	return nil
+}
+
+// Verify 校验磁盘上的证书文件
+func (l *Lineage) Verify() error {
+	fullchainPEM, err := os.ReadFile(l.FullchainPath())
+	if err != nil {
+		return fmt.Errorf("读取完整证书链失败: %w", err)
+	}
+
+	keyPEM, err := os.ReadFile(l.KeyPath())
+	if err != nil {
+		return fmt.Errorf("读取私钥失败: %w", err)
+	}
+
+	certs, err := parseCertificateChain(fullchainPEM)
+	if err != nil {
+		return err
+	}
+
+	return verifyChain(certs, keyPEM)
+}
+
+// LoadCertificates 读取完整证书链，叶子证书在前
+func (l *Lineage) LoadCertificates() ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(l.FullchainPath())
+	if err != nil {
+		return nil, fmt.Errorf("读取完整证书链失败: %w", err)
+	}
+	return parseCertificateChain(data)
+}
+
+// LoadPrivateKey 读取私钥
+func (l *Lineage) LoadPrivateKey() (crypto.Signer, error) {
+	data, err := os.ReadFile(l.KeyPath())
+	if err != nil {
+		return nil, fmt.Errorf("读取私钥失败: %w", err)
+	}
+	return parsePrivateKey(data)
+}
+
+// parseCertificateChain 解析 PEM 证书链
+func parseCertificateChain(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("解析证书失败: %w", err)
+		}
+		certs = append(certs, c)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("证书链中没有证书")
+	}
+	return certs, nil
+}
+
+// parsePrivateKey 解析 PEM 私钥（PKCS#1、PKCS#8 或 EC）
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("无法解析私钥文件")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥失败: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("不支持的私钥类型: %T", key)
+	}
+	return signer, nil
+}
+
+// encodeCertificates 将证书编码为 PEM
+func encodeCertificates(certs []*x509.Certificate) []byte {
+	var buf bytes.Buffer
+	for _, c := range certs {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+	}
+	return buf.Bytes()
+}
+
+// verifyChain 校验私钥与叶子证书匹配，且证书链逐级签名有效
+func verifyChain(certs []*x509.Certificate, keyPEM []byte) error {
+	leaf := certs[0]
+
+	key, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return err
+	}
+	if !publicKeysEqual(leaf.PublicKey, key.Public()) {
+		return fmt.Errorf("私钥与证书不匹配")
+	}
+
+	// 每一张证书都必须由链中的下一张签发
+	for i := 0; i < len(certs)-1; i++ {
+		if err := certs[i].CheckSignatureFrom(certs[i+1]); err != nil {
+			return fmt.Errorf("证书 %q 不是由 %q 签发: %w",
+				certs[i].Subject.CommonName, certs[i+1].Subject.CommonName, err)
+		}
+	}
+
+	now := time.Now()
+	if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return fmt.Errorf("证书不在有效期内: %s - %s",
+			leaf.NotBefore.Format(time.RFC3339), leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	// 自签名证书无法通过系统根证书校验
+	if len(certs) == 1 && isSelfSigned(leaf) {
+		logger.Warn("证书为自签名证书，跳过信任链校验", "subject", leaf.Subject.CommonName)
+		return nil
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	if _, err := leaf.Verify(x509.VerifyOptions{Intermediates: intermediates}); err != nil {
+		// 测试环境 CA 的根证书不在系统信任库中，这里只给出警告
+		logger.Warn("证书链无法通过系统根证书校验", "subject", leaf.Subject.CommonName, "error", err)
+	}
+
+	return nil
+}
+
+// isSelfSigned 判断证书是否为自签名证书
+func isSelfSigned(c *x509.Certificate) bool {
+	if !bytes.Equal(c.RawIssuer, c.RawSubject) {
+		return false
+	}
+	return c.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature) == nil
+}
+
+// publicKeysEqual 比较两个公钥是否相同
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	type equaler interface {
+		Equal(x crypto.PublicKey) bool
+	}
+
+	switch k := a.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return k.(equaler).Equal(b)
+	default:
+		return false
+	}
+}
+
+// writeFileAtomic 先写入临时文件再重命名，避免读取方看到写了一半的文件
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
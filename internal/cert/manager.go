@@ -3,7 +3,9 @@ package cert
 import (
 	"autocert/internal/acme"
 	"autocert/internal/config"
+	"autocert/internal/hook"
 	"autocert/internal/logger"
+	"autocert/internal/notify"
 	"autocert/internal/webserver"
 	"crypto/rand"
 	"crypto/rsa"
@@ -11,6 +13,7 @@ import (
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
 	"strings"
@@ -46,6 +49,9 @@ const (
 	WebServerNginx WebServerType = iota
 	WebServerApache
 	WebServerIIS
+	WebServerHAProxy
+	WebServerTraefik
+	WebServerLighttpd
 )
 
 func (w WebServerType) String() string {
@@ -56,6 +62,12 @@ func (w WebServerType) String() string {
 		return "apache"
 	case WebServerIIS:
 		return "iis"
+	case WebServerHAProxy:
+		return "haproxy"
+	case WebServerTraefik:
+		return "traefik"
+	case WebServerLighttpd:
+		return "lighttpd"
 	default:
 		return "unknown"
 	}
@@ -63,14 +75,15 @@ func (w WebServerType) String() string {
 
 // CertInfo 证书信息
 type CertInfo struct {
-	Domain     string
-	Domains    []string // 所有域名（SAN证书）
-	CertPath   string
-	KeyPath    string
-	ChainPath  string
-	ExpiryDate time.Time
-	IsValid    bool
-	DaysLeft   int
+	Domain        string
+	Domains       []string // 所有域名（SAN证书）
+	CertPath      string   // 仅叶子证书
+	KeyPath       string
+	ChainPath     string // 中间证书链
+	FullchainPath string // 叶子证书 + 中间证书链
+	ExpiryDate    time.Time
+	IsValid       bool
+	DaysLeft      int
 }
 
 // Manager 统一证书管理器（支持单域名和多域名）
@@ -80,10 +93,18 @@ type Manager struct {
 	email         string
 	challengeType ChallengeType
 	webrootPath   string
+	httpWebroot   string // HTTP-01 验证文件写入的目录，为空时使用内置的 80 端口服务器
 	webServerType WebServerType
+	redirect      bool
+	template      string // 站点模板名称或模板文件路径
+	upstream      string // 站点模板使用的上游地址
+	tlsProfile    string // 命令行指定的 TLS 安全配置
+	hsts          bool   // 命令行指定启用 HSTS
 	certDir       string
 	keySize       int
 	configurator  webserver.Configurator
+	services      []string        // 命令行指定的其他服务（postfix, dovecot）
+	privateKey    *rsa.PrivateKey // 本次申请使用的私钥
 }
 
 // NewManager 创建证书管理器
@@ -100,6 +121,9 @@ func NewManager(domains string, email string) *Manager {
 		primaryDomain: domainList[0],
 		email:         email,
 		challengeType: ChallengeWebroot,
+		redirect:      true,
+		template:      config.GetWebServerConfig().Template,
+		upstream:      config.GetWebServerConfig().Upstream,
 		certDir:       config.GetCertDir(),
 		keySize:       2048,
 	}
@@ -116,6 +140,9 @@ func NewManagerWithDomains(domains []string, email string) *Manager {
 		primaryDomain: domains[0],
 		email:         email,
 		challengeType: ChallengeWebroot,
+		redirect:      true,
+		template:      config.GetWebServerConfig().Template,
+		upstream:      config.GetWebServerConfig().Upstream,
 		certDir:       config.GetCertDir(),
 		keySize:       2048,
 	}
@@ -160,6 +187,68 @@ func (m *Manager) SetWebServer(webServerType WebServerType) {
 	}
 }
 
+// SetRedirect 设置是否将 HTTP 重定向到 HTTPS
+func (m *Manager) SetRedirect(redirect bool) {
+	m.redirect = redirect
+}
+
+// SetTemplate 设置站点模板和上游地址，为空时保留配置文件中的值
+func (m *Manager) SetTemplate(template, upstream string) {
+	if template != "" {
+		m.template = template
+	}
+	if upstream != "" {
+		m.upstream = upstream
+	}
+}
+
+// SetSecurity 设置 TLS 安全配置和 HSTS，覆盖配置文件中的值
+func (m *Manager) SetSecurity(profile string, hsts bool) {
+	m.tlsProfile = profile
+	m.hsts = hsts
+}
+
+// securityConfig 合并配置文件和命令行中的 TLS 安全配置
+func (m *Manager) securityConfig() config.SecurityConfig {
+	security := config.GetWebServerConfig().Security
+	if certSecurity := config.GetCertificateConfig(m.getDirName()).Security; certSecurity != (config.SecurityConfig{}) {
+		security = certSecurity
+	}
+
+	if m.tlsProfile != "" {
+		security.Profile = m.tlsProfile
+	}
+	if m.hsts {
+		security.HSTS.Enabled = true
+	}
+	return security
+}
+
+// reloadConfig 获取 Web 服务器的重载方式，证书配置中的 reload 优先
+func (m *Manager) reloadConfig() config.ReloadConfig {
+	if certReload := config.GetCertificateConfig(m.getDirName()).Reload; certReload != (config.ReloadConfig{}) {
+		return certReload
+	}
+
+	return globalReloadConfig()
+}
+
+// globalReloadConfig 全局的 Web 服务器重载方式，reload_cmd 等同于 command 方式
+func globalReloadConfig() config.ReloadConfig {
+	webServer := config.GetWebServerConfig()
+	reload := webServer.Reload
+	if reload.Method == "" && webServer.ReloadCmd != "" {
+		reload.Method = webserver.ReloadCommand
+		reload.Command = webServer.ReloadCmd
+	}
+	return reload
+}
+
+// GetCertName 获取证书名称（证书目录名）
+func (m *Manager) GetCertName() string {
+	return m.getDirName()
+}
+
 // GetDomains 获取所有域名
 func (m *Manager) GetDomains() []string {
 	return m.domains
@@ -214,26 +303,75 @@ func (m *Manager) Install() error {
 		return fmt.Errorf("创建 CSR 失败: %w", err)
 	}
 
-	// 4. 通过 ACME 获取证书
-	certBytes, err := m.obtainCertificate(csr)
+	// 4-6. 在 pre 和 post 钩子之间准备挑战目录并通过 ACME 获取证书
+	chainPEM, err := m.obtainWithHooks(csr)
 	if err != nil {
-		return fmt.Errorf("获取证书失败: %w", err)
+		return err
 	}
 
-	// 5. 保存证书
-	if err := m.saveCertificate(certBytes); err != nil {
+	// 7. 保存证书
+	if err := m.saveCertificate(chainPEM); err != nil {
 		return fmt.Errorf("保存证书失败: %w", err)
 	}
 
-	// 6. 配置 Web 服务器
+	// 8. 生成额外的证书格式和 Kubernetes Secret 清单
+	m.writeOutputFormats()
+	m.writeKubernetesSecret()
+
+	// 9. 配置 Web 服务器
 	if err := m.configureWebServer(); err != nil {
 		return fmt.Errorf("配置 Web 服务器失败: %w", err)
 	}
 
+	// 10. 配置同样使用该证书的其他服务（邮件服务器等）
+	if err := m.configureServices(); err != nil {
+		return fmt.Errorf("配置服务失败: %w", err)
+	}
+
+	// 11. 部署到配置的目标位置
+	if err := m.lineage().Deploy(config.GetCertificateConfig(m.getDirName()).Deploy); err != nil {
+		logger.Error("部署证书失败", "domains", m.domains, "error", err)
+	}
+
+	// 12. 执行 deploy 钩子，证书已经生效，失败只记录错误
+	if err := m.runHooks(hook.EventDeploy); err != nil {
+		logger.Error("执行 deploy 钩子失败", "domains", m.domains, "error", err)
+	}
+
 	logger.Info("证书安装完成", "domains", m.domains)
 	return nil
 }
 
+// obtainWithHooks 执行 pre 钩子后申请证书，申请结束后立即执行 post 钩子（无论成功与否）
+// pre 钩子停止的服务（例如 standalone 模式下占用端口的 Web 服务器）在配置和验证 Web 服务器之前恢复
+func (m *Manager) obtainWithHooks(csr []byte) ([]byte, error) {
+	// 4. 执行 pre 钩子（例如 standalone 模式下停止占用端口的服务）
+	if err := m.runHooks(hook.EventPre); err != nil {
+		return nil, fmt.Errorf("执行 pre 钩子失败: %w", err)
+	}
+	defer func() {
+		if err := m.runHooks(hook.EventPost); err != nil {
+			logger.Error("执行 post 钩子失败", "domains", m.domains, "error", err)
+		}
+	}()
+
+	// 5. webroot 验证时确保 Web 服务器提供挑战目录
+	if m.challengeType == ChallengeWebroot {
+		webroot, err := m.prepareChallenge()
+		if err != nil {
+			return nil, fmt.Errorf("准备 ACME 挑战目录失败: %w", err)
+		}
+		m.httpWebroot = webroot
+	}
+
+	// 6. 通过 ACME 获取证书
+	chainPEM, err := m.obtainCertificate(csr)
+	if err != nil {
+		return nil, fmt.Errorf("获取证书失败: %w", err)
+	}
+	return chainPEM, nil
+}
+
 // Renew 续期证书
 func (m *Manager) Renew() error {
 	logger.Info("开始续期证书", "domains", m.domains)
@@ -272,28 +410,25 @@ func (m *Manager) GetCertInfo() (*CertInfo, error) {
 		return nil, fmt.Errorf("读取证书文件失败: %w", err)
 	}
 
-	// 解析证书
-	block, _ := pem.Decode(certData)
-	if block == nil {
-		return nil, fmt.Errorf("无法解析证书文件")
-	}
-
-	cert, err := x509.ParseCertificate(block.Bytes)
+	// 解析证书（叶子证书在最前面）
+	certs, err := parseCertificateChain(certData)
 	if err != nil {
-		return nil, fmt.Errorf("解析证书失败: %w", err)
+		return nil, err
 	}
+	cert := certs[0]
 
 	daysLeft := int(time.Until(cert.NotAfter).Hours() / 24)
 
 	return &CertInfo{
-		Domain:     m.primaryDomain,
-		Domains:    cert.DNSNames,
-		CertPath:   certPath,
-		KeyPath:    m.getKeyPath(),
-		ChainPath:  m.getChainPath(),
-		ExpiryDate: cert.NotAfter,
-		IsValid:    time.Now().Before(cert.NotAfter),
-		DaysLeft:   daysLeft,
+		Domain:        m.primaryDomain,
+		Domains:       cert.DNSNames,
+		CertPath:      certPath,
+		KeyPath:       m.getKeyPath(),
+		ChainPath:     m.getChainPath(),
+		FullchainPath: m.getFullchainPath(),
+		ExpiryDate:    cert.NotAfter,
+		IsValid:       time.Now().Before(cert.NotAfter),
+		DaysLeft:      daysLeft,
 	}, nil
 }
 
@@ -307,13 +442,21 @@ func (m *Manager) getDirName() string {
 	return m.primaryDomain
 }
 
+// lineage 获取证书目录
+func (m *Manager) lineage() *Lineage {
+	return &Lineage{
+		Name: m.getDirName(),
+		Dir:  filepath.Join(m.certDir, m.getDirName()),
+	}
+}
+
 // createCertDir 创建证书目录
 func (m *Manager) createCertDir() error {
-	certDir := filepath.Join(m.certDir, m.getDirName())
-	return os.MkdirAll(certDir, 0755)
+	return os.MkdirAll(m.lineage().Dir, 0755)
 }
 
 // generatePrivateKey 生成私钥
+// 私钥只保存在内存中，证书申请成功后与证书一起写入磁盘，避免申请失败时覆盖可用的旧私钥
 func (m *Manager) generatePrivateKey() (*rsa.PrivateKey, error) {
 	logger.Debug("生成私钥", "keySize", m.keySize)
 
@@ -322,30 +465,9 @@ func (m *Manager) generatePrivateKey() (*rsa.PrivateKey, error) {
 		return nil, err
 	}
 
-	// 保存私钥到文件
-	keyPath := m.getKeyPath()
-	keyFile, err := os.Create(keyPath)
-	if err != nil {
-		return nil, err
-	}
-	defer keyFile.Close()
-
-	keyBytes := x509.MarshalPKCS1PrivateKey(privateKey)
-	keyPEM := &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: keyBytes,
-	}
-
-	if err := pem.Encode(keyFile, keyPEM); err != nil {
-		return nil, err
-	}
-
-	// 设置私钥文件权限
-	if err := os.Chmod(keyPath, 0600); err != nil {
-		return nil, err
-	}
+	m.privateKey = privateKey
 
-	logger.Debug("私钥生成完成", "keyPath", keyPath)
+	logger.Debug("私钥生成完成")
 	return privateKey, nil
 }
 
@@ -389,13 +511,13 @@ func (m *Manager) obtainCertificate(csr []byte) ([]byte, error) {
 
 // obtainCertificateWebroot 使用 Webroot/HTTP 模式获取证书
 func (m *Manager) obtainCertificateWebroot(csr []byte) ([]byte, error) {
-	logger.Info("使用 HTTP-01 模式获取证书", "domains", m.domains, "webroot", m.webrootPath)
+	logger.Info("使用 HTTP-01 模式获取证书", "domains", m.domains, "webroot", m.httpWebroot)
 
 	if m.HasWildcard() {
 		return nil, fmt.Errorf("泛域名证书不能使用 HTTP 验证模式，请使用 DNS 验证")
 	}
 
-	return m.obtainWithACME(acme.ChallengeHTTP01)
+	return m.obtainWithACME(acme.ChallengeHTTP01, csr)
 }
 
 // obtainCertificateStandalone 使用 Standalone/TLS-ALPN 模式获取证书
@@ -406,7 +528,7 @@ func (m *Manager) obtainCertificateStandalone(csr []byte) ([]byte, error) {
 		return nil, fmt.Errorf("泛域名证书不能使用 TLS-ALPN 验证模式，请使用 DNS 验证")
 	}
 
-	return m.obtainWithACME(acme.ChallengeTLSALPN01)
+	return m.obtainWithACME(acme.ChallengeTLSALPN01, csr)
 }
 
 // obtainCertificateDNS 使用 DNS 模式获取证书
@@ -430,52 +552,55 @@ func (m *Manager) obtainCertificateDNS(csr []byte) ([]byte, error) {
 	return m.generateSelfSignedCert(csr)
 }
 
-// obtainWithACME 使用 ACME 客户端获取证书
-func (m *Manager) obtainWithACME(challengeType acme.ChallengeType) ([]byte, error) {
+// obtainWithACME 使用 ACME 客户端获取证书，返回叶子证书在前的 PEM 证书链
+func (m *Manager) obtainWithACME(challengeType acme.ChallengeType, csr []byte) ([]byte, error) {
 	// 创建 ACME 客户端
 	client, err := acme.NewClient(&acme.ClientConfig{
 		Email:     m.email,
 		ConfigDir: m.certDir,
 		Staging:   false, // 生产环境
-		Webroot:   m.webrootPath,
+		Webroot:   m.httpWebroot,
 	})
 	if err != nil {
-		logger.Warn("创建 ACME 客户端失败，使用自签名证书", "error", err)
-		return m.generateSelfSignedCert(nil)
+		return nil, fmt.Errorf("创建 ACME 客户端失败: %w", err)
 	}
 
 	// 设置挑战类型
 	switch challengeType {
 	case acme.ChallengeHTTP01:
 		if err := client.SetHTTPChallenge(); err != nil {
-			logger.Warn("设置 HTTP 挑战失败", "error", err)
-			return m.generateSelfSignedCert(nil)
+			return nil, fmt.Errorf("设置 HTTP 挑战失败: %w", err)
 		}
 	case acme.ChallengeTLSALPN01:
 		if err := client.SetTLSChallenge(); err != nil {
-			logger.Warn("设置 TLS-ALPN 挑战失败", "error", err)
-			return m.generateSelfSignedCert(nil)
+			return nil, fmt.Errorf("设置 TLS-ALPN 挑战失败: %w", err)
 		}
 	}
 
-	// 申请证书
-	cert, err := client.ObtainCertificate(m.domains)
+	// 使用本地生成的私钥和 CSR 申请证书，保证证书与 key.pem 匹配
+	csrParsed, err := x509.ParseCertificateRequest(csr)
+	if err != nil {
+		return nil, fmt.Errorf("解析 CSR 失败: %w", err)
+	}
+
+	cert, err := client.ObtainCertificateForCSR(csrParsed, m.privateKey)
 	if err != nil {
-		logger.Warn("ACME 证书申请失败，使用自签名证书", "error", err)
-		return m.generateSelfSignedCert(nil)
+		if challengeType == acme.ChallengeHTTP01 && m.httpWebroot == "" {
+			return nil, fmt.Errorf("ACME 证书申请失败（使用内置的 HTTP 服务器验证，需要 80 端口空闲；Web 服务器占用 80 端口时可以先运行 autocert challenge --include 或指定 --webroot）: %w", err)
+		}
+		return nil, fmt.Errorf("ACME 证书申请失败: %w", err)
 	}
 
-	// 保存证书到目录
-	certDir := filepath.Join(m.certDir, m.getDirName())
-	if err := client.SaveCertificate(cert, certDir); err != nil {
-		return nil, fmt.Errorf("保存证书失败: %w", err)
+	// 保存证书元数据
+	if err := client.SaveMetadata(cert, m.lineage().Dir); err != nil {
+		logger.Warn("保存证书元数据失败", "error", err)
 	}
 
-	// 返回证书内容（用于后续处理）
+	// 申请时启用了 Bundle，Certificate 中已包含叶子证书和中间证书
 	return cert.Certificate, nil
 }
 
-// generateSelfSignedCert 生成自签名证书（仅用于演示或回退）
+// generateSelfSignedCert 生成自签名证书（仅用于演示或回退），返回 PEM 格式证书
 func (m *Manager) generateSelfSignedCert(csr []byte) ([]byte, error) {
 	logger.Warn("生成自签名证书（仅用于演示）", "domains", m.domains)
 
@@ -497,49 +622,61 @@ func (m *Manager) generateSelfSignedCert(csr []byte) ([]byte, error) {
 		dnsNames = m.domains
 	}
 
-	template := x509.Certificate{
-		Subject:     subject,
-		DNSNames:    dnsNames,
-		NotBefore:   time.Now(),
-		NotAfter:    time.Now().Add(90 * 24 * time.Hour),
-		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-	}
-
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
 	if err != nil {
 		return nil, err
 	}
 
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      subject,
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	// 使用与 key.pem 相同的私钥签名，保证证书与私钥匹配
+	privateKey := m.privateKey
+	if privateKey == nil {
+		if privateKey, err = m.generatePrivateKey(); err != nil {
+			return nil, err
+		}
+	}
+
 	certBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
 	if err != nil {
 		return nil, err
 	}
 
-	return certBytes, nil
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}), nil
 }
 
 // saveCertificate 保存证书
-func (m *Manager) saveCertificate(certBytes []byte) error {
+// 按 Lineage 布局写入叶子证书、中间证书链、完整链、私钥和组合文件，并校验写入的证书链
+func (m *Manager) saveCertificate(chainPEM []byte) error {
 	logger.Debug("保存证书", "domains", m.domains)
 
-	// 保存证书
-	certPath := m.getCertPath()
-	certFile, err := os.Create(certPath)
-	if err != nil {
-		return err
+	if m.privateKey == nil {
+		return fmt.Errorf("私钥尚未生成")
 	}
-	defer certFile.Close()
 
-	certPEM := &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: certBytes,
-	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(m.privateKey),
+	})
 
-	if err := pem.Encode(certFile, certPEM); err != nil {
+	l := m.lineage()
+	if err := l.Write(chainPEM, keyPEM); err != nil {
 		return err
 	}
 
+	// 重新读取磁盘上的文件进行校验
+	if err := l.Verify(); err != nil {
+		return fmt.Errorf("写入的证书链校验失败: %w", err)
+	}
+
 	// 如果是多域名证书，保存域名列表
 	if len(m.domains) > 1 {
 		domainsFile := filepath.Join(m.certDir, m.getDirName(), "domains.txt")
@@ -548,10 +685,35 @@ func (m *Manager) saveCertificate(certBytes []byte) error {
 		}
 	}
 
-	logger.Debug("证书保存完成", "certPath", certPath)
+	logger.Debug("证书保存完成", "fullchainPath", l.FullchainPath())
 	return nil
 }
 
+// writeOutputFormats 按证书配置生成额外格式（PKCS#12、DER、JKS 等）
+// 证书已经保存成功，格式生成失败只记录错误，不影响后续配置
+func (m *Manager) writeOutputFormats() {
+	certConfig := config.GetCertificateConfig(m.getDirName())
+	if len(certConfig.Formats) == 0 {
+		return
+	}
+
+	if err := m.lineage().ConvertAll(certConfig.Formats); err != nil {
+		logger.Error("生成额外证书格式失败", "certName", m.getDirName(), "error", err)
+	}
+}
+
+// writeKubernetesSecret 按证书配置生成 Kubernetes TLS Secret 清单
+func (m *Manager) writeKubernetesSecret() {
+	k8sConfig := config.GetCertificateConfig(m.getDirName()).Kubernetes
+	if k8sConfig.Output == "" {
+		return
+	}
+
+	if err := m.lineage().WriteKubernetesSecret(k8sConfig, k8sConfig.Output); err != nil {
+		logger.Error("生成 Kubernetes Secret 清单失败", "certName", m.getDirName(), "error", err)
+	}
+}
+
 // configureWebServer 配置 Web 服务器
 func (m *Manager) configureWebServer() error {
 	if m.configurator == nil {
@@ -561,42 +723,156 @@ func (m *Manager) configureWebServer() error {
 
 	logger.Info("配置 Web 服务器", "type", m.webServerType.String(), "domains", m.domains)
 
-	// 使用 webserver 包的配置器
-	cfg := &webserver.Config{
-		Type:     m.webServerType.String(),
-		Domain:   strings.Join(m.domains, " "), // Nginx server_name 支持多域名
-		CertPath: m.getCertPath(),
-		KeyPath:  m.getKeyPath(),
-		WebRoot:  m.webrootPath,
+	if err := m.applyConfigurator(m.configurator, m.webServerConfig()); err != nil {
+		return err
 	}
 
-	if err := m.configurator.Configure(cfg); err != nil {
+	// 验证服务器实际提供的是新证书
+	// 失败时只回滚 Web 服务器配置，证书目录中已写入的新证书保留
+	if err := m.verifyServedCertificate(); err != nil {
+		err = rollbackConfigurator(m.configurator, fmt.Errorf("证书验证失败: %w", err), true)
+		m.notify(notify.EventVerifyFailed, "证书部署验证失败", err)
 		return err
 	}
 
+	logger.Info("Web 服务器配置完成")
+	return nil
+}
+
+// applyConfigurator 写入配置、测试并重载，失败时回滚
+func (m *Manager) applyConfigurator(c webserver.Configurator, cfg *webserver.Config) error {
+	if err := c.Configure(cfg); err != nil {
+		return rollbackConfigurator(c, err, false)
+	}
+
 	// 测试配置
-	if err := m.configurator.Test(); err != nil {
-		return fmt.Errorf("配置测试失败: %w", err)
+	if err := c.Test(); err != nil {
+		return rollbackConfigurator(c, fmt.Errorf("配置测试失败: %w", err), false)
 	}
 
 	// 重载配置
-	if err := m.configurator.Reload(); err != nil {
-		return fmt.Errorf("重载配置失败: %w", err)
+	if err := c.Reload(); err != nil {
+		return rollbackConfigurator(c, fmt.Errorf("重载配置失败: %w", err), true)
 	}
-
-	logger.Info("Web 服务器配置完成")
 	return nil
 }
 
+// Plan 计算安装时 Web 服务器和其他服务配置将执行的修改，不申请证书也不写入任何文件
+func (m *Manager) Plan() (*webserver.Plan, error) {
+	services := m.serviceNames()
+	if m.configurator == nil && len(services) == 0 {
+		return nil, fmt.Errorf("未设置 Web 服务器配置器")
+	}
+
+	plan := &webserver.Plan{}
+	if m.configurator != nil {
+		challengePlan, err := m.planChallenge()
+		if err != nil {
+			return nil, err
+		}
+		plan.Merge(challengePlan)
+
+		webPlan, err := m.configurator.Plan(m.webServerConfig())
+		if err != nil {
+			return nil, err
+		}
+		plan.Merge(webPlan)
+
+		if verifyConfig := config.GetWebServerConfig().Verify; verifyConfig.Enabled && m.webServerType != WebServerIIS {
+			address := verifyConfig.Address
+			if address == "" {
+				address = DefaultVerifyAddress
+			}
+			plan.Commands = append(plan.Commands, fmt.Sprintf("# 验证 %s 上 %s 的证书", address, strings.Join(m.domains, ", ")))
+		}
+	}
+
+	servicePlan, err := m.planServices(services)
+	if err != nil {
+		return nil, err
+	}
+	plan.Merge(servicePlan)
+	return plan, nil
+}
+
+// webServerConfig 生成 Web 服务器配置
+// Web 服务器始终引用完整证书链，避免客户端缺少中间证书
+func (m *Manager) webServerConfig() *webserver.Config {
+	return &webserver.Config{
+		Type:          m.webServerType.String(),
+		Name:          m.getDirName(),
+		Domain:        strings.Join(m.domains, " "), // Nginx server_name 支持多域名
+		CertPath:      m.getCertPath(),
+		KeyPath:       m.getKeyPath(),
+		ChainPath:     m.getChainPath(),
+		FullchainPath: m.getFullchainPath(),
+		CombinedPath:  m.lineage().CombinedPath(),
+		ConfigPath:    config.GetWebServerConfig().ConfigPath,
+		Binary:        config.GetWebServerConfig().Binary,
+		WebRoot:       m.webrootPath,
+		ChallengeDir:  m.challengeDir(),
+		Redirect:      m.redirect,
+		Template:      m.template,
+		Upstream:      m.upstream,
+		Reload:        m.reloadConfig(),
+		Security:      m.securityConfig(),
+		HAProxy:       config.GetWebServerConfig().HAProxy,
+		Traefik:       config.GetWebServerConfig().Traefik,
+	}
+}
+
+// notify 发送通知，失败只记录错误
+func (m *Manager) notify(event notify.Event, subject string, cause error) {
+	err := notify.Send(notify.Message{
+		Event:   event,
+		Subject: fmt.Sprintf("[AutoCert] %s: %s", subject, m.primaryDomain),
+		Body:    cause.Error(),
+		Domains: m.domains,
+	})
+	if err != nil {
+		logger.Error("发送通知失败", "event", string(event), "error", err)
+	}
+}
+
+// rollbackConfigurator 撤销配置器写入的文件，避免错误的配置影响后续其他站点的重载
+// reload 为 true 时表示新配置已经尝试加载，恢复后需要重新加载旧配置
+func rollbackConfigurator(c webserver.Configurator, cause error, reload bool) error {
+	reverted, err := c.Rollback()
+	for _, r := range reverted {
+		logger.Warn("已回滚 Web 服务器配置", "change", r)
+	}
+	if err != nil {
+		logger.Error("回滚 Web 服务器配置失败", "error", err)
+		return fmt.Errorf("%w（回滚失败: %v）", cause, err)
+	}
+	if len(reverted) == 0 {
+		return cause
+	}
+
+	if reload {
+		if err := c.Test(); err != nil {
+			logger.Error("回滚后的配置测试失败", "error", err)
+		} else if err := c.Reload(); err != nil {
+			logger.Error("回滚后重载 Web 服务器失败", "error", err)
+		}
+	}
+
+	return fmt.Errorf("%w（已回滚: %s）", cause, strings.Join(reverted, "; "))
+}
+
 // 路径辅助方法
 func (m *Manager) getCertPath() string {
-	return filepath.Join(m.certDir, m.getDirName(), "cert.pem")
+	return m.lineage().CertPath()
 }
 
 func (m *Manager) getKeyPath() string {
-	return filepath.Join(m.certDir, m.getDirName(), "key.pem")
+	return m.lineage().KeyPath()
 }
 
 func (m *Manager) getChainPath() string {
-	return filepath.Join(m.certDir, m.getDirName(), "chain.pem")
+	return m.lineage().ChainPath()
+}
+
+func (m *Manager) getFullchainPath() string {
+	return m.lineage().FullchainPath()
 }
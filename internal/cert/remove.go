@@ -0,0 +1,168 @@
+package cert
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"autocert/internal/webserver"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RemoveOptions 删除证书的选项
+type RemoveOptions struct {
+	WebServer WebServerType // 删除其中该证书配置的 Web 服务器
+	Site      bool          // 是否删除 Web 服务器配置，为 false 时只删除证书文件
+	KeepCert  bool          // 只删除 Web 服务器配置，保留证书目录
+}
+
+// RemoveCertificate 删除证书：先删除 Web 服务器中为证书生成的配置，测试并重载成功后再删除证书目录
+// Web 服务器配置修改失败时回滚，证书目录保持不变
+func RemoveCertificate(name string, opts RemoveOptions) error {
+	lineage, err := OpenLineage(name)
+	if err != nil {
+		return err
+	}
+
+	if opts.Site {
+		c, cfg, err := siteRemover(lineage, opts.WebServer)
+		if err != nil {
+			return err
+		}
+		if err := removeSite(c, cfg); err != nil {
+			return fmt.Errorf("删除 Web 服务器配置失败: %w", err)
+		}
+	}
+
+	if opts.KeepCert {
+		return nil
+	}
+	return lineage.Remove()
+}
+
+// PlanRemoveCertificate 计算 RemoveCertificate 将执行的修改
+func PlanRemoveCertificate(name string, opts RemoveOptions) (*webserver.Plan, error) {
+	lineage, err := OpenLineage(name)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &webserver.Plan{}
+	if opts.Site {
+		c, cfg, err := siteRemover(lineage, opts.WebServer)
+		if err != nil {
+			return nil, err
+		}
+		sitePlan, err := c.PlanRemoveSite(cfg)
+		if err != nil {
+			return nil, err
+		}
+		plan.Merge(sitePlan)
+	}
+	if !opts.KeepCert {
+		plan.Removed = append(plan.Removed, lineage.Dir)
+	}
+	return plan, nil
+}
+
+// siteRemover 创建配置器和证书对应的 Web 服务器配置
+func siteRemover(lineage *Lineage, webServerType WebServerType) (webserver.SiteRemover, *webserver.Config, error) {
+	domains, err := lineage.domains()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configurator, err := webserver.NewConfigurator(webServerType.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	c, ok := configurator.(webserver.SiteRemover)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s 不支持删除证书配置", webServerType)
+	}
+
+	webServer := config.GetWebServerConfig()
+	reload := config.GetCertificateConfig(lineage.Name).Reload
+	if reload == (config.ReloadConfig{}) {
+		reload = globalReloadConfig()
+	}
+	return c, &webserver.Config{
+		Type:          webServerType.String(),
+		Name:          lineage.Name,
+		Domain:        strings.Join(domains, " "),
+		CertPath:      lineage.CertPath(),
+		KeyPath:       lineage.KeyPath(),
+		ChainPath:     lineage.ChainPath(),
+		FullchainPath: lineage.FullchainPath(),
+		CombinedPath:  lineage.CombinedPath(),
+		ConfigPath:    webServer.ConfigPath,
+		Binary:        webServer.Binary,
+		Reload:        reload,
+		HAProxy:       webServer.HAProxy,
+		Traefik:       webServer.Traefik,
+	}, nil
+}
+
+// removeSite 删除 Web 服务器配置，有修改时测试并重载，失败时回滚
+func removeSite(c webserver.SiteRemover, cfg *webserver.Config) error {
+	changed, err := c.RemoveSite(cfg)
+	if err != nil {
+		return rollbackConfigurator(c, err, false)
+	}
+	if !changed {
+		logger.Info("Web 服务器中没有该证书的配置", "certName", cfg.Name, "webServer", cfg.Type)
+		return nil
+	}
+	if err := c.Test(); err != nil {
+		return rollbackConfigurator(c, fmt.Errorf("配置测试失败: %w", err), false)
+	}
+	if err := c.Reload(); err != nil {
+		return rollbackConfigurator(c, fmt.Errorf("重载配置失败: %w", err), true)
+	}
+	logger.Info("已删除 Web 服务器中的证书配置", "certName", cfg.Name, "webServer", cfg.Type)
+	return nil
+}
+
+// domains 证书中的域名，主域名（证书名称去掉 _san 后缀）排在最前
+func (l *Lineage) domains() ([]string, error) {
+	certs, err := l.LoadCertificates()
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 || len(certs[0].DNSNames) == 0 {
+		return nil, fmt.Errorf("证书 %s 中没有域名", l.Name)
+	}
+
+	primary := strings.TrimSuffix(l.Name, "_san")
+	var domains, others []string
+	for _, d := range certs[0].DNSNames {
+		if d == primary {
+			domains = append(domains, d)
+		} else {
+			others = append(others, d)
+		}
+	}
+	return append(domains, others...), nil
+}
+
+// Remove 删除证书目录，目录必须位于证书目录下
+func (l *Lineage) Remove() error {
+	certDir, err := filepath.Abs(config.GetCertDir())
+	if err != nil {
+		return fmt.Errorf("解析证书目录失败: %w", err)
+	}
+	dir, err := filepath.Abs(l.Dir)
+	if err != nil {
+		return fmt.Errorf("解析证书目录失败: %w", err)
+	}
+	if rel, err := filepath.Rel(certDir, dir); err != nil || rel == "." || rel == ".." || strings.ContainsRune(rel, filepath.Separator) {
+		return fmt.Errorf("拒绝删除证书目录之外的路径: %s", l.Dir)
+	}
+
+	if err := os.RemoveAll(l.Dir); err != nil {
+		return fmt.Errorf("删除证书目录失败: %w", err)
+	}
+	logger.Info("已删除证书", "certName", l.Name, "dir", l.Dir)
+	return nil
+}
@@ -0,0 +1,96 @@
+package cert
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"autocert/internal/webserver"
+	"fmt"
+	"strings"
+)
+
+// 支持的其他服务
+const (
+	ServicePostfix = "postfix"
+	ServiceDovecot = "dovecot"
+)
+
+// SetServices 设置 Web 服务器之外同样使用该证书的服务
+func (m *Manager) SetServices(services []string) {
+	m.services = services
+}
+
+// serviceNames 合并命令行和证书配置中的服务，去重并保持顺序
+func (m *Manager) serviceNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, list := range [][]string{m.services, config.GetCertificateConfig(m.getDirName()).Services} {
+		for _, name := range list {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// serviceConfig 生成服务配置
+// 证书的 reload 配置针对 Web 服务器，其他服务使用各自默认的测试和重载命令
+func (m *Manager) serviceConfig(name string) *webserver.Config {
+	return &webserver.Config{
+		Type:          name,
+		Name:          m.getDirName(),
+		Domain:        strings.Join(m.domains, " "),
+		CertPath:      m.getCertPath(),
+		KeyPath:       m.getKeyPath(),
+		ChainPath:     m.getChainPath(),
+		FullchainPath: m.getFullchainPath(),
+		CombinedPath:  m.lineage().CombinedPath(),
+	}
+}
+
+// newServiceConfigurator 创建服务配置器，只接受邮件服务器等非 Web 服务
+func newServiceConfigurator(name string) (webserver.Configurator, error) {
+	switch name {
+	case ServicePostfix, ServiceDovecot:
+		return webserver.NewConfigurator(name)
+	default:
+		return nil, fmt.Errorf("不支持的服务: %s（支持 %s, %s）", name, ServicePostfix, ServiceDovecot)
+	}
+}
+
+// configureServices 依次配置其他服务，每个服务失败时只回滚该服务
+func (m *Manager) configureServices() error {
+	for _, name := range m.serviceNames() {
+		c, err := newServiceConfigurator(name)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("配置服务", "service", name, "domains", m.domains)
+		if err := m.applyConfigurator(c, m.serviceConfig(name)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		logger.Info("服务配置完成", "service", name)
+	}
+	return nil
+}
+
+// planServices 计算其他服务配置将执行的修改
+func (m *Manager) planServices(names []string) (*webserver.Plan, error) {
+	plan := &webserver.Plan{}
+	for _, name := range names {
+		c, err := newServiceConfigurator(name)
+		if err != nil {
+			return nil, err
+		}
+		servicePlan, err := c.Plan(m.serviceConfig(name))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		plan.Merge(servicePlan)
+	}
+	return plan, nil
+}
@@ -0,0 +1,192 @@
+package cert
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// 部署验证的默认值
+const (
+	DefaultVerifyAddress = "127.0.0.1:443"
+	DefaultVerifyTimeout = 10 * time.Second
+)
+
+// wildcardProbeLabel 验证泛域名证书时替换 * 的标签
+const wildcardProbeLabel = "autocert-verify"
+
+// servedCertVerifier 检查服务器实际提供的证书
+type servedCertVerifier struct {
+	address  string              // 连接地址 host:port
+	expected []*x509.Certificate // 刚安装的证书链，叶子证书在前
+	roots    *x509.CertPool      // 信任的根证书，为空时使用系统根证书
+	timeout  time.Duration       // 等待服务器提供新证书的最长时间
+}
+
+// verify 依次以每个域名作为 SNI 连接服务器，检查叶子证书序列号和证书链
+// 重载后工作进程可能还在切换，验证失败时在超时时间内重试
+func (v *servedCertVerifier) verify(domains []string) error {
+	timeout := v.timeout
+	if timeout <= 0 {
+		timeout = DefaultVerifyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for _, domain := range domains {
+		for {
+			err := v.verifyDomain(domain)
+			if err == nil {
+				logger.Info("证书验证通过", "domain", domain, "address", v.address)
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%s: %w", domain, err)
+			}
+			logger.Debug("证书验证未通过，稍后重试", "domain", domain, "error", err)
+			time.Sleep(time.Second)
+		}
+	}
+	return nil
+}
+
+// verifyDomain 检查一个域名
+func (v *servedCertVerifier) verifyDomain(domain string) error {
+	serverName := domain
+	if strings.HasPrefix(serverName, "*.") {
+		serverName = wildcardProbeLabel + serverName[1:]
+	}
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", v.address, &tls.Config{
+		ServerName: serverName,
+		// 证书链在下面单独校验，以便区分序列号不一致和证书链问题
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return fmt.Errorf("连接 %s 失败: %w", v.address, err)
+	}
+	served := conn.ConnectionState().PeerCertificates
+	conn.Close()
+
+	if len(served) == 0 {
+		return fmt.Errorf("服务器没有提供证书")
+	}
+
+	leaf, expected := served[0], v.expected[0]
+	if leaf.SerialNumber.Cmp(expected.SerialNumber) != 0 {
+		return fmt.Errorf("服务器提供的证书序列号为 %s，期望 %s（服务器可能仍在使用旧证书）",
+			leaf.SerialNumber.Text(16), expected.SerialNumber.Text(16))
+	}
+	if !bytes.Equal(leaf.Raw, expected.Raw) {
+		return fmt.Errorf("服务器提供的证书与安装的证书序列号相同但内容不同")
+	}
+
+	return v.verifyChain(served, serverName)
+}
+
+// verifyChain 校验服务器发送的证书链
+// 签发机构在本机不受信任时（测试环境、自签名证书），只检查服务器发送了全部中间证书
+func (v *servedCertVerifier) verifyChain(served []*x509.Certificate, serverName string) error {
+	opts := x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         v.roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, c := range served[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+
+	_, err := served[0].Verify(opts)
+	if err == nil {
+		return nil
+	}
+
+	// 用安装的完整链再校验一次，判断是服务器的问题还是签发机构本身不受信任
+	expectedOpts := opts
+	expectedOpts.Intermediates = x509.NewCertPool()
+	for _, c := range v.expected[1:] {
+		expectedOpts.Intermediates.AddCert(c)
+	}
+	if _, expectedErr := v.expected[0].Verify(expectedOpts); expectedErr == nil {
+		return fmt.Errorf("证书链校验失败（服务器可能没有发送中间证书）: %w", err)
+	}
+
+	for _, want := range v.expected[1:] {
+		found := false
+		for _, c := range served[1:] {
+			if bytes.Equal(c.Raw, want.Raw) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("服务器没有发送中间证书 %s", want.Subject.CommonName)
+		}
+	}
+	logger.Debug("签发机构在本机不受信任，只检查中间证书是否完整", "issuer", served[0].Issuer.CommonName)
+	return nil
+}
+
+// verifyServedCertificate 重载后验证服务器提供的是刚安装的证书
+func (m *Manager) verifyServedCertificate() error {
+	verifyConfig := config.GetWebServerConfig().Verify
+	if !verifyConfig.Enabled {
+		return nil
+	}
+	// IIS 配置器目前不绑定证书，服务器不会提供新证书
+	if m.webServerType == WebServerIIS {
+		logger.Debug("IIS 不支持部署验证，跳过")
+		return nil
+	}
+
+	certs, err := m.lineage().LoadCertificates()
+	if err != nil {
+		return fmt.Errorf("读取证书失败: %w", err)
+	}
+
+	roots, err := verifyRoots(verifyConfig.RootCA)
+	if err != nil {
+		return err
+	}
+
+	address := verifyConfig.Address
+	if address == "" {
+		address = DefaultVerifyAddress
+	}
+
+	verifier := &servedCertVerifier{
+		address:  address,
+		expected: certs,
+		roots:    roots,
+		timeout:  verifyConfig.Timeout,
+	}
+	return verifier.verify(m.domains)
+}
+
+// verifyRoots 系统根证书加上额外配置的根证书
+func verifyRoots(rootCA string) (*x509.CertPool, error) {
+	if rootCA == "" {
+		return nil, nil
+	}
+
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+
+	data, err := os.ReadFile(rootCA)
+	if err != nil {
+		return nil, fmt.Errorf("读取根证书失败: %w", err)
+	}
+	if !roots.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("根证书文件 %s 中没有有效的证书", rootCA)
+	}
+	return roots, nil
+}
@@ -0,0 +1,206 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testChain 测试用的根证书、中间证书和叶子证书
+type testChain struct {
+	root         *x509.Certificate
+	intermediate *x509.Certificate
+	leaf         *x509.Certificate
+	leafKey      *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// newTestChain 签发一条三级证书链，叶子证书包含 dnsNames
+func newTestChain(t *testing.T, serial int64, dnsNames ...string) *testChain {
+	t.Helper()
+	notBefore, notAfter := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	caTemplate := func(serial int64, name string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             notBefore,
+			NotAfter:              notAfter,
+			KeyUsage:              x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+	}
+
+	root, rootKey := newTestCert(t, caTemplate(1, "Test Root"), nil, nil)
+	intermediate, intermediateKey := newTestCert(t, caTemplate(2, "Test Intermediate"), root, rootKey)
+	leaf, leafKey := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, intermediate, intermediateKey)
+
+	return &testChain{root: root, intermediate: intermediate, leaf: leaf, leafKey: leafKey}
+}
+
+// expected 安装的证书链，叶子证书在前
+func (c *testChain) expected() []*x509.Certificate {
+	return []*x509.Certificate{c.leaf, c.intermediate}
+}
+
+func (c *testChain) roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.root)
+	return pool
+}
+
+// startTLSServer 启动提供 served 证书链的 TLS 服务器，返回地址和收到的 SNI
+func startTLSServer(t *testing.T, served []*x509.Certificate, key *ecdsa.PrivateKey) (string, func() []string) {
+	t.Helper()
+	cert := tls.Certificate{PrivateKey: key}
+	for _, c := range served {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+
+	var mu sync.Mutex
+	var names []string
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			mu.Lock()
+			names = append(names, hello.ServerName)
+			mu.Unlock()
+			return &cert, nil
+		},
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server.Listener.Addr().String(), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), names...)
+	}
+}
+
+func TestServedCertVerifier(t *testing.T) {
+	installed := newTestChain(t, 100, "example.com", "*.example.com")
+	old := newTestChain(t, 99, "example.com", "*.example.com")
+
+	tests := []struct {
+		name    string
+		served  []*x509.Certificate
+		key     *ecdsa.PrivateKey
+		trusted bool   // 是否信任测试根证书
+		wantErr string // 为空时期望验证通过
+	}{
+		{
+			name:    "matching serial",
+			served:  installed.expected(),
+			key:     installed.leafKey,
+			trusted: true,
+		},
+		{
+			name:   "matching serial with untrusted issuer",
+			served: installed.expected(),
+			key:    installed.leafKey,
+		},
+		{
+			name:    "mismatched serial",
+			served:  old.expected(),
+			key:     old.leafKey,
+			trusted: true,
+			wantErr: "序列号为 63，期望 64",
+		},
+		{
+			name:    "missing intermediate",
+			served:  []*x509.Certificate{installed.leaf},
+			key:     installed.leafKey,
+			trusted: true,
+			wantErr: "服务器可能没有发送中间证书",
+		},
+		{
+			name:    "missing intermediate with untrusted issuer",
+			served:  []*x509.Certificate{installed.leaf},
+			key:     installed.leafKey,
+			wantErr: "服务器没有发送中间证书 Test Intermediate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, _ := startTLSServer(t, tt.served, tt.key)
+			verifier := &servedCertVerifier{
+				address:  address,
+				expected: installed.expected(),
+				timeout:  time.Millisecond,
+			}
+			if tt.trusted {
+				verifier.roots = installed.roots()
+			}
+
+			err := verifier.verify([]string{"example.com"})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("verify: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServedCertVerifierWildcardProbe(t *testing.T) {
+	installed := newTestChain(t, 100, "example.com", "*.example.com")
+	address, serverNames := startTLSServer(t, installed.expected(), installed.leafKey)
+
+	verifier := &servedCertVerifier{
+		address:  address,
+		expected: installed.expected(),
+		roots:    installed.roots(),
+		timeout:  time.Millisecond,
+	}
+	if err := verifier.verify([]string{"*.example.com", "example.com"}); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	got := strings.Join(serverNames(), ",")
+	if want := wildcardProbeLabel + ".example.com,example.com"; got != want {
+		t.Errorf("SNI = %s, want %s", got, want)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -24,6 +25,12 @@ type Config struct {
 
 	// Web 服务器配置
 	WebServer WebServerConfig `mapstructure:"webserver"`
+
+	// 全局钩子，对所有证书生效
+	Hooks HooksConfig `mapstructure:"hooks"`
+
+	// 单个证书的配置
+	Certificates []CertificateConfig `mapstructure:"certificates"`
 }
 
 // ACMEConfig ACME 相关配置
@@ -32,6 +39,7 @@ type ACMEConfig struct {
 	Email   string `mapstructure:"email"`    // 邮箱地址
 	KeyType string `mapstructure:"key_type"` // 密钥类型
 	KeySize int    `mapstructure:"key_size"` // 密钥大小
+	Webroot string `mapstructure:"webroot"`  // HTTP-01 验证文件目录，所有站点通过共享的挑战配置访问
 }
 
 // NotificationConfig 通知配置
@@ -52,9 +60,140 @@ type EmailConfig struct {
 
 // WebServerConfig Web 服务器配置
 type WebServerConfig struct {
-	Type       string `mapstructure:"type"`        // nginx, apache, iis
-	ConfigPath string `mapstructure:"config_path"` // 配置文件路径
-	ReloadCmd  string `mapstructure:"reload_cmd"`  // 重载命令
+	Type       string `mapstructure:"type"`        // nginx, apache, haproxy, traefik, lighttpd, iis
+	ConfigPath string `mapstructure:"config_path"` // 主配置文件路径，为空时自动查找（nginx、lighttpd）
+	Binary     string `mapstructure:"binary"`      // 可执行文件路径，OpenResty、Tengine 等安装在非标准前缀时设置
+	ReloadCmd  string `mapstructure:"reload_cmd"`  // 重载命令，等同于 reload.method: command
+	Template   string `mapstructure:"template"`    // 站点模板：static, php, proxy, redirect 或自定义模板文件路径
+	Upstream   string `mapstructure:"upstream"`    // 模板使用的上游地址
+
+	Reload   ReloadConfig   `mapstructure:"reload"`   // 重载方式
+	Verify   VerifyConfig   `mapstructure:"verify"`   // 重载后验证实际提供的证书
+	Security SecurityConfig `mapstructure:"security"` // TLS 安全配置
+	HAProxy  HAProxyConfig  `mapstructure:"haproxy"`  // HAProxy 专用配置
+	Traefik  TraefikConfig  `mapstructure:"traefik"`  // Traefik 专用配置
+}
+
+// TraefikConfig Traefik 文件提供者配置
+type TraefikConfig struct {
+	DynamicFile string `mapstructure:"dynamic_file"` // 动态配置文件（.yml/.yaml/.toml），默认 /etc/traefik/dynamic/autocert.yml
+}
+
+// HAProxyConfig HAProxy 证书配置
+// 不设置时根据 haproxy.cfg 中 ssl bind 的 crt/crt-list 参数确定
+type HAProxyConfig struct {
+	ConfigPath   string `mapstructure:"config_path"`   // haproxy.cfg 路径，默认 /etc/haproxy/haproxy.cfg
+	CertDir      string `mapstructure:"cert_dir"`      // 证书目录（bind ... crt <dir>），默认 /etc/haproxy/certs
+	CrtList      string `mapstructure:"crt_list"`      // crt-list 文件，设置后在其中登记证书
+	MasterSocket string `mapstructure:"master_socket"` // master CLI socket（haproxy -S），默认 /run/haproxy-master.sock
+}
+
+// VerifyConfig 重载后的证书验证配置
+type VerifyConfig struct {
+	Enabled bool          `mapstructure:"enabled"` // 默认关闭，地址与 Web 服务器监听的地址一致时再开启
+	Address string        `mapstructure:"address"` // 连接地址 host:port，默认 127.0.0.1:443
+	Timeout time.Duration `mapstructure:"timeout"` // 等待服务器提供新证书的时间，默认 10 秒
+	RootCA  string        `mapstructure:"root_ca"` // 额外信任的根证书（PEM），用于私有 CA
+}
+
+// ReloadConfig Web 服务器重载方式
+type ReloadConfig struct {
+	Method      string        `mapstructure:"method"`       // command, systemd, signal, docker，默认根据 Web 服务器自动选择
+	Command     string        `mapstructure:"command"`      // command：执行的命令；docker：在容器中执行的命令
+	Unit        string        `mapstructure:"unit"`         // systemd 服务名，默认 nginx、apache2 或 httpd
+	PIDFile     string        `mapstructure:"pid_file"`     // signal：主进程的 PID 文件
+	Signal      string        `mapstructure:"signal"`       // signal、docker：发送的信号，默认 HUP
+	Container   string        `mapstructure:"container"`    // docker：容器名称或 ID
+	Timeout     time.Duration `mapstructure:"timeout"`      // 重载和健康检查的超时时间，默认 30 秒
+	HealthCheck string        `mapstructure:"health_check"` // 重载后的健康检查：http(s):// URL 或 host:port
+}
+
+// SecurityConfig TLS 安全配置
+type SecurityConfig struct {
+	Profile        string        `mapstructure:"profile"`         // Mozilla 安全配置：modern, intermediate（默认）, old
+	HSTS           HSTSConfig    `mapstructure:"hsts"`            // HTTP 严格传输安全
+	OCSPStapling   bool          `mapstructure:"ocsp_stapling"`   // 启用 OCSP Stapling
+	Resolver       string        `mapstructure:"resolver"`        // OCSP Stapling 使用的 DNS 服务器（Nginx）
+	SessionTimeout time.Duration `mapstructure:"session_timeout"` // TLS 会话超时时间，默认 1 天
+	SessionTickets bool          `mapstructure:"session_tickets"` // 启用 TLS 会话票据，默认关闭
+}
+
+// HSTSConfig HTTP 严格传输安全配置
+type HSTSConfig struct {
+	Enabled           bool          `mapstructure:"enabled"`
+	MaxAge            time.Duration `mapstructure:"max_age"`            // 默认 2 年
+	IncludeSubDomains bool          `mapstructure:"include_subdomains"` // 同时作用于所有子域名
+	Preload           bool          `mapstructure:"preload"`            // 申请加入浏览器预加载列表
+}
+
+// CertificateConfig 单个证书（证书目录）的配置
+type CertificateConfig struct {
+	Name    string               `mapstructure:"name"`    // 证书名称，即证书目录名
+	Formats []OutputFormatConfig `mapstructure:"formats"` // 额外输出格式
+	Hooks   HooksConfig          `mapstructure:"hooks"`   // 证书专属钩子，在全局钩子之后执行
+	Deploy  []DeployTargetConfig `mapstructure:"deploy"`  // 部署目标
+
+	// TLS 安全配置，设置后整体替换全局的 webserver.security
+	Security SecurityConfig `mapstructure:"security"`
+
+	// 重载方式，设置后整体替换全局的 webserver.reload
+	Reload ReloadConfig `mapstructure:"reload"`
+
+	// Web 服务器之外同样使用该证书的服务：postfix, dovecot
+	Services []string `mapstructure:"services"`
+
+	Kubernetes KubernetesConfig `mapstructure:"kubernetes"` // Kubernetes TLS Secret 输出
+}
+
+// KubernetesConfig Kubernetes TLS Secret 输出配置
+type KubernetesConfig struct {
+	Namespace string   `mapstructure:"namespace"` // 命名空间，默认 default
+	Name      string   `mapstructure:"name"`      // Secret 名称，默认由证书名称生成
+	Labels    []string `mapstructure:"labels"`    // 标签，key=value 形式
+	Output    string   `mapstructure:"output"`    // 输出文件路径，设置后每次签发/续期都会重新生成
+}
+
+// DeployTargetConfig 证书部署目标配置
+type DeployTargetConfig struct {
+	Type      string            `mapstructure:"type"`       // file（默认）, vault
+	DestDir   string            `mapstructure:"dest_dir"`   // 目标目录
+	Include   []string          `mapstructure:"include"`    // 要部署的文件：cert, chain, fullchain, key, combined，默认 fullchain 和 key
+	FileNames map[string]string `mapstructure:"file_names"` // 目标文件名，例如 fullchain: server.crt
+	Owner     string            `mapstructure:"owner"`      // 文件所有者
+	Group     string            `mapstructure:"group"`      // 文件所属组
+	Mode      string            `mapstructure:"mode"`       // 证书文件权限，默认 0644
+	KeyMode   string            `mapstructure:"key_mode"`   // 私钥文件权限，默认 0600
+	ReloadCmd string            `mapstructure:"reload_cmd"` // 部署后执行的重载命令
+	Timeout   time.Duration     `mapstructure:"timeout"`    // 重载命令超时时间
+	Vault     VaultConfig       `mapstructure:"vault"`      // type 为 vault 时使用
+}
+
+// VaultConfig HashiCorp Vault KV v2 部署目标配置
+type VaultConfig struct {
+	Address   string `mapstructure:"address"`    // Vault 地址，默认读取 VAULT_ADDR
+	Namespace string `mapstructure:"namespace"`  // Vault 企业版命名空间
+	Mount     string `mapstructure:"mount"`      // KV v2 挂载点，默认 secret
+	Path      string `mapstructure:"path"`       // 密钥路径，例如 tls/example.com
+	Token     string `mapstructure:"token"`      // Token 认证，默认读取 VAULT_TOKEN
+	RoleID    string `mapstructure:"role_id"`    // AppRole 认证
+	SecretID  string `mapstructure:"secret_id"`  // AppRole 认证
+	AuthMount string `mapstructure:"auth_mount"` // AppRole 挂载点，默认 approle
+}
+
+// HooksConfig 证书生命周期钩子配置
+type HooksConfig struct {
+	PreHook    string        `mapstructure:"pre_hook"`    // 申请证书之前执行
+	PostHook   string        `mapstructure:"post_hook"`   // 申请证书之后执行（无论成功与否）
+	DeployHook string        `mapstructure:"deploy_hook"` // 证书签发或续期成功后执行
+	Timeout    time.Duration `mapstructure:"timeout"`     // 单个钩子超时时间，默认 5 分钟
+}
+
+// OutputFormatConfig 证书额外输出格式配置
+type OutputFormatConfig struct {
+	Type     string `mapstructure:"type"`     // pkcs12, der, jks, combined
+	Path     string `mapstructure:"path"`     // 输出文件路径，默认写入证书目录
+	Password string `mapstructure:"password"` // PKCS#12 / JKS 密码
+	Alias    string `mapstructure:"alias"`    // JKS 条目别名，默认为证书名称
 }
 
 var (
@@ -62,6 +201,9 @@ var (
 	AppConfig *Config
 )
 
+// DefaultChallengeDir Linux 上 HTTP-01 验证文件的默认目录，需要 Web 服务器可读
+const DefaultChallengeDir = "/var/lib/autocert/acme-challenge"
+
 // Load 加载配置
 func Load() {
 	AppConfig = &Config{}
@@ -83,11 +225,13 @@ func setDefaults() {
 		viper.SetDefault("config_dir", filepath.Join(os.Getenv("PROGRAMDATA"), "AutoCert"))
 		viper.SetDefault("cert_dir", filepath.Join(os.Getenv("PROGRAMDATA"), "AutoCert", "certs"))
 		viper.SetDefault("log_dir", filepath.Join(os.Getenv("PROGRAMDATA"), "AutoCert", "logs"))
+		viper.SetDefault("acme.webroot", filepath.Join(os.Getenv("PROGRAMDATA"), "AutoCert", "acme-challenge"))
 		viper.SetDefault("webserver.type", "iis")
 	} else {
 		viper.SetDefault("config_dir", "/etc/autocert")
 		viper.SetDefault("cert_dir", "/etc/autocert/certs")
 		viper.SetDefault("log_dir", "/var/log")
+		viper.SetDefault("acme.webroot", DefaultChallengeDir)
 		viper.SetDefault("webserver.type", "nginx")
 	}
 
@@ -113,11 +257,13 @@ func getDefaultConfig() *Config {
 		config.ConfigDir = filepath.Join(os.Getenv("PROGRAMDATA"), "AutoCert")
 		config.CertDir = filepath.Join(os.Getenv("PROGRAMDATA"), "AutoCert", "certs")
 		config.LogDir = filepath.Join(os.Getenv("PROGRAMDATA"), "AutoCert", "logs")
+		config.ACME.Webroot = filepath.Join(os.Getenv("PROGRAMDATA"), "AutoCert", "acme-challenge")
 		config.WebServer.Type = "iis"
 	} else {
 		config.ConfigDir = "/etc/autocert"
 		config.CertDir = "/etc/autocert/certs"
 		config.LogDir = "/var/log"
+		config.ACME.Webroot = DefaultChallengeDir
 		config.WebServer.Type = "nginx"
 	}
 
@@ -126,7 +272,7 @@ func getDefaultConfig() *Config {
 
 // GetConfigDir 获取配置目录
 func GetConfigDir() string {
-	if AppConfig != nil {
+	if AppConfig != nil && AppConfig.ConfigDir != "" {
 		return AppConfig.ConfigDir
 	}
 	return getDefaultConfig().ConfigDir
@@ -134,8 +280,36 @@ func GetConfigDir() string {
 
 // GetCertDir 获取证书目录
 func GetCertDir() string {
-	if AppConfig != nil {
+	if AppConfig != nil && AppConfig.CertDir != "" {
 		return AppConfig.CertDir
 	}
 	return getDefaultConfig().CertDir
 }
+
+// GetChallengeDir 获取 HTTP-01 验证文件目录
+func GetChallengeDir() string {
+	if AppConfig != nil && AppConfig.ACME.Webroot != "" {
+		return AppConfig.ACME.Webroot
+	}
+	return getDefaultConfig().ACME.Webroot
+}
+
+// GetWebServerConfig 获取 Web 服务器配置
+func GetWebServerConfig() WebServerConfig {
+	if AppConfig != nil {
+		return AppConfig.WebServer
+	}
+	return getDefaultConfig().WebServer
+}
+
+// GetCertificateConfig 获取指定证书的配置，未配置时返回空配置
+func GetCertificateConfig(name string) *CertificateConfig {
+	if AppConfig != nil {
+		for i := range AppConfig.Certificates {
+			if AppConfig.Certificates[i].Name == name {
+				return &AppConfig.Certificates[i]
+			}
+		}
+	}
+	return &CertificateConfig{Name: name}
+}
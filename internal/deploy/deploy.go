@@ -0,0 +1,76 @@
+package deploy
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/hook"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 证书目录中可部署的文件类型
+const (
+	FileCert      = "cert"
+	FileChain     = "chain"
+	FileFullchain = "fullchain"
+	FileKey       = "key"
+	FileCombined  = "combined"
+)
+
+// Source 待部署的证书
+type Source struct {
+	Name    string            // 证书名称
+	Dir     string            // 证书目录
+	Domains []string          // 证书包含的域名
+	Serial  string            // 证书序列号（十六进制）
+	Expiry  time.Time         // 证书到期时间
+	Files   map[string]string // 文件类型 -> 本地路径
+}
+
+// Apply 将证书部署到指定目标
+func Apply(src *Source, target config.DeployTargetConfig) error {
+	switch strings.ToLower(target.Type) {
+	case "", "file":
+		return applyFile(src, target)
+	case "vault":
+		return applyVault(src, target)
+	default:
+		return fmt.Errorf("不支持的部署目标类型: %s", target.Type)
+	}
+}
+
+// Describe 返回部署目标的简短描述，用于日志
+func Describe(target config.DeployTargetConfig) string {
+	switch strings.ToLower(target.Type) {
+	case "", "file":
+		return "file:" + target.DestDir
+	case "vault":
+		return "vault:" + target.Vault.Path
+	default:
+		return target.Type
+	}
+}
+
+// isPrivate 文件是否包含私钥
+func isPrivate(kind string) bool {
+	return kind == FileKey || kind == FileCombined
+}
+
+// runReload 执行部署后的重载命令
+func runReload(src *Source, command string, timeout time.Duration) error {
+	return hook.Run(hook.EventDeploy, command, Env(src), timeout)
+}
+
+// Env 构造传递给重载命令的环境变量
+func Env(src *Source) map[string]string {
+	env := map[string]string{
+		"AUTOCERT_CERT_NAME": src.Name,
+		"AUTOCERT_LINEAGE":   src.Dir,
+		"AUTOCERT_DOMAINS":   strings.Join(src.Domains, " "),
+		"AUTOCERT_SERIAL":    src.Serial,
+	}
+	if !src.Expiry.IsZero() {
+		env["AUTOCERT_EXPIRY"] = src.Expiry.UTC().Format(time.RFC3339)
+	}
+	return env
+}
@@ -0,0 +1,198 @@
+package deploy
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// defaultInclude 未配置 include 时部署的文件
+var defaultInclude = []string{FileFullchain, FileKey}
+
+// stagedFile 已写入临时文件、等待重命名的文件
+type stagedFile struct {
+	tmpPath  string
+	destPath string
+}
+
+// applyFile 将证书文件复制到目标目录
+// 所有文件先写入同目录下的临时文件并设置好所有者和权限，全部成功后再依次重命名
+func applyFile(src *Source, target config.DeployTargetConfig) error {
+	if target.DestDir == "" {
+		return fmt.Errorf("部署目标未设置 dest_dir")
+	}
+
+	uid, gid, err := lookupOwner(target.Owner, target.Group)
+	if err != nil {
+		return err
+	}
+
+	certMode, err := parseMode(target.Mode, 0644)
+	if err != nil {
+		return err
+	}
+	keyMode, err := parseMode(target.KeyMode, 0600)
+	if err != nil {
+		return err
+	}
+
+	include := target.Include
+	if len(include) == 0 {
+		include = defaultInclude
+	}
+
+	if err := os.MkdirAll(target.DestDir, 0755); err != nil {
+		return fmt.Errorf("创建目标目录失败: %w", err)
+	}
+
+	var staged []stagedFile
+	cleanup := func() {
+		for _, f := range staged {
+			os.Remove(f.tmpPath)
+		}
+	}
+
+	for _, kind := range include {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		srcPath, ok := src.Files[kind]
+		if !ok {
+			cleanup()
+			return fmt.Errorf("未知的证书文件类型: %s", kind)
+		}
+
+		name := target.FileNames[kind]
+		if name == "" {
+			name = filepath.Base(srcPath)
+		}
+
+		mode := certMode
+		if isPrivate(kind) {
+			mode = keyMode
+		}
+
+		tmpPath, err := stageFile(srcPath, filepath.Join(target.DestDir, name), mode, uid, gid)
+		if err != nil {
+			cleanup()
+			return fmt.Errorf("复制 %s 失败: %w", kind, err)
+		}
+		staged = append(staged, stagedFile{tmpPath: tmpPath, destPath: filepath.Join(target.DestDir, name)})
+	}
+
+	for i, f := range staged {
+		if err := os.Rename(f.tmpPath, f.destPath); err != nil {
+			for _, rest := range staged[i:] {
+				os.Remove(rest.tmpPath)
+			}
+			return fmt.Errorf("替换 %s 失败: %w", f.destPath, err)
+		}
+	}
+
+	logger.Info("证书文件部署完成", "certName", src.Name, "destDir", target.DestDir, "files", len(staged))
+
+	if target.ReloadCmd != "" {
+		if err := runReload(src, target.ReloadCmd, target.Timeout); err != nil {
+			return fmt.Errorf("执行重载命令失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// stageFile 将源文件复制到目标目录中的临时文件，返回临时文件路径
+func stageFile(srcPath, destPath string, mode os.FileMode, uid, gid int) (string, error) {
+	data, err := os.ReadFile(srcPath)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(destPath), "."+filepath.Base(destPath)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+
+	// 先收紧权限再写入内容，避免私钥短暂可读
+	if err := tmp.Chmod(0600); err != nil && runtime.GOOS != "windows" {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	if uid >= 0 || gid >= 0 {
+		if err := os.Chown(tmpPath, uid, gid); err != nil {
+			os.Remove(tmpPath)
+			return "", fmt.Errorf("设置文件所有者失败: %w", err)
+		}
+	}
+
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	return tmpPath, nil
+}
+
+// lookupOwner 解析用户名和组名，未设置时返回 -1 表示不修改
+func lookupOwner(owner, group string) (int, int, error) {
+	uid, gid := -1, -1
+	if owner == "" && group == "" {
+		return uid, gid, nil
+	}
+
+	if runtime.GOOS == "windows" {
+		logger.Warn("Windows 不支持设置文件所有者，忽略 owner/group 配置")
+		return uid, gid, nil
+	}
+
+	if owner != "" {
+		u, err := user.Lookup(owner)
+		if err != nil {
+			return 0, 0, fmt.Errorf("查找用户 %s 失败: %w", owner, err)
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return 0, 0, fmt.Errorf("查找用户组 %s 失败: %w", group, err)
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return uid, gid, nil
+}
+
+// parseMode 解析八进制权限字符串
+func parseMode(mode string, def os.FileMode) (os.FileMode, error) {
+	if mode == "" {
+		return def, nil
+	}
+
+	v, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("无效的文件权限 %q: %w", mode, err)
+	}
+	return os.FileMode(v), nil
+}
@@ -0,0 +1,220 @@
+package deploy
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Vault 中保存的字段名
+const (
+	vaultFieldCertificate = "certificate"
+	vaultFieldChain       = "chain"
+	vaultFieldFullchain   = "fullchain"
+	vaultFieldPrivateKey  = "private_key"
+	vaultFieldDomains     = "domains"
+	vaultFieldSerial      = "serial"
+	vaultFieldExpiry      = "expiry"
+)
+
+// vaultClient 基于 HTTP API 的最小 Vault 客户端
+type vaultClient struct {
+	address   string
+	namespace string
+	mount     string
+	token     string
+	http      *http.Client
+}
+
+// applyVault 将证书写入 Vault KV v2
+func applyVault(src *Source, target config.DeployTargetConfig) error {
+	client, err := newVaultClient(target.Vault)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		vaultFieldDomains: strings.Join(src.Domains, ","),
+		vaultFieldSerial:  src.Serial,
+		vaultFieldExpiry:  src.Expiry.UTC().Format(time.RFC3339),
+	}
+
+	fields := map[string]string{
+		vaultFieldCertificate: FileCert,
+		vaultFieldChain:       FileChain,
+		vaultFieldFullchain:   FileFullchain,
+		vaultFieldPrivateKey:  FileKey,
+	}
+	for field, kind := range fields {
+		content, err := os.ReadFile(src.Files[kind])
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %w", kind, err)
+		}
+		data[field] = string(content)
+	}
+
+	body := map[string]interface{}{"data": data}
+	if _, err := client.do(http.MethodPost, client.dataPath(target.Vault.Path), body); err != nil {
+		return fmt.Errorf("写入 Vault 失败: %w", err)
+	}
+
+	logger.Info("证书已写入 Vault", "certName", src.Name, "mount", client.mount, "path", target.Vault.Path)
+	return nil
+}
+
+// FetchFromVault 从 Vault KV v2 读取证书，返回完整证书链和私钥（PEM）
+func FetchFromVault(cfg config.VaultConfig) (fullchain, privateKey []byte, err error) {
+	client, err := newVaultClient(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := client.do(http.MethodGet, client.dataPath(cfg.Path), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取 Vault 失败: %w", err)
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resp, &secret); err != nil {
+		return nil, nil, fmt.Errorf("解析 Vault 响应失败: %w", err)
+	}
+
+	values := secret.Data.Data
+	chain := values[vaultFieldFullchain]
+	if chain == "" {
+		// 兼容只保存了叶子证书和中间证书的密钥
+		chain = values[vaultFieldCertificate] + values[vaultFieldChain]
+	}
+	if chain == "" || values[vaultFieldPrivateKey] == "" {
+		return nil, nil, fmt.Errorf("Vault 路径 %s 中缺少证书或私钥", cfg.Path)
+	}
+
+	return []byte(chain), []byte(values[vaultFieldPrivateKey]), nil
+}
+
+// newVaultClient 创建 Vault 客户端并完成认证
+func newVaultClient(cfg config.VaultConfig) (*vaultClient, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("Vault 部署目标未设置 path")
+	}
+
+	c := &vaultClient{
+		address:   cfg.Address,
+		namespace: cfg.Namespace,
+		mount:     cfg.Mount,
+		token:     cfg.Token,
+		http:      &http.Client{Timeout: 30 * time.Second},
+	}
+	if c.address == "" {
+		c.address = os.Getenv("VAULT_ADDR")
+	}
+	if c.address == "" {
+		return nil, fmt.Errorf("未设置 Vault 地址")
+	}
+	c.address = strings.TrimRight(c.address, "/")
+	if c.mount == "" {
+		c.mount = "secret"
+	}
+
+	// 优先使用 AppRole，其次使用配置或环境变量中的 Token
+	if cfg.RoleID != "" {
+		if err := c.loginAppRole(cfg); err != nil {
+			return nil, err
+		}
+	} else if c.token == "" {
+		c.token = os.Getenv("VAULT_TOKEN")
+	}
+	if c.token == "" {
+		return nil, fmt.Errorf("未配置 Vault 认证信息（token 或 role_id/secret_id）")
+	}
+
+	return c, nil
+}
+
+// loginAppRole 使用 AppRole 登录获取 Token
+func (c *vaultClient) loginAppRole(cfg config.VaultConfig) error {
+	authMount := cfg.AuthMount
+	if authMount == "" {
+		authMount = "approle"
+	}
+
+	body := map[string]interface{}{
+		"role_id":   cfg.RoleID,
+		"secret_id": cfg.SecretID,
+	}
+	resp, err := c.do(http.MethodPost, "auth/"+strings.Trim(authMount, "/")+"/login", body)
+	if err != nil {
+		return fmt.Errorf("Vault AppRole 登录失败: %w", err)
+	}
+
+	var login struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if err := json.Unmarshal(resp, &login); err != nil {
+		return fmt.Errorf("解析 Vault 登录响应失败: %w", err)
+	}
+	if login.Auth.ClientToken == "" {
+		return fmt.Errorf("Vault AppRole 登录未返回 Token")
+	}
+
+	c.token = login.Auth.ClientToken
+	return nil
+}
+
+// dataPath KV v2 数据路径
+func (c *vaultClient) dataPath(path string) string {
+	return strings.Trim(c.mount, "/") + "/data/" + strings.Trim(path, "/")
+}
+
+// do 发送 Vault API 请求
+func (c *vaultClient) do(method, path string, body interface{}) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.address+"/v1/"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Vault 返回 %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
@@ -0,0 +1,89 @@
+package hook
+
+import (
+	"autocert/internal/logger"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event 钩子触发时机
+type Event string
+
+const (
+	EventPre    Event = "pre"    // 申请证书之前
+	EventPost   Event = "post"   // 申请证书之后（无论成功与否）
+	EventDeploy Event = "deploy" // 证书签发或续期成功之后
+)
+
+// DefaultTimeout 默认钩子超时时间
+const DefaultTimeout = 5 * time.Minute
+
+// Run 通过系统 shell 执行钩子命令
+// env 会追加到当前进程的环境变量之后，命令的输出会记录到日志中
+func Run(event Event, command string, env map[string]string, timeout time.Duration) error {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	logger.Info("执行钩子", "event", string(event), "command", command, "timeout", timeout.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), formatEnv(env)...)
+	// 超时后 shell 的子进程可能仍持有输出管道，限制等待时间
+	cmd.WaitDelay = 5 * time.Second
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	start := time.Now()
+	err := cmd.Run()
+	out := strings.TrimSpace(output.String())
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logger.Error("钩子执行超时", "event", string(event), "command", command, "output", out)
+		return fmt.Errorf("%s 钩子执行超时（%s）", event, timeout)
+	}
+	if err != nil {
+		logger.Error("钩子执行失败", "event", string(event), "command", command, "error", err, "output", out)
+		return fmt.Errorf("%s 钩子执行失败: %w", event, err)
+	}
+
+	logger.Info("钩子执行完成", "event", string(event), "duration", time.Since(start).String(), "output", out)
+	return nil
+}
+
+// formatEnv 将环境变量转换为 KEY=VALUE 形式，按键排序保证顺序稳定
+func formatEnv(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make([]string, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, k+"="+env[k])
+	}
+	return result
+}
@@ -9,7 +9,8 @@ import (
 	"github.com/spf13/viper"
 )
 
-var log *logrus.Logger
+// log 在 Init 之前（例如单元测试中）输出到标准错误
+var log = logrus.New()
 
 // Init 初始化日志系统
 func Init() {
@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event 通知类型
+type Event string
+
+const (
+	EventVerifyFailed Event = "verify_failed" // 部署后验证证书失败
+)
+
+// Message 通知内容
+type Message struct {
+	Event   Event    `json:"event"`
+	Subject string   `json:"subject"`
+	Body    string   `json:"message"`
+	Domains []string `json:"domains"`
+	Time    string   `json:"time"`
+}
+
+// Send 按通知配置发送 Webhook 和邮件，未配置时不做任何事
+// 所有渠道都会尝试发送，返回遇到的错误
+func Send(msg Message) error {
+	if config.AppConfig == nil {
+		return nil
+	}
+	cfg := config.AppConfig.Notification
+	if msg.Time == "" {
+		msg.Time = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	var errs []error
+	if cfg.Webhook != "" {
+		if err := sendWebhook(cfg.Webhook, msg); err != nil {
+			errs = append(errs, fmt.Errorf("发送 Webhook 通知失败: %w", err))
+		} else {
+			logger.Info("已发送 Webhook 通知", "event", string(msg.Event))
+		}
+	}
+	if cfg.Email.SMTP != "" && cfg.Email.To != "" {
+		if err := sendEmail(cfg.Email, msg); err != nil {
+			errs = append(errs, fmt.Errorf("发送邮件通知失败: %w", err))
+		} else {
+			logger.Info("已发送邮件通知", "event", string(msg.Event), "to", cfg.Email.To)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendWebhook 以 JSON 形式 POST 通知内容
+func sendWebhook(url string, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendEmail 通过 SMTP 发送邮件，465 端口使用 TLS 直连，其他端口在服务器支持时使用 STARTTLS
+func sendEmail(cfg config.EmailConfig, msg Message) error {
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(cfg.SMTP, strconv.Itoa(port))
+
+	from := cfg.From
+	if from == "" {
+		from = cfg.Username
+	}
+	var to []string
+	for _, t := range strings.Split(cfg.To, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			to = append(to, t)
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "From: %s\r\n", from)
+	fmt.Fprintf(&sb, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&sb, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	sb.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	sb.WriteString("\r\n")
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.SMTP)
+	}
+
+	if port != 465 {
+		return smtp.SendMail(addr, auth, from, to, []byte(sb.String()))
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: cfg.SMTP})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, cfg.SMTP)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, t := range to {
+		if err := client.Rcpt(t); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(sb.String())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,236 @@
+package scheduler
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// DefaultSchedule 默认每天凌晨 2 点检查续期
+const DefaultSchedule = "0 2 * * *"
+
+// cronMacros cron 的 @ 简写对应的 systemd 日历表达式
+var cronMacros = map[string]string{
+	"@yearly":   "yearly",
+	"@annually": "yearly",
+	"@monthly":  "monthly",
+	"@weekly":   "weekly",
+	"@daily":    "daily",
+	"@midnight": "daily",
+	"@hourly":   "hourly",
+}
+
+// cronField cron 表达式中一个字段的取值范围和名称
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names []string // 月份、星期的英文缩写，下标为对应的数值
+}
+
+var (
+	cronMinute = cronField{name: "分钟", min: 0, max: 59}
+	cronHour   = cronField{name: "小时", min: 0, max: 23}
+	cronDay    = cronField{name: "日期", min: 1, max: 31}
+	cronMonth  = cronField{name: "月份", min: 1, max: 12,
+		names: []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	cronWeekday = cronField{name: "星期", min: 0, max: 7,
+		names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat", "sun"}}
+)
+
+// systemdWeekdays systemd 的星期名称，下标为 cron 的星期数值（0 和 7 都是星期日）
+var systemdWeekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// IsCronExpression 是否为 cron 表达式（5 个字段或 @ 简写），否则按 systemd 日历表达式处理
+func IsCronExpression(schedule string) bool {
+	schedule = strings.TrimSpace(schedule)
+	return strings.HasPrefix(schedule, "@") || len(strings.Fields(schedule)) == 5
+}
+
+// ToOnCalendar 将 cron 表达式转换为 systemd timer 的 OnCalendar，systemd 日历表达式原样返回
+// 例如 "0 2 * * *" 转换为 "*-*-* 02:00:00"，"30 3 * * 1-5" 转换为 "Mon..Fri *-*-* 03:30:00"
+func ToOnCalendar(schedule string) (string, error) {
+	schedule = strings.TrimSpace(schedule)
+	if schedule == "" {
+		return "", fmt.Errorf("调度表达式不能为空")
+	}
+	if !IsCronExpression(schedule) {
+		return schedule, nil
+	}
+	if strings.HasPrefix(schedule, "@") {
+		calendar, ok := cronMacros[strings.ToLower(schedule)]
+		if !ok {
+			return "", fmt.Errorf("不支持的 cron 简写: %s", schedule)
+		}
+		return calendar, nil
+	}
+
+	fields := strings.Fields(schedule)
+	// cron 同时限制日期和星期时满足其一即可，systemd 要求同时满足，无法等价转换
+	if fields[2] != "*" && fields[4] != "*" {
+		return "", fmt.Errorf("不支持同时指定日期和星期的 cron 表达式: %s", schedule)
+	}
+
+	minute, err := cronMinute.calendar(fields[0], 0)
+	if err != nil {
+		return "", err
+	}
+	hour, err := cronHour.calendar(fields[1], 0)
+	if err != nil {
+		return "", err
+	}
+	day, err := cronDay.calendar(fields[2], 1)
+	if err != nil {
+		return "", err
+	}
+	month, err := cronMonth.calendar(fields[3], 1)
+	if err != nil {
+		return "", err
+	}
+
+	calendar := fmt.Sprintf("*-%s-%s %s:%s:00", month, day, hour, minute)
+	if fields[4] != "*" {
+		weekdays, err := cronWeekday.values(fields[4])
+		if err != nil {
+			return "", err
+		}
+		if list := weekdayList(weekdays); list != "" {
+			calendar = list + " " + calendar
+		}
+	}
+	return calendar, nil
+}
+
+// calendar 转换一个数值字段：* 保持不变，*/n 转换为 start/n，其他展开为逗号分隔的值
+func (f cronField) calendar(expr string, start int) (string, error) {
+	if expr == "*" {
+		return "*", nil
+	}
+	if step, ok := strings.CutPrefix(expr, "*/"); ok {
+		n, err := strconv.Atoi(step)
+		if err != nil || n <= 0 {
+			return "", fmt.Errorf("%s字段的间隔无效: %s", f.name, expr)
+		}
+		return fmt.Sprintf("%02d/%d", start, n), nil
+	}
+
+	values, err := f.values(expr)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%02d", v)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// values 展开字段中的列表、范围和间隔，例如 1-10/3 展开为 1,4,7,10
+func (f cronField) values(expr string) ([]int, error) {
+	var result []int
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, step := part, 1
+		if r, s, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("%s字段的间隔无效: %s", f.name, part)
+			}
+			rangeExpr, step = r, n
+		}
+
+		var low, high int
+		switch {
+		case rangeExpr == "*":
+			low, high = f.min, f.max
+		case strings.Contains(rangeExpr, "-"):
+			a, b, _ := strings.Cut(rangeExpr, "-")
+			var err error
+			if low, err = f.parse(a); err != nil {
+				return nil, err
+			}
+			if high, err = f.parse(b); err != nil {
+				return nil, err
+			}
+			if low > high {
+				return nil, fmt.Errorf("%s字段的范围无效: %s", f.name, part)
+			}
+		default:
+			v, err := f.parse(rangeExpr)
+			if err != nil {
+				return nil, err
+			}
+			low, high = v, v
+			if step > 1 {
+				high = f.max
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+// parse 解析单个数值或名称，并检查取值范围
+func (f cronField) parse(s string) (int, error) {
+	for i, name := range f.names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s字段的值无效: %s（范围 %d-%d）", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// weekdayList 星期列表，连续的星期合并为范围，例如 Mon..Fri
+// systemd 的范围从星期一开始，不能写成 Sun..Sat，所以按星期一到星期日排列
+func weekdayList(values []int) string {
+	seen := make(map[int]bool)
+	for _, v := range values {
+		if v == 0 {
+			v = 7
+		}
+		seen[v] = true
+	}
+	if len(seen) == 7 {
+		return ""
+	}
+
+	var parts []string
+	for d := 1; d <= 7; d++ {
+		if !seen[d] {
+			continue
+		}
+		end := d
+		for end+1 <= 7 && seen[end+1] {
+			end++
+		}
+		switch {
+		case end == d:
+			parts = append(parts, systemdWeekdays[d])
+		case end == d+1:
+			parts = append(parts, systemdWeekdays[d], systemdWeekdays[end])
+		default:
+			parts = append(parts, systemdWeekdays[d]+".."+systemdWeekdays[end])
+		}
+		d = end
+	}
+	return strings.Join(parts, ",")
+}
+
+// ValidateOnCalendar 使用 systemd-analyze calendar 检查表达式，没有 systemd-analyze 时跳过
+func ValidateOnCalendar(calendar string) error {
+	if _, err := exec.LookPath("systemd-analyze"); err != nil {
+		return nil
+	}
+	output, err := exec.Command("systemd-analyze", "calendar", calendar).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("无效的 systemd 日历表达式 %q: %s", calendar, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToOnCalendar(t *testing.T) {
+	tests := []struct {
+		schedule string
+		want     string
+	}{
+		// 文档注释中的例子
+		{"0 2 * * *", "*-*-* 02:00:00"},
+		{"30 3 * * 1-5", "Mon..Fri *-*-* 03:30:00"},
+
+		// 间隔
+		{"*/15 * * * *", "*-*-* *:00/15:00"},
+		{"0 */6 * * *", "*-*-* 00/6:00:00"},
+		{"0 0 */2 * *", "*-*-01/2 00:00:00"},
+		{"0 1-10/3 * * *", "*-*-* 01,04,07,10:00:00"},
+		{"5/20 * * * *", "*-*-* *:05,25,45:00"},
+
+		// 列表和范围
+		{"0 4 1,15 * *", "*-*-01,15 04:00:00"},
+		{"0,30 8-10 * * *", "*-*-* 08,09,10:00,30:00"},
+
+		// 月份和星期名称
+		{"0 0 * jan-mar sun,6", "Sat,Sun *-01,02,03-* 00:00:00"},
+		{"0 0 1 DEC *", "*-12-01 00:00:00"},
+		{"0 9 * * mon,wed,fri", "Mon,Wed,Fri *-*-* 09:00:00"},
+		{"0 9 * * tue-thu", "Tue..Thu *-*-* 09:00:00"},
+
+		// 星期日可以写成 0 或 7
+		{"0 0 * * 0", "Sun *-*-* 00:00:00"},
+		{"0 0 * * 7", "Sun *-*-* 00:00:00"},
+		{"0 0 * * 0,7", "Sun *-*-* 00:00:00"},
+		{"0 0 * * 5-7", "Fri..Sun *-*-* 00:00:00"},
+		{"0 0 * * 0-1", "Mon,Sun *-*-* 00:00:00"},
+		{"0 1 * * 0-6", "*-*-* 01:00:00"},
+		{"0 1 * * */1", "*-*-* 01:00:00"},
+
+		// @ 简写
+		{"@weekly", "weekly"},
+		{"@DAILY", "daily"},
+		{"@midnight", "daily"},
+		{"@annually", "yearly"},
+
+		// systemd 日历表达式原样返回
+		{"daily", "daily"},
+		{" Mon *-*-* 04:00:00 ", "Mon *-*-* 04:00:00"},
+	}
+
+	for _, tt := range tests {
+		got, err := ToOnCalendar(tt.schedule)
+		if err != nil {
+			t.Errorf("ToOnCalendar(%q): %v", tt.schedule, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ToOnCalendar(%q) = %q, want %q", tt.schedule, got, tt.want)
+		}
+	}
+}
+
+func TestToOnCalendarErrors(t *testing.T) {
+	tests := []struct {
+		schedule string
+		want     string
+	}{
+		{"", "不能为空"},
+		{"   ", "不能为空"},
+		{"@reboot", "不支持的 cron 简写"},
+		{"0 2 1 * 1", "不支持同时指定日期和星期"},
+		{"0 2 1-15 * mon-fri", "不支持同时指定日期和星期"},
+		{"61 2 * * *", "分钟字段的值无效"},
+		{"0 24 * * *", "小时字段的值无效"},
+		{"0 0 0 * *", "日期字段的值无效"},
+		{"0 0 * 13 *", "月份字段的值无效"},
+		{"0 0 * foo *", "月份字段的值无效"},
+		{"0 0 * * 8", "星期字段的值无效"},
+		{"*/0 * * * *", "分钟字段的间隔无效"},
+		{"0 */x * * *", "小时字段的间隔无效"},
+		{"0 1-5/0 * * *", "小时字段的间隔无效"},
+		{"0 10-5 * * *", "小时字段的范围无效"},
+		{"0 0 * * fri-mon", "星期字段的范围无效"},
+	}
+
+	for _, tt := range tests {
+		_, err := ToOnCalendar(tt.schedule)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ToOnCalendar(%q) error = %v, want containing %q", tt.schedule, err, tt.want)
+		}
+	}
+}
+
+func TestIsCronExpression(t *testing.T) {
+	tests := []struct {
+		schedule string
+		want     bool
+	}{
+		{"0 2 * * *", true},
+		{" 0 2 * * * ", true},
+		{"@daily", true},
+		{"daily", false},
+		{"*-*-* 02:00:00", false},
+		{"Mon..Fri *-*-* 03:30:00", false},
+		{"0 2 * *", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsCronExpression(tt.schedule); got != tt.want {
+			t.Errorf("IsCronExpression(%q) = %v, want %v", tt.schedule, got, tt.want)
+		}
+	}
+}
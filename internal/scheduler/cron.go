@@ -0,0 +1,316 @@
+package scheduler
+
+import (
+	"autocert/internal/logger"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// cronDDir 系统 cron 目录，每个任务一个文件
+	cronDDir = "/etc/cron.d"
+	// busyboxCronDir BusyBox crond 的目录，没有 cron.d，任务写入 root 的 crontab
+	busyboxCronDir = "/etc/crontabs"
+)
+
+const (
+	cronBlockBegin = "# BEGIN AutoCert "
+	cronBlockEnd   = "# END AutoCert "
+	cronMarker     = "# AutoCert 自动生成的定时任务，请使用 autocert schedule 管理"
+)
+
+// cronTaskName cron.d 的文件名只能包含字母、数字、下划线和连字符，否则会被 run-parts 规则忽略
+var cronTaskName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// cronTarget 任务所在的 cron 文件
+type cronTarget struct {
+	path   string
+	shared bool // BusyBox 的 root crontab 与其他任务共用，只改写 AutoCert 的区块，条目中没有用户字段
+}
+
+// cronTargetFor 选择任务文件：优先使用 /etc/cron.d/<任务名>，其次 BusyBox 的 /etc/crontabs/root
+func cronTargetFor(taskName string) (cronTarget, error) {
+	if !cronTaskName.MatchString(taskName) {
+		return cronTarget{}, fmt.Errorf("任务名称只能包含字母、数字、下划线和连字符: %s", taskName)
+	}
+	if isDir(cronDDir) {
+		return cronTarget{path: filepath.Join(cronDDir, taskName)}, nil
+	}
+	if isDir(busyboxCronDir) {
+		return cronTarget{path: filepath.Join(busyboxCronDir, "root"), shared: true}, nil
+	}
+	return cronTarget{}, fmt.Errorf("未找到 %s 或 %s，无法安装 cron 任务", cronDDir, busyboxCronDir)
+}
+
+// installCronJob 安装 cron 任务，重复安装时改写已有的条目
+func (l *LinuxScheduler) installCronJob(taskName, command, schedule string) error {
+	if !IsCronExpression(schedule) {
+		return fmt.Errorf("cron 只支持 cron 表达式（例如 \"0 2 * * *\"），不支持 systemd 日历表达式: %s", schedule)
+	}
+	target, err := cronTargetFor(taskName)
+	if err != nil {
+		return err
+	}
+
+	// 旧版本追加到当前用户 crontab 中的条目，保留会导致任务重复执行
+	if err := removeLegacyCrontabEntry(taskName); err != nil {
+		return err
+	}
+
+	block := cronBlock(taskName, cronEntry(taskName, command, schedule, !target.shared))
+	if err := updateCronFile(target, taskName, block); err != nil {
+		return fmt.Errorf("安装 cron 任务失败: %w", err)
+	}
+
+	logger.Info("cron 任务安装成功", "taskName", taskName, "file", target.path)
+	return nil
+}
+
+// removeCronJob 删除 cron 任务
+func (l *LinuxScheduler) removeCronJob(taskName string) error {
+	target, err := cronTargetFor(taskName)
+	if err != nil {
+		return err
+	}
+	if err := removeLegacyCrontabEntry(taskName); err != nil {
+		return err
+	}
+	if err := updateCronFile(target, taskName, ""); err != nil {
+		return fmt.Errorf("删除 cron 任务失败: %w", err)
+	}
+
+	logger.Info("cron 任务删除成功", "taskName", taskName)
+	return nil
+}
+
+// listCronJobs 列出 AutoCert 安装的 cron 任务
+func (l *LinuxScheduler) listCronJobs() ([]Task, error) {
+	tasks := []Task{}
+	if isDir(cronDDir) {
+		entries, err := os.ReadDir(cronDDir)
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %w", cronDDir, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(cronDDir, entry.Name()))
+			if err != nil {
+				continue
+			}
+			tasks = append(tasks, parseCronBlocks(string(data), true)...)
+		}
+	} else if data, err := os.ReadFile(filepath.Join(busyboxCronDir, "root")); err == nil {
+		tasks = append(tasks, parseCronBlocks(string(data), false)...)
+	}
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+	return tasks, nil
+}
+
+// isCronJobInstalled 检查 cron 任务是否已安装
+func (l *LinuxScheduler) isCronJobInstalled(taskName string) bool {
+	target, err := cronTargetFor(taskName)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(target.path)
+	if err != nil {
+		return false
+	}
+	for _, task := range parseCronBlocks(string(data), !target.shared) {
+		if task.Name == taskName {
+			return true
+		}
+	}
+	return false
+}
+
+// cronEntry 生成 cron 条目，使用 flock 防止上一次续期还没结束时重复执行
+func cronEntry(taskName, command, schedule string, withUser bool) string {
+	run := shellQuote(command) + " renew --all"
+	if flock, err := exec.LookPath("flock"); err == nil {
+		run = fmt.Sprintf("%s -n %s %s", flock, shellQuote(cronLockFile(taskName)), run)
+	} else {
+		logger.Warn("未找到 flock，cron 任务执行时不加锁", "taskName", taskName)
+	}
+	// crontab 中未转义的 % 表示换行
+	run = strings.ReplaceAll(run, "%", `\%`)
+
+	if withUser {
+		return fmt.Sprintf("%s root %s", strings.TrimSpace(schedule), run)
+	}
+	return fmt.Sprintf("%s %s", strings.TrimSpace(schedule), run)
+}
+
+// cronLockFile 任务执行时使用的锁文件
+func cronLockFile(taskName string) string {
+	dir := "/run/lock"
+	if !isDir(dir) {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, taskName+".lock")
+}
+
+// cronBlock 用标记包围任务条目，删除和改写时只处理该区块
+func cronBlock(taskName, entry string) string {
+	return cronBlockBegin + taskName + "\n" + cronMarker + "\n" + entry + "\n" + cronBlockEnd + taskName + "\n"
+}
+
+// replaceCronBlock 删除任务原有的区块，block 不为空时追加新的区块
+func replaceCronBlock(content, taskName, block string) string {
+	var lines []string
+	inBlock := false
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == cronBlockBegin+taskName:
+			inBlock = true
+		case inBlock && trimmed == cronBlockEnd+taskName:
+			inBlock = false
+		case !inBlock && line != "":
+			lines = append(lines, line)
+		}
+	}
+
+	result := strings.Join(lines, "")
+	if block == "" {
+		return result
+	}
+	if result != "" && !strings.HasSuffix(result, "\n") {
+		result += "\n"
+	}
+	return result + block
+}
+
+// updateCronFile 改写任务文件中的区块，内容不变时不写入
+// cron.d 中的文件只属于该任务，删除区块后文件为空时删除文件
+func updateCronFile(target cronTarget, taskName, block string) error {
+	data, err := os.ReadFile(target.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	current := string(data)
+	if !target.shared && current != "" && !strings.Contains(current, cronBlockBegin+taskName+"\n") {
+		return fmt.Errorf("%s 不是 AutoCert 生成的文件", target.path)
+	}
+
+	updated := replaceCronBlock(current, taskName, block)
+	if updated == current {
+		return nil
+	}
+
+	if !target.shared && updated == "" {
+		if err := os.Remove(target.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	perm := os.FileMode(0644)
+	if target.shared {
+		perm = 0600
+	}
+	tmp := target.path + ".autocert.tmp"
+	if err := os.WriteFile(tmp, []byte(updated), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, target.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// BusyBox crond 通过 cron.update 得知 crontab 已修改
+	if target.shared {
+		os.WriteFile(filepath.Join(filepath.Dir(target.path), "cron.update"), []byte("root\n"), 0600)
+	}
+	return nil
+}
+
+// parseCronBlocks 解析 AutoCert 区块中的任务，withUser 表示条目中有用户字段（cron.d）
+func parseCronBlocks(content string, withUser bool) []Task {
+	var tasks []Task
+	name := ""
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, cronBlockBegin):
+			name = strings.TrimPrefix(line, cronBlockBegin)
+		case strings.HasPrefix(line, cronBlockEnd):
+			name = ""
+		case name == "" || line == "" || strings.HasPrefix(line, "#"):
+		default:
+			fields := strings.Fields(line)
+			scheduleFields := 5
+			if strings.HasPrefix(fields[0], "@") {
+				scheduleFields = 1
+			}
+			commandStart := scheduleFields
+			if withUser {
+				commandStart++
+			}
+			if len(fields) <= commandStart {
+				continue
+			}
+			tasks = append(tasks, Task{
+				Name:     name,
+				Command:  strings.Join(fields[commandStart:], " "),
+				Schedule: strings.Join(fields[:scheduleFields], " "),
+				Status:   "enabled",
+			})
+		}
+	}
+	return tasks
+}
+
+// removeLegacyCrontabEntry 从当前用户的 crontab 中删除旧版本添加的条目（以 "# 任务名" 结尾）
+func removeLegacyCrontabEntry(taskName string) error {
+	if _, err := exec.LookPath("crontab"); err != nil {
+		return nil
+	}
+	output, err := exec.Command("crontab", "-l").Output()
+	if err != nil {
+		return nil // 没有 crontab 不是错误
+	}
+
+	var lines []string
+	removed := false
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasSuffix(strings.TrimSpace(line), "# "+taskName) {
+			removed = true
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if !removed {
+		return nil
+	}
+
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(strings.Join(lines, "\n"))
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("删除 crontab 中的旧任务失败: %w", err)
+	}
+	logger.Info("已删除 crontab 中的旧任务", "taskName", taskName)
+	return nil
+}
+
+// shellQuote 路径中有空格或特殊字符时加单引号
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789/._-+=:,@") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// isDir 路径是否为目录
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
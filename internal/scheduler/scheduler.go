@@ -9,6 +9,7 @@ import (
 	"runtime"
 	"strings"
 	"text/template"
+	"time"
 )
 
 // TaskScheduler 任务调度器接口
@@ -29,12 +30,21 @@ type Task struct {
 	NextRun  string
 }
 
+// DefaultRandomizedDelay systemd timer 默认的随机延迟
+const DefaultRandomizedDelay = time.Hour
+
+// Options 调度器选项
+type Options struct {
+	// RandomizedDelay systemd timer 的随机延迟（RandomizedDelaySec），避免大量主机同时请求 ACME 服务器，0 表示不延迟
+	RandomizedDelay time.Duration
+}
+
 // NewScheduler 创建新的任务调度器
-func NewScheduler() TaskScheduler {
+func NewScheduler(opts Options) TaskScheduler {
 	if runtime.GOOS == "windows" {
 		return &WindowsScheduler{}
 	} else {
-		return &LinuxScheduler{}
+		return &LinuxScheduler{opts: opts}
 	}
 }
 
@@ -208,7 +218,9 @@ func (w *WindowsScheduler) generateTaskXML(taskName, command, schedule string) (
 }
 
 // LinuxScheduler Linux Cron 调度器
-type LinuxScheduler struct{}
+type LinuxScheduler struct {
+	opts Options
+}
 
 // Install 安装 Linux 定时任务
 func (l *LinuxScheduler) Install(taskName, command, schedule string) error {
@@ -259,6 +271,14 @@ func (l *LinuxScheduler) supportsSystemdTimer() bool {
 
 // installSystemdTimer 安装 systemd timer
 func (l *LinuxScheduler) installSystemdTimer(taskName, command, schedule string) error {
+	calendar, err := ToOnCalendar(schedule)
+	if err != nil {
+		return err
+	}
+	if err := ValidateOnCalendar(calendar); err != nil {
+		return err
+	}
+
 	// 创建 service 文件
 	serviceContent := fmt.Sprintf(`[Unit]
 Description=%s - AutoCert Certificate Renewal
@@ -281,13 +301,12 @@ Description=%s Timer - AutoCert Certificate Renewal
 Requires=%s.service
 
 [Timer]
-OnCalendar=daily
-RandomizedDelaySec=3600
-Persistent=true
+OnCalendar=%s
+%sPersistent=true
 
 [Install]
 WantedBy=timers.target
-`, taskName, taskName)
+`, taskName, taskName, calendar, l.randomizedDelayLine())
 
 	timerPath := fmt.Sprintf("/etc/systemd/system/%s.timer", taskName)
 	if err := os.WriteFile(timerPath, []byte(timerContent), 0644); err != nil {
@@ -308,10 +327,19 @@ WantedBy=timers.target
 		return fmt.Errorf("启动 timer 失败: %w", err)
 	}
 
-	logger.Info("systemd timer 安装成功", "taskName", taskName)
+	logger.Info("systemd timer 安装成功", "taskName", taskName, "onCalendar", calendar)
 	return nil
 }
 
+// randomizedDelayLine timer 中的 RandomizedDelaySec 配置，未设置延迟时为空
+func (l *LinuxScheduler) randomizedDelayLine() string {
+	seconds := int64(l.opts.RandomizedDelay / time.Second)
+	if seconds <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("RandomizedDelaySec=%d\n", seconds)
+}
+
 // removeSystemdTimer 删除 systemd timer
 func (l *LinuxScheduler) removeSystemdTimer(taskName string) error {
 	// 停止并禁用 timer
@@ -363,96 +391,3 @@ func (l *LinuxScheduler) isSystemdTimerInstalled(taskName string) bool {
 	err := cmd.Run()
 	return err == nil
 }
-
-// installCronJob 安装 cron 任务
-func (l *LinuxScheduler) installCronJob(taskName, command, schedule string) error {
-	// 获取当前 crontab
-	cmd := exec.Command("crontab", "-l")
-	currentCrontab, _ := cmd.Output()
-
-	// 添加新任务
-	cronEntry := fmt.Sprintf("%s %s renew --all # %s\n", schedule, command, taskName)
-	newCrontab := string(currentCrontab) + cronEntry
-
-	// 写入新的 crontab
-	cmd = exec.Command("crontab", "-")
-	cmd.Stdin = strings.NewReader(newCrontab)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("安装 cron 任务失败: %w", err)
-	}
-
-	logger.Info("cron 任务安装成功", "taskName", taskName)
-	return nil
-}
-
-// removeCronJob 删除 cron 任务
-func (l *LinuxScheduler) removeCronJob(taskName string) error {
-	// 获取当前 crontab
-	cmd := exec.Command("crontab", "-l")
-	currentCrontab, err := cmd.Output()
-	if err != nil {
-		return fmt.Errorf("获取 crontab 失败: %w", err)
-	}
-
-	// 过滤掉指定任务
-	lines := strings.Split(string(currentCrontab), "\n")
-	var newLines []string
-
-	for _, line := range lines {
-		if !strings.Contains(line, "# "+taskName) {
-			newLines = append(newLines, line)
-		}
-	}
-
-	// 写入新的 crontab
-	newCrontab := strings.Join(newLines, "\n")
-	cmd = exec.Command("crontab", "-")
-	cmd.Stdin = strings.NewReader(newCrontab)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("删除 cron 任务失败: %w", err)
-	}
-
-	logger.Info("cron 任务删除成功", "taskName", taskName)
-	return nil
-}
-
-// listCronJobs 列出 cron 任务
-func (l *LinuxScheduler) listCronJobs() ([]Task, error) {
-	cmd := exec.Command("crontab", "-l")
-	output, err := cmd.Output()
-	if err != nil {
-		return []Task{}, nil // 没有 crontab 不是错误
-	}
-
-	tasks := []Task{}
-	lines := strings.Split(string(output), "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" && !strings.HasPrefix(line, "#") {
-			fields := strings.Fields(line)
-			if len(fields) >= 6 {
-				task := Task{
-					Name:     "cron-task",
-					Command:  strings.Join(fields[5:], " "),
-					Schedule: strings.Join(fields[0:5], " "),
-					Status:   "enabled",
-				}
-				tasks = append(tasks, task)
-			}
-		}
-	}
-
-	return tasks, nil
-}
-
-// isCronJobInstalled 检查 cron 任务是否已安装
-func (l *LinuxScheduler) isCronJobInstalled(taskName string) bool {
-	cmd := exec.Command("crontab", "-l")
-	output, err := cmd.Output()
-	if err != nil {
-		return false
-	}
-
-	return strings.Contains(string(output), "# "+taskName)
-}
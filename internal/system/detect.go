@@ -19,7 +19,7 @@ type OSInfo struct {
 
 // WebServerInfo Web 服务器信息
 type WebServerInfo struct {
-	Type       string // nginx, apache, iis
+	Type       string // nginx, apache, haproxy, lighttpd, iis
 	Version    string
 	ConfigPath string
 	IsRunning  bool
@@ -162,6 +162,16 @@ func detectWebServers() ([]WebServerInfo, error) {
 		if apacheInfo := detectApache(); apacheInfo != nil {
 			servers = append(servers, *apacheInfo)
 		}
+
+		// 检测 HAProxy
+		if haproxyInfo := detectHAProxy(); haproxyInfo != nil {
+			servers = append(servers, *haproxyInfo)
+		}
+
+		// 检测 lighttpd
+		if lighttpdInfo := detectLighttpd(); lighttpdInfo != nil {
+			servers = append(servers, *lighttpdInfo)
+		}
 	}
 
 	return servers, nil
@@ -215,26 +225,49 @@ func detectNginxWindows() *WebServerInfo {
 	return nil
 }
 
-// detectNginxLinux 检测 Linux 上的 Nginx
+// nginxBinaries Nginx 及兼容发行版（OpenResty、Tengine）的可执行文件和常见安装前缀
+var nginxBinaries = []string{
+	"nginx",
+	"openresty",
+	"tengine",
+	"/usr/local/openresty/bin/openresty",
+	"/usr/local/openresty/nginx/sbin/nginx",
+	"/usr/local/tengine/sbin/nginx",
+	"/opt/tengine/sbin/nginx",
+	"/usr/local/nginx/sbin/nginx",
+}
+
+// detectNginxLinux 检测 Linux 上的 Nginx，OpenResty 和 Tengine 同样识别为 nginx
 func detectNginxLinux() *WebServerInfo {
 	// 检查 nginx 命令是否存在
-	_, err := exec.LookPath("nginx")
-	if err != nil {
+	var nginxPath string
+	for _, name := range nginxBinaries {
+		if path, err := exec.LookPath(name); err == nil {
+			nginxPath = path
+			break
+		}
+	}
+	if nginxPath == "" {
 		return nil
 	}
 
-	// 获取版本
-	cmd := exec.Command("nginx", "-v")
-	output, err := cmd.CombinedOutput()
-	version := "Unknown"
-	if err == nil {
-		version = strings.TrimSpace(string(output))
+	// 获取版本，例如 nginx version: openresty/1.25.3.1
+	version := getNginxVersion(nginxPath)
+	serviceName := "nginx"
+	switch lower := strings.ToLower(version); {
+	case strings.Contains(lower, "openresty"):
+		serviceName = "openresty"
+	case strings.Contains(lower, "tengine"):
+		serviceName = "tengine"
 	}
 
 	// 查找配置文件
 	configPaths := []string{
 		"/etc/nginx/nginx.conf",
 		"/usr/local/nginx/conf/nginx.conf",
+		"/usr/local/openresty/nginx/conf/nginx.conf",
+		"/usr/local/tengine/conf/nginx.conf",
+		"/opt/tengine/conf/nginx.conf",
 	}
 
 	configPath := ""
@@ -249,7 +282,7 @@ func detectNginxLinux() *WebServerInfo {
 		Type:       "nginx",
 		Version:    version,
 		ConfigPath: configPath,
-		IsRunning:  isServiceRunning("nginx"),
+		IsRunning:  isServiceRunning(serviceName),
 	}
 }
 
@@ -304,6 +337,85 @@ func detectApache() *WebServerInfo {
 	}
 }
 
+// detectHAProxy 检测 HAProxy
+func detectHAProxy() *WebServerInfo {
+	if _, err := exec.LookPath("haproxy"); err != nil {
+		return nil
+	}
+
+	// 获取版本
+	cmd := exec.Command("haproxy", "-v")
+	output, err := cmd.Output()
+	version := "Unknown"
+	if err == nil {
+		lines := strings.Split(string(output), "\n")
+		if len(lines) > 0 {
+			version = strings.TrimSpace(lines[0])
+		}
+	}
+
+	// 查找配置文件
+	configPaths := []string{
+		"/etc/haproxy/haproxy.cfg",
+		"/usr/local/etc/haproxy/haproxy.cfg",
+	}
+
+	configPath := ""
+	for _, path := range configPaths {
+		if _, err := os.Stat(path); err == nil {
+			configPath = path
+			break
+		}
+	}
+
+	return &WebServerInfo{
+		Type:       "haproxy",
+		Version:    version,
+		ConfigPath: configPath,
+		IsRunning:  isServiceRunning("haproxy"),
+	}
+}
+
+// detectLighttpd 检测 lighttpd
+func detectLighttpd() *WebServerInfo {
+	if _, err := exec.LookPath("lighttpd"); err != nil {
+		return nil
+	}
+
+	// 获取版本
+	cmd := exec.Command("lighttpd", "-v")
+	output, err := cmd.Output()
+	version := "Unknown"
+	if err == nil {
+		lines := strings.Split(string(output), "\n")
+		if len(lines) > 0 {
+			version = strings.TrimSpace(lines[0])
+		}
+	}
+
+	// 查找配置文件
+	configPaths := []string{
+		"/etc/lighttpd/lighttpd.conf",
+		"/usr/local/etc/lighttpd/lighttpd.conf",
+		"/opt/etc/lighttpd/lighttpd.conf",
+	}
+
+	configPath := ""
+	for _, path := range configPaths {
+		if _, err := os.Stat(path); err == nil {
+			configPath = path
+			break
+		}
+	}
+
+	return &WebServerInfo{
+		Type:       "lighttpd",
+		Version:    version,
+		ConfigPath: configPath,
+		IsRunning:  isServiceRunning("lighttpd"),
+	}
+}
+
 // getNginxVersion 获取 Nginx 版本
 func getNginxVersion(nginxPath string) string {
 	cmd := exec.Command(nginxPath, "-v")
@@ -0,0 +1,632 @@
+package webserver
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ApacheConfigurator Apache 配置器
+type ApacheConfigurator struct {
+	configPath string
+	layout     *apacheLayout
+	changes    *changeSet
+	reloadOpts config.ReloadConfig
+}
+
+// apacheLayout 不同发行版的 Apache 目录布局
+type apacheLayout struct {
+	name       string // debian, rhel, suse
+	serverRoot string // ServerRoot，相对 Include 路径以此为基准
+	mainConfig string // 主配置文件
+	siteDir    string // 站点配置写入目录
+	enabledDir string // 站点启用目录（仅 Debian）
+	confDir    string // 全局配置片段目录
+	confEnable string // 全局配置片段启用目录（仅 Debian）
+	service    string // systemd 服务名
+}
+
+// apacheLayouts 按检测顺序排列的已知布局
+var apacheLayouts = []apacheLayout{
+	{
+		name:       "debian",
+		serverRoot: "/etc/apache2",
+		mainConfig: "/etc/apache2/apache2.conf",
+		siteDir:    "/etc/apache2/sites-available",
+		enabledDir: "/etc/apache2/sites-enabled",
+		confDir:    "/etc/apache2/conf-available",
+		confEnable: "/etc/apache2/conf-enabled",
+		service:    "apache2",
+	},
+	{
+		name:       "rhel",
+		serverRoot: "/etc/httpd",
+		mainConfig: "/etc/httpd/conf/httpd.conf",
+		siteDir:    "/etc/httpd/conf.d",
+		confDir:    "/etc/httpd/conf.d",
+		service:    "httpd",
+	},
+	{
+		name:       "suse",
+		serverRoot: "/etc/apache2",
+		mainConfig: "/etc/apache2/httpd.conf",
+		siteDir:    "/etc/apache2/vhosts.d",
+		confDir:    "/etc/apache2/conf.d",
+		service:    "apache2",
+	},
+}
+
+// apacheVirtualHost 解析得到的 VirtualHost
+type apacheVirtualHost struct {
+	File          string
+	Addresses     []string
+	ServerName    string
+	ServerAliases []string
+	SSLEngine     bool
+	CertFile      string
+}
+
+// Configure 配置 Apache
+func (a *ApacheConfigurator) Configure(config *Config) error {
+	logger.Info("开始配置 Apache", "domain", config.Domain)
+	a.changes = newChangeSet(false)
+	a.reloadOpts = config.Reload
+	return a.configure(config)
+}
+
+// Plan 计算 Configure 将执行的修改
+func (a *ApacheConfigurator) Plan(config *Config) (*Plan, error) {
+	a.changes = newChangeSet(true)
+	a.reloadOpts = config.Reload
+	if err := a.configure(config); err != nil {
+		return nil, err
+	}
+
+	r, err := a.reloadStrategy()
+	if err != nil {
+		return nil, err
+	}
+	plan := a.changes.plan
+	plan.Commands = append(plan.Commands, r.planCommands()...)
+	return plan, nil
+}
+
+// configure 执行配置步骤，文件修改通过 a.changes 完成
+func (a *ApacheConfigurator) configure(config *Config) error {
+	// 1. 确定目录布局
+	if err := a.detectLayout(); err != nil {
+		return fmt.Errorf("查找 Apache 配置路径失败: %w", err)
+	}
+
+	// 检查重载方式，避免写入配置后才发现无法重载
+	if _, err := a.reloadStrategy(); err != nil {
+		return fmt.Errorf("重载配置无效: %w", err)
+	}
+
+	// 2. 启用所需模块
+	if err := a.enableModules(config); err != nil {
+		return fmt.Errorf("启用 Apache 模块失败: %w", err)
+	}
+
+	// 3. 创建站点配置
+	siteConfigPath, err := a.createSiteConfig(config)
+	if err != nil {
+		return fmt.Errorf("创建站点配置失败: %w", err)
+	}
+
+	// 4. 启用站点配置
+	if err := a.enableSite(siteConfigPath); err != nil {
+		return fmt.Errorf("启用站点配置失败: %w", err)
+	}
+
+	logger.Info("Apache 配置完成", "domain", config.Domain, "layout", a.layout.name)
+	return nil
+}
+
+// Test 测试 Apache 配置
+func (a *ApacheConfigurator) Test() error {
+	r, err := a.reloadStrategy()
+	if err != nil {
+		return err
+	}
+	if err := r.runTest(); err != nil {
+		return fmt.Errorf("Apache 配置测试失败: %w", err)
+	}
+
+	logger.Info("Apache 配置测试成功")
+	return nil
+}
+
+// Reload 重载 Apache 配置
+func (a *ApacheConfigurator) Reload() error {
+	r, err := a.reloadStrategy()
+	if err != nil {
+		return err
+	}
+	if err := r.runReload(); err != nil {
+		return fmt.Errorf("重载 Apache 失败: %w", err)
+	}
+
+	logger.Info("Apache 配置重载成功")
+	return nil
+}
+
+// testCommand 配置测试命令
+func (a *ApacheConfigurator) testCommand() []string {
+	return []string{apacheCtl(), "-t"}
+}
+
+// reloadStrategy 根据配置的重载方式创建重载器
+func (a *ApacheConfigurator) reloadStrategy() (*reloader, error) {
+	return newReloader(a.reloadOpts, a.service(), a.testCommand(), a.reloadCommand())
+}
+
+// reloadCommand 未配置重载方式时使用的重载命令
+func (a *ApacheConfigurator) reloadCommand() []string {
+	if _, err := exec.LookPath("systemctl"); err == nil {
+		return []string{"systemctl", "reload", a.service()}
+	}
+	return []string{apacheCtl(), "-k", "graceful"}
+}
+
+// service Apache 的 systemd 服务名
+func (a *ApacheConfigurator) service() string {
+	if a.layout != nil {
+		return a.layout.service
+	}
+	if _, err := os.Stat("/etc/httpd"); err == nil {
+		return "httpd"
+	}
+	return "apache2"
+}
+
+// Rollback 撤销最近一次 Configure 对文件的修改
+func (a *ApacheConfigurator) Rollback() ([]string, error) {
+	if a.changes == nil {
+		return nil, nil
+	}
+	return a.changes.rollback()
+}
+
+// GetConfigPath 获取配置路径
+func (a *ApacheConfigurator) GetConfigPath() string {
+	return a.configPath
+}
+
+// IsSSLEnabled 检查 SSL 是否已启用
+func (a *ApacheConfigurator) IsSSLEnabled(domain string) bool {
+	if err := a.detectLayout(); err != nil {
+		return false
+	}
+
+	for _, vhost := range parseApacheVirtualHosts(a.layout.mainConfig, a.layout.serverRoot) {
+		if vhost.SSLEngine && vhost.CertFile != "" && vhost.matches(domain) {
+			logger.Debug("找到已启用 SSL 的 VirtualHost", "domain", domain, "file", vhost.File)
+			return true
+		}
+	}
+
+	return false
+}
+
+// detectLayout 检测 Apache 目录布局
+func (a *ApacheConfigurator) detectLayout() error {
+	if a.layout != nil {
+		return nil
+	}
+
+	for i := range apacheLayouts {
+		layout := apacheLayouts[i]
+		if _, err := os.Stat(layout.mainConfig); err != nil {
+			continue
+		}
+		if _, err := os.Stat(layout.siteDir); err != nil {
+			continue
+		}
+
+		a.layout = &layout
+		a.configPath = layout.mainConfig
+		logger.Debug("检测到 Apache 目录布局", "layout", layout.name, "config", layout.mainConfig)
+		return nil
+	}
+
+	return fmt.Errorf("未找到 Apache 配置文件")
+}
+
+// enableModules 启用 mod_ssl、mod_rewrite 以及站点模板需要的模块
+func (a *ApacheConfigurator) enableModules(config *Config) error {
+	names, modules := apacheTemplateModules(config)
+
+	if a.layout.name == "debian" {
+		// a2enmod 和手动方式都会在 mods-enabled 中创建这些链接
+		for _, mod := range modules {
+			if err := a.changes.track(filepath.Join(a.layout.serverRoot, "mods-enabled", mod)); err != nil {
+				return err
+			}
+		}
+
+		if _, err := exec.LookPath("a2enmod"); err == nil {
+			output, err := a.changes.run("a2enmod", append([]string{"-q"}, names...)...)
+			if err != nil {
+				return fmt.Errorf("a2enmod 执行失败: %s", string(output))
+			}
+			return nil
+		}
+
+		// 没有 a2enmod 时手动创建模块链接
+		for _, mod := range modules {
+			src := filepath.Join(a.layout.serverRoot, "mods-available", mod)
+			dst := filepath.Join(a.layout.serverRoot, "mods-enabled", mod)
+			if _, err := os.Stat(src); err != nil {
+				continue
+			}
+			if _, err := os.Lstat(dst); err == nil {
+				continue
+			}
+			if err := a.changes.symlink(src, dst); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// RHEL/SUSE 的 mod_ssl 由软件包提供，只检查是否已加载
+	output, err := exec.Command(apacheCtl(), "-M").CombinedOutput()
+	if err != nil {
+		logger.Warn("无法获取 Apache 已加载模块列表", "error", err)
+		return nil
+	}
+	if !strings.Contains(string(output), "ssl_module") {
+		return fmt.Errorf("未加载 mod_ssl，请先安装 mod_ssl 软件包")
+	}
+	if !strings.Contains(string(output), "rewrite_module") {
+		logger.Warn("未加载 mod_rewrite，HTTP 到 HTTPS 的重定向将不会生效")
+	}
+	for _, name := range names {
+		if (strings.HasPrefix(name, "proxy") || name == "headers") && !strings.Contains(string(output), name+"_module") {
+			logger.Warn("站点模板需要的 Apache 模块未加载", "module", "mod_"+name)
+		}
+	}
+	return nil
+}
+
+// apacheTemplateModules 站点模板需要的模块，返回 a2enmod 使用的模块名和 mods-enabled 中的文件名
+func apacheTemplateModules(config *Config) ([]string, []string) {
+	names := []string{"ssl", "rewrite"}
+	modules := []string{"socache_shmcb.load", "ssl.load", "ssl.conf", "rewrite.load"}
+
+	headers := config.Security.HSTS.Enabled
+	switch config.Template {
+	case TemplatePHP:
+		names = append(names, "proxy", "proxy_fcgi")
+		modules = append(modules, "proxy.load", "proxy.conf", "proxy_fcgi.load")
+	case TemplateProxy:
+		names = append(names, "proxy", "proxy_http")
+		modules = append(modules, "proxy.load", "proxy.conf", "proxy_http.load")
+		headers = true
+	}
+	if headers {
+		names = append(names, "headers")
+		modules = append(modules, "headers.load")
+	}
+	return names, modules
+}
+
+// createSiteConfig 创建站点配置
+func (a *ApacheConfigurator) createSiteConfig(config *Config) (string, error) {
+	if len(strings.Fields(config.Domain)) == 0 {
+		return "", fmt.Errorf("域名不能为空")
+	}
+
+	configFile := filepath.Join(a.layout.siteDir, apacheSiteName(config)+".conf")
+
+	configContent, err := a.generateConfig(config)
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.changes.writeFile(configFile, []byte(configContent), 0644); err != nil {
+		return "", err
+	}
+
+	logger.Info("创建 Apache 站点配置", "configFile", configFile)
+
+	// 删除旧版本按第一个域名命名的站点配置，避免 VirtualHost 重复
+	for _, legacy := range a.legacySiteFiles(config) {
+		if err := a.disableSite(legacy); err != nil {
+			return "", err
+		}
+		if err := a.changes.remove(legacy); err != nil {
+			return "", err
+		}
+		logger.Info("删除旧的 Apache 站点配置", "configFile", legacy)
+	}
+	return configFile, nil
+}
+
+// legacySiteFiles 旧版本为该证书生成的站点配置
+// 旧版本以第一个域名命名（<域名>-ssl.conf），增减域名或调整顺序后名称会变化，
+// 所以检查证书中的每个域名，并且只认引用该证书目录的 AutoCert 生成的文件
+func (a *ApacheConfigurator) legacySiteFiles(config *Config) []string {
+	current := apacheSiteName(config)
+	certDir := filepath.Dir(config.FullchainPath)
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, domain := range strings.Fields(config.Domain) {
+		name := strings.ReplaceAll(domain, "*", "_wildcard") + "-ssl"
+		if name == current || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		path := filepath.Join(a.layout.siteDir, name+".conf")
+		if !strings.HasPrefix(readFileString(path), generatedMarker) {
+			continue
+		}
+		p := &apacheParser{visited: make(map[string]bool)}
+		p.parseFile(path)
+		for _, vhost := range p.vhosts {
+			if isUnderDir(vhost.CertFile, certDir) {
+				files = append(files, path)
+				break
+			}
+		}
+	}
+	return files
+}
+
+// generateConfig 生成 Apache 配置
+func (a *ApacheConfigurator) generateConfig(config *Config) (string, error) {
+	data, err := newTemplateData(config)
+	if err != nil {
+		return "", err
+	}
+	data.LegacyChain = apacheNeedsChainFile()
+	return renderTemplate("apache", apacheCommonTemplate, apacheTemplates, data)
+}
+
+// enableSite 启用站点配置
+func (a *ApacheConfigurator) enableSite(configFile string) error {
+	// 只有 Debian 布局需要显式启用站点，其他布局的 conf.d/vhosts.d 会被自动包含
+	if a.layout.enabledDir == "" {
+		return nil
+	}
+
+	linkPath := filepath.Join(a.layout.enabledDir, filepath.Base(configFile))
+	if err := a.changes.track(linkPath); err != nil {
+		return err
+	}
+
+	siteName := strings.TrimSuffix(filepath.Base(configFile), ".conf")
+	if _, err := exec.LookPath("a2ensite"); err == nil {
+		output, err := a.changes.run("a2ensite", "-q", siteName)
+		if err != nil {
+			return fmt.Errorf("a2ensite 执行失败: %s", string(output))
+		}
+		logger.Info("启用 Apache 站点", "site", siteName)
+		return nil
+	}
+
+	if err := a.changes.mkdirAll(a.layout.enabledDir); err != nil {
+		return err
+	}
+	if err := a.changes.symlink(configFile, linkPath); err != nil {
+		return err
+	}
+
+	logger.Info("启用 Apache 站点", "link", linkPath)
+	return nil
+}
+
+// matches 检查 VirtualHost 是否服务于指定域名
+func (v *apacheVirtualHost) matches(domain string) bool {
+	domain = strings.ToLower(domain)
+	if strings.EqualFold(v.ServerName, domain) {
+		return true
+	}
+
+	for _, alias := range v.ServerAliases {
+		alias = strings.ToLower(alias)
+		if alias == domain {
+			return true
+		}
+		// ServerAlias 支持 * 和 ? 通配符
+		if ok, _ := path.Match(alias, domain); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseApacheVirtualHosts 从主配置文件开始解析所有 VirtualHost，跟随 Include 指令
+func parseApacheVirtualHosts(mainConfig, serverRoot string) []apacheVirtualHost {
+	p := &apacheParser{
+		serverRoot: serverRoot,
+		visited:    make(map[string]bool),
+	}
+	p.parseFile(mainConfig)
+	return p.vhosts
+}
+
+// apacheParser 简单的 Apache 配置解析器
+type apacheParser struct {
+	serverRoot string
+	visited    map[string]bool
+	vhosts     []apacheVirtualHost
+	current    *apacheVirtualHost
+}
+
+// parseFile 解析单个配置文件
+func (p *apacheParser) parseFile(file string) {
+	abs, err := filepath.Abs(file)
+	if err != nil || p.visited[abs] {
+		return
+	}
+	p.visited[abs] = true
+
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var continued string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// 处理行尾的续行符
+		if strings.HasSuffix(line, "\\") {
+			continued += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		line = continued + line
+		continued = ""
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p.parseLine(file, line)
+	}
+}
+
+// parseLine 解析一行指令
+func (p *apacheParser) parseLine(file, line string) {
+	if strings.HasPrefix(line, "</") {
+		if strings.EqualFold(strings.Trim(line, "</> \t"), "VirtualHost") && p.current != nil {
+			p.vhosts = append(p.vhosts, *p.current)
+			p.current = nil
+		}
+		return
+	}
+
+	if strings.HasPrefix(line, "<") {
+		section := strings.Fields(strings.Trim(line, "<>"))
+		if len(section) > 0 && strings.EqualFold(section[0], "VirtualHost") {
+			p.current = &apacheVirtualHost{File: file, Addresses: section[1:]}
+		}
+		return
+	}
+
+	fields := strings.Fields(line)
+	directive := strings.ToLower(fields[0])
+	args := fields[1:]
+	for i, arg := range args {
+		args[i] = strings.Trim(arg, `"'`)
+	}
+
+	switch directive {
+	case "include", "includeoptional":
+		for _, arg := range args {
+			p.include(arg)
+		}
+		return
+	}
+
+	if p.current == nil || len(args) == 0 {
+		return
+	}
+
+	switch directive {
+	case "servername":
+		// ServerName 可以带协议和端口
+		name := strings.TrimPrefix(strings.TrimPrefix(args[0], "https://"), "http://")
+		if host, _, found := strings.Cut(name, ":"); found {
+			name = host
+		}
+		p.current.ServerName = name
+	case "serveralias":
+		p.current.ServerAliases = append(p.current.ServerAliases, args...)
+	case "sslengine":
+		p.current.SSLEngine = strings.EqualFold(args[0], "on")
+	case "sslcertificatefile":
+		p.current.CertFile = args[0]
+	}
+}
+
+// include 处理 Include/IncludeOptional，支持通配符和目录
+func (p *apacheParser) include(pattern string) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(p.serverRoot, pattern)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return
+	}
+
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			p.parseFile(match)
+			continue
+		}
+
+		// Include 目录时包含其中所有文件
+		entries, err := os.ReadDir(match)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				p.parseFile(filepath.Join(match, entry.Name()))
+			}
+		}
+	}
+}
+
+// apacheCtl 查找 Apache 控制命令
+func apacheCtl() string {
+	for _, name := range []string{"apache2ctl", "apachectl", "httpd"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name
+		}
+	}
+	return "apachectl"
+}
+
+// apacheVersionPattern 匹配 apachectl -v 输出中的版本号
+var apacheVersionPattern = regexp.MustCompile(`Apache/(\d+)\.(\d+)\.(\d+)`)
+
+// apacheNeedsChainFile Apache 2.4.8 之前的版本 SSLCertificateFile 不支持完整证书链
+func apacheNeedsChainFile() bool {
+	output, err := exec.Command(apacheCtl(), "-v").CombinedOutput()
+	if err != nil {
+		return false
+	}
+
+	m := apacheVersionPattern.FindStringSubmatch(string(output))
+	if m == nil {
+		return false
+	}
+
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	patch, _ := strconv.Atoi(m[3])
+
+	if major != 2 {
+		return major < 2
+	}
+	if minor != 4 {
+		return minor < 4
+	}
+	return patch < 8
+}
+
+// apacheSiteName 站点配置名称，与其他 Web 服务器一样按证书名称固定
+func apacheSiteName(config *Config) string {
+	return siteFileName(config) + "-ssl"
+}
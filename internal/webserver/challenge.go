@@ -0,0 +1,361 @@
+package webserver
+
+import (
+	"autocert/internal/logger"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// challengeSnippetName 共享 ACME 挑战配置的文件名
+const challengeSnippetName = "autocert-acme.conf"
+
+// ChallengeConfigurator 支持共享 ACME 挑战配置的配置器（Nginx、Apache）
+// 挑战配置从 config.ChallengeDir 提供 /.well-known/acme-challenge/，使所有站点都可以通过 webroot 方式验证
+type ChallengeConfigurator interface {
+	Configurator
+	// ConfigureChallenge 写入挑战配置，include 为 true 时在已有的 HTTP 站点中引用（Nginx）
+	// 返回是否修改了文件，有修改时需要 Test 和 Reload，失败时 Rollback
+	ConfigureChallenge(config *Config, include bool) (bool, error)
+	// PlanChallenge 计算 ConfigureChallenge 将执行的修改
+	PlanChallenge(config *Config, include bool) (*Plan, error)
+	// ChallengeReady 挑战配置是否已对 config.Domain 中的所有域名生效
+	// 未生效时 ACME 无法从挑战目录读取验证文件，需要使用其他验证方式
+	ChallengeReady(config *Config) (bool, error)
+}
+
+// nginxChallengeSnippet Nginx 挑战配置，在 server 块中 include
+func nginxChallengeSnippet(dir string) string {
+	return fmt.Sprintf(`# 由 AutoCert 生成：从 AutoCert 的挑战目录提供 ACME HTTP-01 验证文件
+# 在 server 块中引用: include snippets/%s;
+location ^~ /.well-known/acme-challenge/ {
+    default_type "text/plain";
+    root %s;
+}
+`, challengeSnippetName, dir)
+}
+
+// apacheChallengeSnippet Apache 挑战配置，全局的 Alias 对所有 VirtualHost 生效
+func apacheChallengeSnippet(dir string) string {
+	target := filepath.ToSlash(filepath.Join(dir, ".well-known", "acme-challenge")) + "/"
+	return fmt.Sprintf(`# 由 AutoCert 生成：所有站点从 AutoCert 的挑战目录提供 ACME HTTP-01 验证文件
+Alias /.well-known/acme-challenge/ "%s"
+<Directory "%s">
+    Options None
+    AllowOverride None
+    ForceType text/plain
+    Require all granted
+</Directory>
+`, target, target)
+}
+
+// ConfigureChallenge 写入 snippets/autocert-acme.conf，include 为 true 时在 HTTP server 块中引用
+func (n *NginxConfigurator) ConfigureChallenge(config *Config, include bool) (bool, error) {
+	n.changes = newChangeSet(false)
+	n.setup(config)
+	return n.configureChallenge(config.ChallengeDir, include)
+}
+
+// PlanChallenge 计算 ConfigureChallenge 将执行的修改
+func (n *NginxConfigurator) PlanChallenge(config *Config, include bool) (*Plan, error) {
+	n.changes = newChangeSet(true)
+	n.setup(config)
+	changed, err := n.configureChallenge(config.ChallengeDir, include)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := n.changes.plan
+	if changed {
+		r, err := n.reloadStrategy()
+		if err != nil {
+			return nil, err
+		}
+		plan.Commands = append(plan.Commands, r.planCommands()...)
+	}
+	return plan, nil
+}
+
+// challengeSnippetPath Nginx 挑战配置路径（主配置所在目录的 snippets 中）
+func (n *NginxConfigurator) challengeSnippetPath() string {
+	return filepath.Join(filepath.Dir(n.configPath), "snippets", challengeSnippetName)
+}
+
+// configureChallenge 写入挑战配置，并列出或修改还没有引用它的 HTTP server 块
+func (n *NginxConfigurator) configureChallenge(dir string, include bool) (bool, error) {
+	if dir == "" {
+		return false, fmt.Errorf("未设置 ACME 挑战目录")
+	}
+	if _, err := n.reloadStrategy(); err != nil {
+		return false, fmt.Errorf("重载配置无效: %w", err)
+	}
+	if err := n.findConfigPath(); err != nil {
+		return false, fmt.Errorf("查找 Nginx 配置路径失败: %w", err)
+	}
+
+	changed := false
+	snippet := n.challengeSnippetPath()
+	content := nginxChallengeSnippet(dir)
+	if readFileString(snippet) != content {
+		if err := n.changes.mkdirAll(filepath.Dir(snippet)); err != nil {
+			return false, err
+		}
+		if err := n.changes.writeFile(snippet, []byte(content), 0644); err != nil {
+			return false, err
+		}
+		logger.Info("写入 Nginx ACME 挑战配置", "file", snippet)
+		changed = true
+	}
+
+	parsed, err := loadNginxConfig(n.configPath)
+	if err != nil {
+		return false, fmt.Errorf("解析 Nginx 配置失败: %w", err)
+	}
+
+	// 按文件分组，保持解析顺序
+	byFile := make(map[string][]nginxServer)
+	var files []*nginxConfigFile
+	for _, server := range parsed.Servers() {
+		if !server.servesHTTP() || server.servesChallenge() {
+			continue
+		}
+		if _, ok := byFile[server.File.Path]; !ok {
+			files = append(files, server.File)
+		}
+		byFile[server.File.Path] = append(byFile[server.File.Path], server)
+	}
+
+	if !include {
+		for _, file := range files {
+			for _, server := range byFile[file.Path] {
+				logger.Info("HTTP server 块没有引用 ACME 挑战配置，可以使用 --include 添加",
+					"file", file.Path, "serverName", strings.Join(server.serverNames(), " "))
+			}
+		}
+		return changed, nil
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			return changed, err
+		}
+		if string(data) != file.Content {
+			return changed, fmt.Errorf("配置文件 %s 在解析后被修改", file.Path)
+		}
+
+		var edits []nginxEdit
+		for _, server := range byFile[file.Path] {
+			edits = append(edits, planChallengeInclude(file.Content, server, snippet)...)
+		}
+		newContent := applyEdits(file.Content, edits)
+		if newContent == file.Content {
+			continue
+		}
+
+		backupPath, err := n.changes.updateFile(file.Path, []byte(newContent))
+		if err != nil {
+			return changed, err
+		}
+		logger.Info("在 HTTP server 块中引用 ACME 挑战配置", "configFile", file.Path, "backup", backupPath)
+		changed = true
+	}
+	return changed, nil
+}
+
+// planChallengeInclude 在 server 块中添加 include
+// server 级别的 return 会在匹配 location 之前执行，移到 location / 中，使挑战路径不被重定向
+func planChallengeInclude(content string, server nginxServer, snippet string) []nginxEdit {
+	block := server.Directive
+	indent := blockIndent(content, block)
+	listens := server.find("listen")
+
+	returns := server.find("return")
+	if len(returns) > 0 {
+		for _, d := range server.find("location") {
+			if len(d.Args) == 1 && d.Args[0] == "/" {
+				logger.Warn("server 块中同时有 return 和 location /，跳过",
+					"file", server.File.Path, "serverName", strings.Join(server.serverNames(), " "))
+				return nil
+			}
+		}
+	}
+
+	pos := insertPosition(content, block, listens)
+	edits := []nginxEdit{{start: pos, end: pos, text: fmt.Sprintf("%sinclude %s;\n", indent, snippet)}}
+	for _, d := range returns {
+		text := fmt.Sprintf("location / {\n%s    %s\n%s}", indent, content[d.Start:d.End], indent)
+		edits = append(edits, nginxEdit{start: d.Start, end: d.End, text: text})
+	}
+	return edits
+}
+
+// servesHTTP server 块是否监听 80 端口的 HTTP（没有 listen 时默认监听 80）
+func (s *nginxServer) servesHTTP() bool {
+	listens := s.find("listen")
+	if len(listens) == 0 {
+		return true
+	}
+	for _, d := range listens {
+		if listenIsHTTP(d.Args) {
+			return true
+		}
+	}
+	return false
+}
+
+// includesChallenge server 块是否引用了挑战配置
+func (s *nginxServer) includesChallenge() bool {
+	for _, d := range s.find("include") {
+		if len(d.Args) == 1 && filepath.Base(d.Args[0]) == challengeSnippetName {
+			return true
+		}
+	}
+	return false
+}
+
+// servesChallenge server 块是否已经引用挑战配置或自行处理挑战路径
+func (s *nginxServer) servesChallenge() bool {
+	if s.includesChallenge() {
+		return true
+	}
+	for _, d := range s.find("location") {
+		for _, arg := range d.Args {
+			if strings.Contains(arg, "/.well-known/acme-challenge") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ChallengeReady 每个域名匹配的 HTTP server 块是否都引用了挑战配置
+// 自行处理挑战路径的 location 不一定使用 AutoCert 的挑战目录，不算生效
+func (n *NginxConfigurator) ChallengeReady(config *Config) (bool, error) {
+	n.setup(config)
+	if readFileString(n.challengeSnippetPath()) != nginxChallengeSnippet(config.ChallengeDir) {
+		return false, nil
+	}
+	parsed, err := n.loadConfig()
+	if err != nil {
+		return false, fmt.Errorf("解析 Nginx 配置失败: %w", err)
+	}
+
+	for _, domain := range strings.Fields(config.Domain) {
+		found := false
+		for _, server := range parsed.Servers() {
+			if !server.servesHTTP() || !server.Matches(domain) {
+				continue
+			}
+			if !server.includesChallenge() {
+				return false, nil
+			}
+			found = true
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ConfigureChallenge 写入全局的 Alias 配置，对所有 VirtualHost 生效，include 参数不需要
+func (a *ApacheConfigurator) ConfigureChallenge(config *Config, include bool) (bool, error) {
+	a.changes = newChangeSet(false)
+	a.reloadOpts = config.Reload
+	return a.configureChallenge(config.ChallengeDir)
+}
+
+// PlanChallenge 计算 ConfigureChallenge 将执行的修改
+func (a *ApacheConfigurator) PlanChallenge(config *Config, include bool) (*Plan, error) {
+	a.changes = newChangeSet(true)
+	a.reloadOpts = config.Reload
+	changed, err := a.configureChallenge(config.ChallengeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := a.changes.plan
+	if changed {
+		r, err := a.reloadStrategy()
+		if err != nil {
+			return nil, err
+		}
+		plan.Commands = append(plan.Commands, r.planCommands()...)
+	}
+	return plan, nil
+}
+
+// ChallengeReady 全局的 Alias 配置已写入并启用时对所有 VirtualHost 生效
+func (a *ApacheConfigurator) ChallengeReady(config *Config) (bool, error) {
+	if err := a.detectLayout(); err != nil {
+		return false, fmt.Errorf("查找 Apache 配置路径失败: %w", err)
+	}
+	snippet := filepath.Join(a.layout.confDir, challengeSnippetName)
+	if readFileString(snippet) != apacheChallengeSnippet(config.ChallengeDir) {
+		return false, nil
+	}
+	if a.layout.confEnable != "" {
+		if _, err := os.Stat(filepath.Join(a.layout.confEnable, challengeSnippetName)); err != nil {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// configureChallenge 写入并启用 autocert-acme.conf
+func (a *ApacheConfigurator) configureChallenge(dir string) (bool, error) {
+	if dir == "" {
+		return false, fmt.Errorf("未设置 ACME 挑战目录")
+	}
+	if err := a.detectLayout(); err != nil {
+		return false, fmt.Errorf("查找 Apache 配置路径失败: %w", err)
+	}
+	if _, err := a.reloadStrategy(); err != nil {
+		return false, fmt.Errorf("重载配置无效: %w", err)
+	}
+
+	changed := false
+	snippet := filepath.Join(a.layout.confDir, challengeSnippetName)
+	content := apacheChallengeSnippet(dir)
+	if readFileString(snippet) != content {
+		if err := a.changes.mkdirAll(a.layout.confDir); err != nil {
+			return false, err
+		}
+		if err := a.changes.writeFile(snippet, []byte(content), 0644); err != nil {
+			return false, err
+		}
+		logger.Info("写入 Apache ACME 挑战配置", "file", snippet)
+		changed = true
+	}
+
+	// 只有 Debian 布局需要启用 conf-available 中的配置
+	if a.layout.confEnable == "" {
+		return changed, nil
+	}
+	linkPath := filepath.Join(a.layout.confEnable, challengeSnippetName)
+	if _, err := os.Lstat(linkPath); err == nil {
+		return changed, nil
+	}
+
+	if err := a.changes.track(linkPath); err != nil {
+		return changed, err
+	}
+	if _, err := exec.LookPath("a2enconf"); err == nil {
+		output, err := a.changes.run("a2enconf", "-q", strings.TrimSuffix(challengeSnippetName, ".conf"))
+		if err != nil {
+			return changed, fmt.Errorf("a2enconf 执行失败: %s", string(output))
+		}
+	} else {
+		if err := a.changes.mkdirAll(a.layout.confEnable); err != nil {
+			return changed, err
+		}
+		if err := a.changes.symlink(snippet, linkPath); err != nil {
+			return changed, err
+		}
+	}
+	logger.Info("启用 Apache ACME 挑战配置", "link", linkPath)
+	return true, nil
+}
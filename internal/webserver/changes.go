@@ -0,0 +1,266 @@
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Plan 配置器将要执行的修改
+type Plan struct {
+	Dirs     []string         // 将创建的目录
+	Files    []PlannedFile    // 将写入的文件
+	Symlinks []PlannedSymlink // 将创建的符号链接
+	Removed  []string         // 将删除的文件或符号链接
+	Commands []string         // 将执行的命令（包括配置测试和重载）
+}
+
+// PlannedFile 将写入的文件
+type PlannedFile struct {
+	Path    string
+	Exists  bool
+	Old     string
+	New     string
+	Comment string // 例如备份位置
+}
+
+// PlannedSymlink 将创建的符号链接
+type PlannedSymlink struct {
+	Path   string
+	Target string
+	Old    string // 原有链接目标，不存在时为空
+}
+
+// Merge 将另一个计划的修改追加到本计划
+func (p *Plan) Merge(other *Plan) {
+	if other == nil {
+		return
+	}
+	p.Dirs = append(p.Dirs, other.Dirs...)
+	p.Files = append(p.Files, other.Files...)
+	p.Symlinks = append(p.Symlinks, other.Symlinks...)
+	p.Removed = append(p.Removed, other.Removed...)
+	p.Commands = append(p.Commands, other.Commands...)
+}
+
+// Diff 将计划输出为 unified diff 和命令列表
+func (p *Plan) Diff() string {
+	var sb strings.Builder
+
+	for _, f := range p.Files {
+		oldName := "a" + filepath.ToSlash(f.Path)
+		if !f.Exists {
+			oldName = "/dev/null"
+		}
+		diff := unifiedDiff(oldName, "b"+filepath.ToSlash(f.Path), f.Old, f.New)
+		if diff == "" {
+			continue
+		}
+		if f.Comment != "" {
+			fmt.Fprintf(&sb, "# %s\n", f.Comment)
+		}
+		sb.WriteString(diff)
+		sb.WriteString("\n")
+	}
+
+	if len(p.Dirs) > 0 {
+		sb.WriteString("# 创建目录\n")
+		for _, d := range p.Dirs {
+			fmt.Fprintf(&sb, "mkdir -p %s\n", d)
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(p.Symlinks) > 0 {
+		sb.WriteString("# 符号链接\n")
+		for _, l := range p.Symlinks {
+			if l.Old != "" {
+				fmt.Fprintf(&sb, "ln -sfn %s %s  # 原链接目标: %s\n", l.Target, l.Path, l.Old)
+			} else {
+				fmt.Fprintf(&sb, "ln -s %s %s\n", l.Target, l.Path)
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(p.Removed) > 0 {
+		sb.WriteString("# 删除文件\n")
+		for _, r := range p.Removed {
+			if info, err := os.Stat(r); err == nil && info.IsDir() {
+				sb.WriteString(commandString("rm", "-r", r) + "\n")
+			} else {
+				sb.WriteString(commandString("rm", r) + "\n")
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(p.Commands) > 0 {
+		sb.WriteString("# 执行命令\n")
+		for _, c := range p.Commands {
+			sb.WriteString(c)
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// changeSet 配置器对文件系统的修改
+// 正常模式下写入前记录快照以便回滚，计划模式下只记录到 Plan 中
+type changeSet struct {
+	dryRun   bool
+	snapshot *snapshot
+	plan     *Plan
+}
+
+// newChangeSet 创建修改集合
+func newChangeSet(dryRun bool) *changeSet {
+	return &changeSet{
+		dryRun:   dryRun,
+		snapshot: newSnapshot(),
+		plan:     &Plan{},
+	}
+}
+
+// mkdirAll 创建目录
+func (c *changeSet) mkdirAll(dir string) error {
+	if !c.dryRun {
+		if err := c.snapshot.trackDir(dir); err != nil {
+			return err
+		}
+		return os.MkdirAll(dir, 0755)
+	}
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		c.plan.Dirs = append(c.plan.Dirs, dir)
+	}
+	return nil
+}
+
+// writeFile 写入（新建或覆盖）文件
+func (c *changeSet) writeFile(path string, data []byte, perm os.FileMode) error {
+	if c.dryRun {
+		c.planFile(path, data, "")
+		return nil
+	}
+	if err := c.snapshot.track(path); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, perm)
+}
+
+// updateFile 修改已有文件：先备份，并保留原有权限
+func (c *changeSet) updateFile(path string, data []byte) (string, error) {
+	if c.dryRun {
+		c.planFile(path, data, "修改前会备份到 AutoCert 配置目录")
+		return "", nil
+	}
+
+	backupPath, err := backupConfigFile(path)
+	if err != nil {
+		return "", fmt.Errorf("备份配置文件失败: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if err := c.snapshot.track(path); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, info.Mode().Perm()); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// symlink 创建符号链接，已存在时替换
+func (c *changeSet) symlink(target, link string) error {
+	old, _ := os.Readlink(link)
+
+	if c.dryRun {
+		if old != target {
+			c.plan.Symlinks = append(c.plan.Symlinks, PlannedSymlink{Path: link, Target: target, Old: old})
+		}
+		return nil
+	}
+
+	if err := c.snapshot.track(link); err != nil {
+		return err
+	}
+	os.Remove(link)
+	return os.Symlink(target, link)
+}
+
+// remove 删除文件或符号链接，不存在时忽略
+func (c *changeSet) remove(path string) error {
+	if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if c.dryRun {
+		c.plan.Removed = append(c.plan.Removed, path)
+		return nil
+	}
+	if err := c.snapshot.track(path); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// track 记录将由外部命令修改的文件（例如 a2enmod 创建的链接）
+func (c *changeSet) track(path string) error {
+	if c.dryRun {
+		return nil
+	}
+	return c.snapshot.track(path)
+}
+
+// run 执行会修改配置的命令
+func (c *changeSet) run(name string, args ...string) ([]byte, error) {
+	if c.dryRun {
+		c.plan.Commands = append(c.plan.Commands, commandString(name, args...))
+		return nil, nil
+	}
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+// rollback 撤销所有修改
+func (c *changeSet) rollback() ([]string, error) {
+	if c.dryRun {
+		return nil, nil
+	}
+	return c.snapshot.restore()
+}
+
+// planFile 记录将写入的文件，同一文件多次写入时合并
+func (c *changeSet) planFile(path string, data []byte, comment string) {
+	for i := range c.plan.Files {
+		if c.plan.Files[i].Path == path {
+			c.plan.Files[i].New = string(data)
+			return
+		}
+	}
+
+	f := PlannedFile{Path: path, New: string(data)}
+	if old, err := os.ReadFile(path); err == nil {
+		f.Exists = true
+		f.Old = string(old)
+		f.Comment = comment
+	}
+	c.plan.Files = append(c.plan.Files, f)
+}
+
+// commandString 命令的可读形式，包含空格的参数加上单引号
+func commandString(name string, args ...string) string {
+	parts := []string{name}
+	for _, a := range args {
+		if strings.ContainsAny(a, " \t") {
+			a = "'" + a + "'"
+		}
+		parts = append(parts, a)
+	}
+	return strings.Join(parts, " ")
+}
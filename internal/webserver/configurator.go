@@ -1,25 +1,95 @@
 package webserver
 
 import (
+	"autocert/internal/config"
 	"autocert/internal/logger"
-	"bufio"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"sort"
 	"strings"
-	"text/template"
 )
 
 // Config Web 服务器配置
 type Config struct {
-	Type       string // nginx, apache, iis
-	Domain     string
-	CertPath   string
-	KeyPath    string
-	ConfigPath string
-	WebRoot    string
+	Type          string // nginx, apache, haproxy, traefik, lighttpd, iis, postfix, dovecot
+	Name          string // 证书名称（证书目录名）
+	Domain        string
+	CertPath      string // 仅叶子证书
+	KeyPath       string
+	ChainPath     string // 中间证书链
+	FullchainPath string // 叶子证书 + 中间证书链，服务器配置应引用此文件
+	CombinedPath  string // 私钥 + 完整证书链（HAProxy）
+	ConfigPath    string // 主配置文件路径，为空时自动查找
+	Binary        string // 可执行文件路径，为空时自动查找
+	WebRoot       string
+	ChallengeDir  string // ACME HTTP-01 验证文件目录
+	Redirect      bool   // 是否将 HTTP 重定向到 HTTPS
+	Template      string // 站点模板：内置模板名称或自定义模板文件路径
+	Upstream      string // 模板使用的上游地址
+	Reload        config.ReloadConfig
+	Security      config.SecurityConfig
+	HAProxy       config.HAProxyConfig
+	Traefik       config.TraefikConfig
+}
+
+// siteFileName 站点配置和证书文件的名称，按证书名称（证书目录名）固定，续期和增减域名时不变
+// 泛域名证书的 * 替换为 _wildcard，未设置证书名称时使用第一个域名
+func siteFileName(config *Config) string {
+	name := config.Name
+	if name == "" {
+		name = strings.Fields(config.Domain)[0]
+	}
+	return strings.ReplaceAll(name, "*", "_wildcard")
+}
+
+// generatedMarker 内置模板生成的站点配置的第一行，用于识别可以由 AutoCert 删除或替换的文件
+const generatedMarker = "# AutoCert 自动生成的配置"
+
+// isLegacySite 是否为旧版本为该证书生成的 Nginx 站点配置
+// 旧版本以空格分隔的全部域名作为文件名，例如 "example.com www.example.com"
+// 域名顺序可能与证书中的不同，按域名集合比较
+func isLegacySite(path string, config *Config) bool {
+	name := strings.TrimSuffix(filepath.Base(path), ".conf")
+	if name == siteFileName(config) || !sameDomainSet(strings.Fields(name), strings.Fields(config.Domain)) {
+		return false
+	}
+	return strings.HasPrefix(readFileString(path), generatedMarker)
+}
+
+// legacySiteFiles 站点目录中旧版本为该证书生成的配置
+func legacySiteFiles(dir string, config *Config) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() && isLegacySite(path, config) {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// sameDomainSet 两组域名是否相同（忽略顺序和大小写）
+func sameDomainSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	normalize := func(domains []string) []string {
+		result := make([]string, len(domains))
+		for i, d := range domains {
+			result[i] = strings.ToLower(d)
+		}
+		sort.Strings(result)
+		return result
+	}
+	return slices.Equal(normalize(a), normalize(b))
 }
 
 // Configurator Web 服务器配置器接口
@@ -29,6 +99,10 @@ type Configurator interface {
 	Reload() error
 	GetConfigPath() string
 	IsSSLEnabled(domain string) bool
+	// Rollback 撤销最近一次 Configure 对文件的修改，返回被撤销的修改
+	Rollback() ([]string, error)
+	// Plan 计算 Configure 将执行的修改，不写入任何文件
+	Plan(config *Config) (*Plan, error)
 }
 
 // NewConfigurator 创建配置器
@@ -38,8 +112,18 @@ func NewConfigurator(serverType string) (Configurator, error) {
 		return &NginxConfigurator{}, nil
 	case "apache":
 		return &ApacheConfigurator{}, nil
+	case "haproxy":
+		return &HAProxyConfigurator{}, nil
+	case "traefik":
+		return &TraefikConfigurator{}, nil
+	case "lighttpd":
+		return &LighttpdConfigurator{}, nil
 	case "iis":
 		return &IISConfigurator{}, nil
+	case "postfix":
+		return &PostfixConfigurator{}, nil
+	case "dovecot":
+		return &DovecotConfigurator{}, nil
 	default:
 		return nil, fmt.Errorf("不支持的 Web 服务器类型: %s", serverType)
 	}
@@ -48,24 +132,91 @@ func NewConfigurator(serverType string) (Configurator, error) {
 // NginxConfigurator Nginx 配置器
 type NginxConfigurator struct {
 	configPath string
+	binary     *nginxBinary
+	layout     *nginxLayout
+	changes    *changeSet
+	reloadOpts config.ReloadConfig
 }
 
 // Configure 配置 Nginx
 func (n *NginxConfigurator) Configure(config *Config) error {
 	logger.Info("开始配置 Nginx", "domain", config.Domain)
+	n.changes = newChangeSet(false)
+	n.setup(config)
+	return n.configure(config)
+}
+
+// Plan 计算 Configure 将执行的修改
+func (n *NginxConfigurator) Plan(config *Config) (*Plan, error) {
+	n.changes = newChangeSet(true)
+	n.setup(config)
+	if err := n.configure(config); err != nil {
+		return nil, err
+	}
+
+	r, err := n.reloadStrategy()
+	if err != nil {
+		return nil, err
+	}
+	plan := n.changes.plan
+	plan.Commands = append(plan.Commands, r.planCommands()...)
+	return plan, nil
+}
+
+// setup 保存配置中的路径和重载方式
+func (n *NginxConfigurator) setup(config *Config) {
+	n.reloadOpts = config.Reload
+	n.configPath = config.ConfigPath
+	binary := findNginxBinary(config.Binary)
+	n.binary = &binary
+}
+
+// bin 可执行文件，OpenResty、Tengine 使用各自的可执行文件和服务名
+func (n *NginxConfigurator) bin() nginxBinary {
+	if n.binary == nil {
+		binary := findNginxBinary("")
+		n.binary = &binary
+	}
+	return *n.binary
+}
+
+// configure 执行配置步骤，文件修改通过 n.changes 完成
+func (n *NginxConfigurator) configure(config *Config) error {
+	// 先检查重载方式，避免写入配置后才发现无法重载
+	if _, err := n.reloadStrategy(); err != nil {
+		return fmt.Errorf("重载配置无效: %w", err)
+	}
 
 	// 1. 确定配置文件路径
 	if err := n.findConfigPath(); err != nil {
 		return fmt.Errorf("查找 Nginx 配置路径失败: %w", err)
 	}
 
-	// 2. 创建站点配置
+	// 2. 优先在已有的 server 块中添加 SSL 配置，保留原有的代理、PHP 等配置
+	modified, err := n.configureExistingSites(config)
+	if err != nil {
+		return fmt.Errorf("更新已有站点配置失败: %w", err)
+	}
+	if len(modified) > 0 {
+		logger.Info("Nginx 配置完成", "domain", config.Domain, "files", modified)
+		return nil
+	}
+
+	// 3. 没有找到已有站点时，在主配置实际包含的目录中创建新的站点配置
+	parsed, err := loadNginxConfig(n.configPath)
+	if err != nil {
+		return fmt.Errorf("解析 Nginx 配置失败: %w", err)
+	}
+	if err := n.detectLayout(parsed); err != nil {
+		return fmt.Errorf("检测 Nginx 站点目录失败: %w", err)
+	}
+
 	siteConfigPath, err := n.createSiteConfig(config)
 	if err != nil {
 		return fmt.Errorf("创建站点配置失败: %w", err)
 	}
 
-	// 3. 启用站点配置
+	// 4. 启用站点配置
 	if err := n.enableSite(siteConfigPath); err != nil {
 		return fmt.Errorf("启用站点配置失败: %w", err)
 	}
@@ -76,10 +227,12 @@ func (n *NginxConfigurator) Configure(config *Config) error {
 
 // Test 测试 Nginx 配置
 func (n *NginxConfigurator) Test() error {
-	cmd := exec.Command("nginx", "-t")
-	output, err := cmd.CombinedOutput()
+	r, err := n.reloadStrategy()
 	if err != nil {
-		return fmt.Errorf("Nginx 配置测试失败: %s", string(output))
+		return err
+	}
+	if err := r.runTest(); err != nil {
+		return fmt.Errorf("Nginx 配置测试失败: %w", err)
 	}
 	logger.Info("Nginx 配置测试成功")
 	return nil
@@ -87,26 +240,48 @@ func (n *NginxConfigurator) Test() error {
 
 // Reload 重载 Nginx 配置
 func (n *NginxConfigurator) Reload() error {
-	var cmd *exec.Cmd
+	r, err := n.reloadStrategy()
+	if err != nil {
+		return err
+	}
+	if err := r.runReload(); err != nil {
+		return fmt.Errorf("重载 Nginx 失败: %w", err)
+	}
 
+	logger.Info("Nginx 配置重载成功")
+	return nil
+}
+
+// testCommand 配置测试命令
+func (n *NginxConfigurator) testCommand() []string {
+	return []string{n.bin().path, "-t"}
+}
+
+// reloadStrategy 根据配置的重载方式创建重载器
+func (n *NginxConfigurator) reloadStrategy() (*reloader, error) {
+	return newReloader(n.reloadOpts, n.bin().unit, n.testCommand(), n.reloadCommand())
+}
+
+// reloadCommand 未配置重载方式时使用的重载命令
+func (n *NginxConfigurator) reloadCommand() []string {
+	binary := n.bin()
 	if runtime.GOOS == "windows" {
-		cmd = exec.Command("nginx", "-s", "reload")
-	} else {
-		// 尝试使用 systemctl
-		if _, err := exec.LookPath("systemctl"); err == nil {
-			cmd = exec.Command("systemctl", "reload", "nginx")
-		} else {
-			cmd = exec.Command("nginx", "-s", "reload")
-		}
+		return []string{binary.path, "-s", "reload"}
 	}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("重载 Nginx 失败: %s", string(output))
+	// 尝试使用 systemctl
+	if _, err := exec.LookPath("systemctl"); err == nil {
+		return []string{"systemctl", "reload", binary.unit}
 	}
+	return []string{binary.path, "-s", "reload"}
+}
 
-	logger.Info("Nginx 配置重载成功")
-	return nil
+// Rollback 撤销最近一次 Configure 对文件的修改
+func (n *NginxConfigurator) Rollback() ([]string, error) {
+	if n.changes == nil {
+		return nil, nil
+	}
+	return n.changes.rollback()
 }
 
 // GetConfigPath 获取配置路径
@@ -116,11 +291,14 @@ func (n *NginxConfigurator) GetConfigPath() string {
 
 // IsSSLEnabled 检查 SSL 是否已启用
 func (n *NginxConfigurator) IsSSLEnabled(domain string) bool {
-	// 查找域名的配置文件
-	configFiles := n.findSiteConfigs()
+	parsed, err := n.loadConfig()
+	if err != nil {
+		logger.Debug("解析 Nginx 配置失败", "error", err)
+		return false
+	}
 
-	for _, configFile := range configFiles {
-		if n.checkSSLInConfig(configFile, domain) {
+	for _, server := range parsed.ServersFor(domain) {
+		if server.hasSSL() && server.hasCertificate() {
 			return true
 		}
 	}
@@ -128,21 +306,32 @@ func (n *NginxConfigurator) IsSSLEnabled(domain string) bool {
 	return false
 }
 
+// loadConfig 解析完整的 Nginx 配置
+// 优先从主配置文件开始跟随 include 解析，找不到主配置文件时使用 nginx -T 的输出
+func (n *NginxConfigurator) loadConfig() (*nginxConfig, error) {
+	if n.configPath == "" {
+		if err := n.findConfigPath(); err != nil {
+			return loadNginxFromBinary(n.bin().path)
+		}
+	}
+	return loadNginxConfig(n.configPath)
+}
+
 // findConfigPath 查找 Nginx 配置路径
+// 优先使用配置的路径和 nginx -V 中编译时指定的 --conf-path，找不到时尝试常见路径
 func (n *NginxConfigurator) findConfigPath() error {
-	var configPaths []string
-
-	if runtime.GOOS == "windows" {
-		configPaths = []string{
-			`C:\nginx\conf\nginx.conf`,
-			`C:\Program Files\nginx\conf\nginx.conf`,
+	if n.configPath != "" {
+		if _, err := os.Stat(n.configPath); err != nil {
+			return fmt.Errorf("配置文件 %s 不可用: %w", n.configPath, err)
 		}
+		return nil
+	}
+
+	configPaths := defaultNginxConfigPaths()
+	if _, confPath, err := nginxBuildPaths(n.bin().path); err == nil {
+		configPaths = append([]string{confPath}, configPaths...)
 	} else {
-		configPaths = []string{
-			"/etc/nginx/nginx.conf",
-			"/usr/local/nginx/conf/nginx.conf",
-			"/usr/local/etc/nginx/nginx.conf",
-		}
+		logger.Debug("无法获取 Nginx 编译参数", "error", err)
 	}
 
 	for _, path := range configPaths {
@@ -157,19 +346,10 @@ func (n *NginxConfigurator) findConfigPath() error {
 
 // createSiteConfig 创建站点配置
 func (n *NginxConfigurator) createSiteConfig(config *Config) (string, error) {
-	var configDir string
-	var configFile string
-
-	if runtime.GOOS == "windows" {
-		configDir = filepath.Dir(n.configPath)
-		configFile = filepath.Join(configDir, "conf.d", config.Domain+".conf")
-	} else {
-		configDir = "/etc/nginx/sites-available"
-		configFile = filepath.Join(configDir, config.Domain)
-	}
+	configFile := n.layout.siteFile(siteFileName(config))
 
 	// 确保配置目录存在
-	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+	if err := n.changes.mkdirAll(filepath.Dir(configFile)); err != nil {
 		return "", err
 	}
 
@@ -180,91 +360,56 @@ func (n *NginxConfigurator) createSiteConfig(config *Config) (string, error) {
 	}
 
 	// 写入配置文件
-	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+	if err := n.changes.writeFile(configFile, []byte(configContent), 0644); err != nil {
 		return "", err
 	}
 
 	logger.Info("创建 Nginx 站点配置", "configFile", configFile)
+
+	// 删除旧版本按全部域名命名的站点配置，避免 server_name 重复
+	for _, legacy := range legacySiteFiles(filepath.Dir(configFile), config) {
+		if legacy == configFile {
+			continue
+		}
+		if n.layout.enabledDir != "" {
+			if err := n.changes.remove(filepath.Join(n.layout.enabledDir, filepath.Base(legacy))); err != nil {
+				return "", err
+			}
+		}
+		if err := n.changes.remove(legacy); err != nil {
+			return "", err
+		}
+		logger.Info("删除旧的 Nginx 站点配置", "configFile", legacy)
+	}
 	return configFile, nil
 }
 
 // generateConfig 生成 Nginx 配置
 func (n *NginxConfigurator) generateConfig(config *Config) (string, error) {
-	tmpl := `# AutoCert 自动生成的配置
-server {
-    listen 80;
-    server_name {{.Domain}};
-    
-    # 重定向 HTTP 到 HTTPS
-    return 301 https://$server_name$request_uri;
-}
-
-server {
-    listen 443 ssl http2;
-    server_name {{.Domain}};
-    
-    # SSL 证书配置
-    ssl_certificate {{.CertPath}};
-    ssl_certificate_key {{.KeyPath}};
-    
-    # SSL 安全配置
-    ssl_protocols TLSv1.2 TLSv1.3;
-    ssl_prefer_server_ciphers on;
-    ssl_ciphers ECDHE-RSA-AES256-GCM-SHA384:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-RSA-AES256-SHA384:ECDHE-RSA-AES128-SHA256;
-    ssl_session_cache shared:SSL:10m;
-    ssl_session_timeout 10m;
-    
-    # 网站根目录
-    root {{.WebRoot}};
-    index index.html index.htm index.php;
-    
-    # 通用配置
-    location / {
-        try_files $uri $uri/ =404;
-    }
-    
-    # ACME 挑战目录
-    location ^~ /.well-known/acme-challenge/ {
-        default_type "text/plain";
-        root {{.WebRoot}};
-    }
-}
-`
-
-	t, err := template.New("nginx").Parse(tmpl)
+	data, err := newTemplateData(config)
 	if err != nil {
 		return "", err
 	}
-
-	var result strings.Builder
-	if err := t.Execute(&result, config); err != nil {
-		return "", err
-	}
-
-	return result.String(), nil
+	return renderTemplate("nginx", nginxCommonTemplate, nginxTemplates, data)
 }
 
 // enableSite 启用站点配置
 func (n *NginxConfigurator) enableSite(configFile string) error {
-	if runtime.GOOS == "windows" {
-		// Windows 下通常配置文件直接放在 conf.d 目录
+	// 只有 sites-available/sites-enabled 布局需要创建符号链接，其他布局的目录直接被 include
+	if n.layout.enabledDir == "" {
 		return nil
 	}
 
-	// Linux 下需要创建符号链接
-	sitesEnabled := "/etc/nginx/sites-enabled"
+	sitesEnabled := n.layout.enabledDir
 	linkPath := filepath.Join(sitesEnabled, filepath.Base(configFile))
 
 	// 确保 sites-enabled 目录存在
-	if err := os.MkdirAll(sitesEnabled, 0755); err != nil {
+	if err := n.changes.mkdirAll(sitesEnabled); err != nil {
 		return err
 	}
 
-	// 删除已存在的链接
-	os.Remove(linkPath)
-
-	// 创建新的符号链接
-	if err := os.Symlink(configFile, linkPath); err != nil {
+	// 创建新的符号链接，替换已存在的链接
+	if err := n.changes.symlink(configFile, linkPath); err != nil {
 		return err
 	}
 
@@ -272,139 +417,15 @@ func (n *NginxConfigurator) enableSite(configFile string) error {
 	return nil
 }
 
-// findSiteConfigs 查找站点配置文件
-func (n *NginxConfigurator) findSiteConfigs() []string {
-	var configs []string
-	var searchDirs []string
-
-	if runtime.GOOS == "windows" {
-		searchDirs = []string{
-			filepath.Join(filepath.Dir(n.configPath), "conf.d"),
-		}
-	} else {
-		searchDirs = []string{
-			"/etc/nginx/sites-enabled",
-			"/etc/nginx/conf.d",
-		}
-	}
-
-	for _, dir := range searchDirs {
-		if files, err := filepath.Glob(filepath.Join(dir, "*")); err == nil {
-			configs = append(configs, files...)
-		}
-	}
-
-	return configs
-}
-
-// checkSSLInConfig 检查配置文件中是否启用了 SSL
-func (n *NginxConfigurator) checkSSLInConfig(configFile, domain string) bool {
-	file, err := os.Open(configFile)
-	if err != nil {
-		return false
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	inServerBlock := false
-	hasSSL := false
-	hasDomain := false
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		if strings.Contains(line, "server {") {
-			inServerBlock = true
-			hasSSL = false
-			hasDomain = false
-		} else if strings.Contains(line, "}") && inServerBlock {
-			if hasSSL && hasDomain {
-				return true
-			}
-			inServerBlock = false
-		} else if inServerBlock {
-			if strings.Contains(line, "ssl_certificate") {
-				hasSSL = true
-			}
-			if strings.Contains(line, "server_name") && strings.Contains(line, domain) {
-				hasDomain = true
-			}
-		}
-	}
-
-	return false
-}
-
-// ApacheConfigurator Apache 配置器
-type ApacheConfigurator struct {
-	configPath string
-}
-
-// Configure 配置 Apache
-func (a *ApacheConfigurator) Configure(config *Config) error {
-	logger.Info("开始配置 Apache", "domain", config.Domain)
-
-	// Apache 配置实现
-	// 这里应该实现完整的 Apache SSL 配置逻辑
-
-	logger.Info("Apache 配置完成", "domain", config.Domain)
-	return nil
-}
-
-// Test 测试 Apache 配置
-func (a *ApacheConfigurator) Test() error {
-	cmd := exec.Command("apache2ctl", "configtest")
-	if _, err := exec.LookPath("apache2ctl"); err != nil {
-		cmd = exec.Command("httpd", "-t")
-	}
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("Apache 配置测试失败: %s", string(output))
-	}
-
-	logger.Info("Apache 配置测试成功")
-	return nil
-}
-
-// Reload 重载 Apache 配置
-func (a *ApacheConfigurator) Reload() error {
-	var cmd *exec.Cmd
-
-	if _, err := exec.LookPath("systemctl"); err == nil {
-		cmd = exec.Command("systemctl", "reload", "apache2")
-	} else if _, err := exec.LookPath("apache2ctl"); err == nil {
-		cmd = exec.Command("apache2ctl", "graceful")
-	} else {
-		cmd = exec.Command("httpd", "-k", "graceful")
-	}
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("重载 Apache 失败: %s", string(output))
-	}
-
-	logger.Info("Apache 配置重载成功")
-	return nil
-}
-
-// GetConfigPath 获取配置路径
-func (a *ApacheConfigurator) GetConfigPath() string {
-	return a.configPath
-}
-
-// IsSSLEnabled 检查 SSL 是否已启用
-func (a *ApacheConfigurator) IsSSLEnabled(domain string) bool {
-	// Apache SSL 检查实现
-	return false
-}
-
 // IISConfigurator IIS 配置器
-type IISConfigurator struct{}
+type IISConfigurator struct {
+	reloadOpts config.ReloadConfig
+}
 
 // Configure 配置 IIS
 func (i *IISConfigurator) Configure(config *Config) error {
 	logger.Info("开始配置 IIS", "domain", config.Domain)
+	i.reloadOpts = config.Reload
 
 	// IIS 配置实现
 	// 这里应该实现完整的 IIS SSL 配置逻辑，使用 PowerShell 脚本
@@ -422,10 +443,12 @@ func (i *IISConfigurator) Test() error {
 
 // Reload 重载 IIS 配置
 func (i *IISConfigurator) Reload() error {
-	cmd := exec.Command("iisreset")
-	output, err := cmd.CombinedOutput()
+	r, err := i.reloadStrategy()
 	if err != nil {
-		return fmt.Errorf("重载 IIS 失败: %s", string(output))
+		return err
+	}
+	if err := r.runReload(); err != nil {
+		return fmt.Errorf("重载 IIS 失败: %w", err)
 	}
 
 	logger.Info("IIS 配置重载成功")
@@ -437,6 +460,26 @@ func (i *IISConfigurator) GetConfigPath() string {
 	return `C:\Windows\System32\inetsrv\config\applicationHost.config`
 }
 
+// Plan IIS 配置器不修改文件，只会重启 IIS
+func (i *IISConfigurator) Plan(config *Config) (*Plan, error) {
+	i.reloadOpts = config.Reload
+	r, err := i.reloadStrategy()
+	if err != nil {
+		return nil, err
+	}
+	return &Plan{Commands: r.planCommands()}, nil
+}
+
+// reloadStrategy 根据配置的重载方式创建重载器
+func (i *IISConfigurator) reloadStrategy() (*reloader, error) {
+	return newReloader(i.reloadOpts, "W3SVC", nil, []string{"iisreset"})
+}
+
+// Rollback IIS 配置器不修改文件，无需回滚
+func (i *IISConfigurator) Rollback() ([]string, error) {
+	return nil, nil
+}
+
 // IsSSLEnabled 检查 SSL 是否已启用
 func (i *IISConfigurator) IsSSLEnabled(domain string) bool {
 	// IIS SSL 检查实现
@@ -0,0 +1,167 @@
+package webserver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext unified diff 中每个修改前后保留的上下文行数
+const diffContext = 3
+
+// diffOp 一行的差异类型
+type diffOp struct {
+	kind byte // ' '、'-' 或 '+'
+	line string
+	a, b int // 在旧文件和新文件中的行号（从 0 开始）
+}
+
+// unifiedDiff 生成 unified diff，内容相同时返回空字符串
+// 旧文件不存在时 oldName 使用 /dev/null
+func unifiedDiff(oldName, newName, oldContent, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+
+	ops := diffLines(splitLines(oldContent), splitLines(newContent))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+
+	for i := 0; i < len(ops); {
+		// 跳过没有修改的行
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// 向前保留上下文，向后合并相距不超过 2*diffContext 的修改
+		start := max(i-diffContext, 0)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContext {
+				end = min(end+diffContext, len(ops))
+				break
+			}
+			end = next
+		}
+
+		writeHunk(&sb, ops[start:end])
+		i = end
+	}
+
+	return sb.String()
+}
+
+// writeHunk 输出一个 hunk
+func writeHunk(sb *strings.Builder, ops []diffOp) {
+	oldStart, newStart := -1, -1
+	oldCount, newCount := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			if oldStart < 0 {
+				oldStart = op.a
+			}
+			oldCount++
+		}
+		if op.kind != '-' {
+			if newStart < 0 {
+				newStart = op.b
+			}
+			newCount++
+		}
+	}
+
+	// 空范围的起始行号为其前一行
+	if oldStart < 0 {
+		oldStart = ops[0].a
+	} else {
+		oldStart++
+	}
+	if newStart < 0 {
+		newStart = ops[0].b
+	} else {
+		newStart++
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+	for _, op := range ops {
+		sb.WriteByte(op.kind)
+		sb.WriteString(op.line)
+		sb.WriteByte('\n')
+	}
+}
+
+// diffLines 基于最长公共子序列计算逐行差异
+func diffLines(a, b []string) []diffOp {
+	// 去掉相同的开头和结尾，减少 LCS 表的大小
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ma := a[prefix : len(a)-suffix]
+	mb := b[prefix : len(b)-suffix]
+
+	// lcs[i][j] 为 ma[i:] 和 mb[j:] 的最长公共子序列长度
+	lcs := make([][]int32, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for k := 0; k < prefix; k++ {
+		ops = append(ops, diffOp{kind: ' ', line: a[k], a: k, b: k})
+	}
+
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			ops = append(ops, diffOp{kind: ' ', line: ma[i], a: prefix + i, b: prefix + j})
+			i++
+			j++
+		case i < len(ma) && (j == len(mb) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', line: ma[i], a: prefix + i, b: prefix + j})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', line: mb[j], a: prefix + i, b: prefix + j})
+			j++
+		}
+	}
+
+	for k := 0; k < suffix; k++ {
+		ai, bi := len(a)-suffix+k, len(b)-suffix+k
+		ops = append(ops, diffOp{kind: ' ', line: a[ai], a: ai, b: bi})
+	}
+
+	return ops
+}
+
+// splitLines 按行拆分，忽略末尾的换行符
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
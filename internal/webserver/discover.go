@@ -0,0 +1,201 @@
+package webserver
+
+import (
+	"autocert/internal/logger"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DiscoveredSite 从已有的 Web 服务器配置中发现的站点，每个站点建议申请一张证书
+type DiscoveredSite struct {
+	Sources []string // 来源的 server 块或 VirtualHost（文件:行号）
+	Domains []string // 可以申请证书的域名，第一个为主域名
+	Skipped []string // 被过滤的名称及原因
+}
+
+// nonPublicSuffixes 不能申请公共证书的保留或内部域名后缀
+var nonPublicSuffixes = []string{
+	".localhost", ".local", ".localdomain", ".lan", ".home", ".internal", ".intranet", ".corp",
+	".test", ".example", ".invalid", ".home.arpa", ".arpa",
+}
+
+// publicDomainName 规范化 server_name/ServerName 中的名称，判断能否申请公共证书
+// 返回规范化后的域名，不能申请时返回过滤原因
+func publicDomainName(name string) (string, string) {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	switch {
+	case name == "" || name == "_":
+		return "", "默认 server"
+	case strings.HasPrefix(name, "~"):
+		return "", "正则表达式"
+	case strings.HasPrefix(name, "$"):
+		return "", "变量"
+	case name == "localhost":
+		return "", "本机名称"
+	case net.ParseIP(strings.Trim(name, "[]")) != nil:
+		return "", "IP 地址"
+	}
+
+	if strings.HasSuffix(name, ".*") || strings.ContainsAny(strings.TrimPrefix(name, "*."), "*?") {
+		return "", "不支持的通配符"
+	}
+	if !strings.Contains(strings.TrimPrefix(name, "*."), ".") {
+		return "", "不是完整域名"
+	}
+	for _, suffix := range nonPublicSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return "", "内部域名"
+		}
+	}
+	for _, label := range strings.Split(strings.TrimPrefix(name, "*."), ".") {
+		if label == "" || strings.Trim(label, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return "", "包含无效字符"
+		}
+	}
+	return name, ""
+}
+
+// newDiscoveredSite 过滤一组名称，Nginx 的 .example.com 同时匹配 example.com 和 *.example.com
+func newDiscoveredSite(source string, names []string) DiscoveredSite {
+	site := DiscoveredSite{Sources: []string{source}}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		candidates := []string{name}
+		if strings.HasPrefix(name, ".") {
+			candidates = []string{strings.TrimPrefix(name, "."), "*" + name}
+		}
+		for _, candidate := range candidates {
+			domain, reason := publicDomainName(candidate)
+			if reason != "" {
+				site.Skipped = append(site.Skipped, fmt.Sprintf("%s（%s）", candidate, reason))
+				continue
+			}
+			if !seen[domain] {
+				seen[domain] = true
+				site.Domains = append(site.Domains, domain)
+			}
+		}
+	}
+	return site
+}
+
+// mergeDiscoveredSites 合并域名相同的站点（例如同一站点的 HTTP 和 HTTPS 配置），去掉没有可用域名的站点
+func mergeDiscoveredSites(sites []DiscoveredSite) []DiscoveredSite {
+	var result []DiscoveredSite
+	index := make(map[string]int)
+	for _, site := range sites {
+		if len(site.Domains) == 0 {
+			logger.Info("站点没有可以申请证书的域名，跳过", "source", strings.Join(site.Sources, ", "), "skipped", site.Skipped)
+			continue
+		}
+		sorted := append([]string(nil), site.Domains...)
+		sort.Strings(sorted)
+		key := strings.Join(sorted, ",")
+
+		if i, ok := index[key]; ok {
+			result[i].Sources = append(result[i].Sources, site.Sources...)
+			result[i].Skipped = append(result[i].Skipped, site.Skipped...)
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, site)
+	}
+	return result
+}
+
+// sameFile 比较配置文件路径，site 可以是相对路径或符号链接（sites-enabled 中的链接）
+func sameFile(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// DiscoverSites 从 Nginx 配置的 server_name 中发现站点，site 不为空时只读取该文件
+// 没有被主配置引用的文件（例如未启用的 sites-available）直接解析其中的 server 块
+func (n *NginxConfigurator) DiscoverSites(config *Config, site string) ([]DiscoveredSite, error) {
+	n.setup(config)
+	parsed, err := n.loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("解析 Nginx 配置失败: %w", err)
+	}
+
+	var servers []nginxServer
+	for _, server := range parsed.Servers() {
+		if site == "" || sameFile(server.File.Path, site) {
+			servers = append(servers, server)
+		}
+	}
+
+	if site != "" && len(servers) == 0 {
+		data, err := os.ReadFile(site)
+		if err != nil {
+			return nil, fmt.Errorf("读取站点配置失败: %w", err)
+		}
+		directives, err := parseNginx(site, string(data))
+		if err != nil {
+			return nil, fmt.Errorf("解析站点配置失败: %w", err)
+		}
+		file := &nginxConfigFile{Path: site, Content: string(data), Directives: directives}
+		for _, d := range directives {
+			if d.Name == "server" && d.IsBlock() {
+				servers = append(servers, nginxServer{File: file, Directive: d})
+			}
+		}
+	}
+
+	var sites []DiscoveredSite
+	for _, server := range servers {
+		source := fmt.Sprintf("%s:%d", server.File.Path, server.Directive.Line)
+		sites = append(sites, newDiscoveredSite(source, server.serverNames()))
+	}
+	return sites, nil
+}
+
+// DiscoverSites 从 Apache 配置的 ServerName 和 ServerAlias 中发现站点，site 不为空时只读取该文件
+func (a *ApacheConfigurator) DiscoverSites(config *Config, site string) ([]DiscoveredSite, error) {
+	var vhosts []apacheVirtualHost
+	if site != "" {
+		p := &apacheParser{visited: make(map[string]bool)}
+		if err := a.detectLayout(); err == nil {
+			p.serverRoot = a.layout.serverRoot
+		}
+		if _, err := os.Stat(site); err != nil {
+			return nil, fmt.Errorf("读取站点配置失败: %w", err)
+		}
+		p.parseFile(site)
+		vhosts = p.vhosts
+	} else {
+		if err := a.detectLayout(); err != nil {
+			return nil, fmt.Errorf("查找 Apache 配置路径失败: %w", err)
+		}
+		vhosts = parseApacheVirtualHosts(a.layout.mainConfig, a.layout.serverRoot)
+	}
+
+	var sites []DiscoveredSite
+	for _, vhost := range vhosts {
+		names := append([]string{vhost.ServerName}, vhost.ServerAliases...)
+		sites = append(sites, newDiscoveredSite(vhost.File, names))
+	}
+	return sites, nil
+}
+
+// SiteDiscoverer 支持从已有配置中发现站点的配置器
+type SiteDiscoverer interface {
+	DiscoverSites(config *Config, site string) ([]DiscoveredSite, error)
+}
+
+// DiscoverSites 发现站点，过滤不能申请证书的名称，合并域名相同的站点
+func DiscoverSites(d SiteDiscoverer, config *Config, site string) ([]DiscoveredSite, error) {
+	sites, err := d.DiscoverSites(config, site)
+	if err != nil {
+		return nil, err
+	}
+	return mergeDiscoveredSites(sites), nil
+}
@@ -0,0 +1,210 @@
+package webserver
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// dovecotSnippetName AutoCert 写入 conf.d 的配置片段，排序靠后以覆盖 10-ssl.conf 中的设置
+const dovecotSnippetName = "99-autocert.conf"
+
+// dovecotConfigPaths dovecot.conf 的常见位置
+var dovecotConfigPaths = []string{
+	"/etc/dovecot/dovecot.conf",
+	"/usr/local/etc/dovecot/dovecot.conf",
+}
+
+// dovecotConfDInclude 匹配 dovecot.conf 中包含 conf.d/*.conf 的语句
+var dovecotConfDInclude = regexp.MustCompile(`(?m)^\s*!include(_try)?\s+conf\.d/\*\.conf\s*$`)
+
+// DovecotConfigurator Dovecot 配置器
+// 在 conf.d 中写入单独的配置片段设置证书，不修改 ssl 等其他设置
+type DovecotConfigurator struct {
+	configPath string
+	changes    *changeSet
+	reloadOpts config.ReloadConfig
+}
+
+// Configure 配置 Dovecot
+func (d *DovecotConfigurator) Configure(config *Config) error {
+	logger.Info("开始配置 Dovecot", "domain", config.Domain)
+	d.changes = newChangeSet(false)
+	d.reloadOpts = config.Reload
+	return d.configure(config)
+}
+
+// Plan 计算 Configure 将执行的修改
+func (d *DovecotConfigurator) Plan(config *Config) (*Plan, error) {
+	d.changes = newChangeSet(true)
+	d.reloadOpts = config.Reload
+	if err := d.configure(config); err != nil {
+		return nil, err
+	}
+
+	r, err := d.reloadStrategy()
+	if err != nil {
+		return nil, err
+	}
+	plan := d.changes.plan
+	plan.Commands = append(plan.Commands, r.planCommands()...)
+	return plan, nil
+}
+
+// configure 写入证书配置片段
+func (d *DovecotConfigurator) configure(config *Config) error {
+	if _, err := d.reloadStrategy(); err != nil {
+		return fmt.Errorf("重载配置无效: %w", err)
+	}
+	if config.FullchainPath == "" || config.KeyPath == "" {
+		return fmt.Errorf("缺少证书或私钥路径")
+	}
+
+	if err := d.findConfigPath(config.ConfigPath); err != nil {
+		return err
+	}
+
+	main, err := os.ReadFile(d.configPath)
+	if err != nil {
+		return fmt.Errorf("读取 Dovecot 配置失败: %w", err)
+	}
+	if !dovecotConfDInclude.Match(main) {
+		return fmt.Errorf("%s 没有包含 conf.d/*.conf，请添加 \"!include conf.d/*.conf\" 后重试", d.configPath)
+	}
+
+	snippet := d.snippetPath()
+	content := dovecotSnippet(config, dovecotVersion())
+	if old, err := os.ReadFile(snippet); err == nil && bytes.Equal(old, content) {
+		logger.Info("Dovecot 已使用该证书，无需修改", "file", snippet)
+		return nil
+	}
+
+	if err := d.changes.writeFile(snippet, content, 0644); err != nil {
+		return fmt.Errorf("写入 Dovecot 配置失败: %w", err)
+	}
+
+	logger.Info("Dovecot 配置完成", "domain", config.Domain, "file", snippet)
+	return nil
+}
+
+// dovecotSnippet 生成证书配置片段
+// Dovecot 2.4 将 ssl_cert/ssl_key 改为 ssl_server_cert_file/ssl_server_key_file，且不再使用 < 前缀
+func dovecotSnippet(config *Config, version string) []byte {
+	var sb strings.Builder
+	sb.WriteString("# 由 AutoCert 生成，请勿手动修改\n")
+	fmt.Fprintf(&sb, "# 证书: %s\n", config.Name)
+	if dovecotVersionAtLeast(version, 2, 4) {
+		fmt.Fprintf(&sb, "ssl_server_cert_file = %s\n", config.FullchainPath)
+		fmt.Fprintf(&sb, "ssl_server_key_file = %s\n", config.KeyPath)
+	} else {
+		fmt.Fprintf(&sb, "ssl_cert = <%s\n", config.FullchainPath)
+		fmt.Fprintf(&sb, "ssl_key = <%s\n", config.KeyPath)
+	}
+	return []byte(sb.String())
+}
+
+// dovecotVersion 读取 Dovecot 版本（例如 2.3.21），无法获取时返回空字符串
+func dovecotVersion() string {
+	output, err := exec.Command("dovecot", "--version").Output()
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// dovecotVersionAtLeast 比较 Dovecot 版本
+func dovecotVersionAtLeast(version string, major, minor int) bool {
+	return postfixVersionAtLeast(version, major, minor)
+}
+
+// findConfigPath 查找 dovecot.conf
+func (d *DovecotConfigurator) findConfigPath(configPath string) error {
+	if configPath != "" {
+		d.configPath = configPath
+		return nil
+	}
+	for _, path := range dovecotConfigPaths {
+		if _, err := os.Stat(path); err == nil {
+			d.configPath = path
+			return nil
+		}
+	}
+	return fmt.Errorf("未找到 Dovecot 配置文件")
+}
+
+// snippetPath 证书配置片段的路径
+func (d *DovecotConfigurator) snippetPath() string {
+	return filepath.Join(filepath.Dir(d.configPath), "conf.d", dovecotSnippetName)
+}
+
+// Test 测试 Dovecot 配置
+func (d *DovecotConfigurator) Test() error {
+	r, err := d.reloadStrategy()
+	if err != nil {
+		return err
+	}
+	if err := r.runTest(); err != nil {
+		return fmt.Errorf("Dovecot 配置测试失败: %w", err)
+	}
+	logger.Info("Dovecot 配置测试成功")
+	return nil
+}
+
+// Reload 重载 Dovecot 配置
+func (d *DovecotConfigurator) Reload() error {
+	r, err := d.reloadStrategy()
+	if err != nil {
+		return err
+	}
+	if err := r.runReload(); err != nil {
+		return fmt.Errorf("重载 Dovecot 失败: %w", err)
+	}
+	logger.Info("Dovecot 配置重载成功")
+	return nil
+}
+
+// reloadStrategy 根据配置的重载方式创建重载器
+func (d *DovecotConfigurator) reloadStrategy() (*reloader, error) {
+	return newReloader(d.reloadOpts, "dovecot", []string{"doveconf", "-n"}, []string{"doveadm", "reload"})
+}
+
+// Rollback 撤销最近一次 Configure 对文件的修改
+func (d *DovecotConfigurator) Rollback() ([]string, error) {
+	if d.changes == nil {
+		return nil, nil
+	}
+	return d.changes.rollback()
+}
+
+// GetConfigPath 获取配置路径
+func (d *DovecotConfigurator) GetConfigPath() string {
+	if d.configPath == "" {
+		d.findConfigPath("")
+	}
+	return d.configPath
+}
+
+// IsSSLEnabled 检查 Dovecot 使用的证书是否包含该域名
+func (d *DovecotConfigurator) IsSSLEnabled(domain string) bool {
+	for _, name := range []string{"ssl_server_cert_file", "ssl_cert"} {
+		output, err := exec.Command("doveconf", "-h", name).Output()
+		if err != nil {
+			continue
+		}
+		certFile := strings.TrimPrefix(strings.TrimSpace(string(output)), "<")
+		if certFile != "" && pemCoversDomain(certFile, domain) {
+			return true
+		}
+	}
+	return false
+}
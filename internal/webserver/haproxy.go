@@ -0,0 +1,685 @@
+package webserver
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"bufio"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// HAProxy 默认路径
+const (
+	DefaultHAProxyCertDir      = "/etc/haproxy/certs"
+	DefaultHAProxyMasterSocket = "/run/haproxy-master.sock"
+)
+
+// defaultHAProxyConfigPaths 未配置时尝试的 haproxy.cfg 路径
+var defaultHAProxyConfigPaths = []string{
+	"/etc/haproxy/haproxy.cfg",
+	"/usr/local/etc/haproxy/haproxy.cfg",
+}
+
+// haproxySectionKeywords 开始一个新配置段的关键字
+var haproxySectionKeywords = map[string]bool{
+	"global": true, "defaults": true, "frontend": true, "backend": true, "listen": true,
+	"userlist": true, "peers": true, "resolvers": true, "mailers": true, "program": true,
+	"http-errors": true, "ring": true, "cache": true,
+}
+
+// HAProxyConfigurator HAProxy 配置器
+// HAProxy 使用私钥 + 完整证书链的组合 PEM，通过 crt 目录或 crt-list 加载
+type HAProxyConfigurator struct {
+	configPath string
+	changes    *changeSet
+	reloadOpts config.ReloadConfig
+	options    config.HAProxyConfig
+}
+
+// haproxySection haproxy.cfg 中的一个配置段
+type haproxySection struct {
+	kind  string // global, frontend, listen 等
+	name  string
+	start int // 段首行的行号（从 0 开始）
+	end   int // 段结束后的第一行
+	binds []*haproxyBind
+}
+
+// haproxyBind bind 指令
+type haproxyBind struct {
+	line int
+	args []string // bind 之后的参数
+}
+
+// ssl 是否为 TLS 监听
+func (b *haproxyBind) ssl() bool {
+	for _, a := range b.args[1:] {
+		if a == "ssl" {
+			return true
+		}
+	}
+	return false
+}
+
+// values 获取指定参数的所有值，例如 crt、crt-list
+func (b *haproxyBind) values(key string) []string {
+	var values []string
+	for i := 1; i < len(b.args)-1; i++ {
+		if b.args[i] == key {
+			values = append(values, b.args[i+1])
+		}
+	}
+	return values
+}
+
+// port 监听端口
+func (b *haproxyBind) port() string {
+	addr := b.args[0]
+	if i := strings.LastIndex(addr, ":"); i >= 0 {
+		return addr[i+1:]
+	}
+	return ""
+}
+
+// haproxyConfig 解析后的 haproxy.cfg
+type haproxyConfig struct {
+	path     string
+	lines    []string
+	sections []*haproxySection
+	crtBase  string // global 中的 crt-base
+}
+
+// parseHAProxyConfig 按行解析 haproxy.cfg，只识别配置段和 bind 指令
+func parseHAProxyConfig(path, content string) *haproxyConfig {
+	cfg := &haproxyConfig{path: path, lines: strings.Split(content, "\n")}
+
+	var current *haproxySection
+	for i, line := range cfg.lines {
+		fields := haproxyFields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if haproxySectionKeywords[fields[0]] {
+			if current != nil {
+				current.end = i
+			}
+			current = &haproxySection{kind: fields[0], start: i}
+			if len(fields) > 1 {
+				current.name = fields[1]
+			}
+			cfg.sections = append(cfg.sections, current)
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch {
+		case fields[0] == "bind" && len(fields) > 1:
+			current.binds = append(current.binds, &haproxyBind{line: i, args: fields[1:]})
+		case fields[0] == "crt-base" && current.kind == "global" && len(fields) > 1:
+			cfg.crtBase = fields[1]
+		}
+	}
+	if current != nil {
+		current.end = len(cfg.lines)
+	}
+	return cfg
+}
+
+// haproxyFields 拆分一行配置，去掉注释，支持引号
+func haproxyFields(line string) []string {
+	var fields []string
+	var sb strings.Builder
+	var quote rune
+	inField := false
+
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				sb.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inField = true
+		case r == '#':
+			if inField {
+				fields = append(fields, sb.String())
+			}
+			return fields
+		case r == ' ' || r == '\t' || r == '\r':
+			if inField {
+				fields = append(fields, sb.String())
+				sb.Reset()
+				inField = false
+			}
+		default:
+			sb.WriteRune(r)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, sb.String())
+	}
+	return fields
+}
+
+// resolve 将 crt 参数转换为绝对路径
+func (c *haproxyConfig) resolve(path string) string {
+	if filepath.IsAbs(path) || c.crtBase == "" {
+		return path
+	}
+	return filepath.Join(c.crtBase, path)
+}
+
+// sslBinds 所有 frontend/listen 中的 ssl bind
+func (c *haproxyConfig) sslBinds() []*haproxyBind {
+	var binds []*haproxyBind
+	for _, s := range c.sections {
+		if s.kind != "frontend" && s.kind != "listen" {
+			continue
+		}
+		for _, b := range s.binds {
+			if b.ssl() {
+				binds = append(binds, b)
+			}
+		}
+	}
+	return binds
+}
+
+// Configure 配置 HAProxy
+func (h *HAProxyConfigurator) Configure(config *Config) error {
+	logger.Info("开始配置 HAProxy", "domain", config.Domain)
+	h.changes = newChangeSet(false)
+	h.reloadOpts = config.Reload
+	h.options = config.HAProxy
+	return h.configure(config)
+}
+
+// Plan 计算 Configure 将执行的修改
+func (h *HAProxyConfigurator) Plan(config *Config) (*Plan, error) {
+	h.changes = newChangeSet(true)
+	h.reloadOpts = config.Reload
+	h.options = config.HAProxy
+	if err := h.configure(config); err != nil {
+		return nil, err
+	}
+
+	r, err := h.reloadStrategy()
+	if err != nil {
+		return nil, err
+	}
+	plan := h.changes.plan
+	plan.Commands = append(plan.Commands, r.planCommands()...)
+	return plan, nil
+}
+
+// configure 执行配置步骤，文件修改通过 h.changes 完成
+func (h *HAProxyConfigurator) configure(config *Config) error {
+	if _, err := h.reloadStrategy(); err != nil {
+		return fmt.Errorf("重载配置无效: %w", err)
+	}
+	if config.CombinedPath == "" {
+		return fmt.Errorf("缺少组合 PEM 文件路径")
+	}
+
+	// 1. 确定配置文件路径
+	if err := h.findConfigPath(); err != nil {
+		return fmt.Errorf("查找 HAProxy 配置路径失败: %w", err)
+	}
+
+	content, err := os.ReadFile(h.configPath)
+	if err != nil {
+		return fmt.Errorf("读取 HAProxy 配置失败: %w", err)
+	}
+	cfg := parseHAProxyConfig(h.configPath, string(content))
+
+	// 2. 按已有的 crt-list 或 crt 目录安装证书，都没有时使用默认证书目录
+	crtList, certDir := h.certLocation(cfg)
+	switch {
+	case crtList != "":
+		if err := h.installCrtList(crtList, config); err != nil {
+			return fmt.Errorf("更新 crt-list 失败: %w", err)
+		}
+	case certDir != "":
+		if err := h.installCertDir(certDir, config); err != nil {
+			return fmt.Errorf("安装证书失败: %w", err)
+		}
+	}
+
+	// 3. 确保 frontend 引用了证书位置，并按需添加 HTTPS 重定向
+	if err := h.updateFrontend(cfg, crtList, certDir, config); err != nil {
+		return fmt.Errorf("更新 HAProxy 配置失败: %w", err)
+	}
+
+	logger.Info("HAProxy 配置完成", "domain", config.Domain)
+	return nil
+}
+
+// certLocation 确定证书的安装位置：crt-list 文件或 crt 目录
+func (h *HAProxyConfigurator) certLocation(cfg *haproxyConfig) (crtList, certDir string) {
+	if h.options.CrtList != "" {
+		return h.options.CrtList, ""
+	}
+	if h.options.CertDir != "" {
+		return "", h.options.CertDir
+	}
+
+	binds := cfg.sslBinds()
+	for _, b := range binds {
+		if lists := b.values("crt-list"); len(lists) > 0 {
+			return cfg.resolve(lists[0]), ""
+		}
+	}
+	for _, b := range binds {
+		for _, crt := range b.values("crt") {
+			path := cfg.resolve(crt)
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				return "", path
+			}
+		}
+	}
+	return "", DefaultHAProxyCertDir
+}
+
+// installCertDir 将组合 PEM 复制到 crt 目录，HAProxy 会加载目录中的所有证书
+func (h *HAProxyConfigurator) installCertDir(certDir string, config *Config) error {
+	data, err := os.ReadFile(config.CombinedPath)
+	if errors.Is(err, os.ErrNotExist) && h.changes.dryRun {
+		// 计划模式下证书还没有申请
+		data, err = []byte(fmt.Sprintf("# %s 的内容（私钥 + 完整证书链）\n", config.CombinedPath)), nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取组合 PEM 失败: %w", err)
+	}
+	if err := h.changes.mkdirAll(certDir); err != nil {
+		return err
+	}
+
+	path := filepath.Join(certDir, siteFileName(config)+".pem")
+	if err := h.changes.writeFile(path, data, 0600); err != nil {
+		return err
+	}
+	logger.Info("安装 HAProxy 证书", "file", path)
+	return nil
+}
+
+// installCrtList 在 crt-list 中登记证书目录中的组合 PEM，续期后无需再次修改
+func (h *HAProxyConfigurator) installCrtList(crtList string, config *Config) error {
+	var lines []string
+	if data, err := os.ReadFile(crtList); err == nil {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	for _, line := range lines {
+		if fields := haproxyFields(line); len(fields) > 0 && fields[0] == config.CombinedPath {
+			logger.Info("crt-list 中已包含证书", "crtList", crtList, "file", config.CombinedPath)
+			return nil
+		}
+	}
+
+	lines = append(lines, config.CombinedPath)
+	content := strings.Join(lines, "\n") + "\n"
+
+	if _, err := os.Stat(crtList); err == nil {
+		backupPath, err := h.changes.updateFile(crtList, []byte(content))
+		if err != nil {
+			return err
+		}
+		logger.Info("在 crt-list 中登记证书", "crtList", crtList, "file", config.CombinedPath, "backup", backupPath)
+		return nil
+	}
+
+	if err := h.changes.mkdirAll(filepath.Dir(crtList)); err != nil {
+		return err
+	}
+	if err := h.changes.writeFile(crtList, []byte(content), 0644); err != nil {
+		return err
+	}
+	logger.Info("创建 crt-list", "crtList", crtList, "file", config.CombinedPath)
+	return nil
+}
+
+// updateFrontend 确保有 ssl bind 引用证书位置，并按需添加 HTTPS 重定向
+func (h *HAProxyConfigurator) updateFrontend(cfg *haproxyConfig, crtList, certDir string, config *Config) error {
+	key, location := "crt", certDir
+	if crtList != "" {
+		key, location = "crt-list", crtList
+	}
+
+	// 已有 bind 引用了证书位置时不需要修改
+	var sslBind *haproxyBind
+	for _, b := range cfg.sslBinds() {
+		for _, v := range b.values(key) {
+			if filepath.Clean(cfg.resolve(v)) == filepath.Clean(location) {
+				sslBind = b
+			}
+		}
+	}
+
+	edits := make(map[int]string)
+	if sslBind == nil {
+		if binds := cfg.sslBinds(); len(binds) > 0 {
+			// 在已有的 ssl bind 上追加证书位置
+			sslBind = binds[0]
+			edits[sslBind.line] = cfg.lines[sslBind.line] + " " + key + " " + location
+		} else {
+			// 在监听 80 端口的 frontend 中添加 443 监听
+			section := cfg.httpFrontend()
+			if section == nil {
+				return fmt.Errorf("%s 中没有 frontend 或 listen 段", cfg.path)
+			}
+			anchor := section.start
+			if len(section.binds) > 0 {
+				anchor = section.binds[len(section.binds)-1].line
+			}
+			bind := "bind :443 ssl " + key + " " + location
+			if cfg.mode(section) == "http" {
+				bind += " alpn h2,http/1.1"
+			}
+			edits[anchor] = cfg.lines[anchor] + "\n" + haproxyIndent(cfg.lines, section) + bind
+		}
+	}
+
+	// 在监听 80 端口的 http 模式 frontend 中重定向到 HTTPS
+	if section := cfg.httpFrontend(); config.Redirect && section != nil && section.hasPort("80") &&
+		cfg.mode(section) == "http" && !cfg.hasHTTPSRedirect(section) {
+		anchor := section.binds[len(section.binds)-1].line
+		line := cfg.lines[anchor]
+		if edit, ok := edits[anchor]; ok {
+			line = edit
+		}
+		edits[anchor] = line + "\n" + haproxyIndent(cfg.lines, section) + "http-request redirect scheme https code 301 unless { ssl_fc }"
+	}
+
+	if len(edits) == 0 {
+		return nil
+	}
+
+	lines := make([]string, len(cfg.lines))
+	copy(lines, cfg.lines)
+	for i, text := range edits {
+		lines[i] = text
+	}
+
+	backupPath, err := h.changes.updateFile(cfg.path, []byte(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+	logger.Info("更新 HAProxy 配置", "configFile", cfg.path, "backup", backupPath)
+	return nil
+}
+
+// sectionOf 获取 bind 所在的配置段
+func (c *haproxyConfig) sectionOf(b *haproxyBind) *haproxySection {
+	if b == nil {
+		return nil
+	}
+	for _, s := range c.sections {
+		if b.line >= s.start && b.line < s.end {
+			return s
+		}
+	}
+	return nil
+}
+
+// mode 配置段的代理模式，未设置时继承前面的 defaults 段，默认为 tcp
+func (c *haproxyConfig) mode(s *haproxySection) string {
+	mode := "tcp"
+	for _, sec := range c.sections {
+		if sec.start > s.start {
+			break
+		}
+		if sec.kind != "defaults" && sec != s {
+			continue
+		}
+		for _, line := range c.lines[sec.start+1 : sec.end] {
+			if fields := haproxyFields(line); len(fields) == 2 && fields[0] == "mode" {
+				mode = fields[1]
+			}
+		}
+	}
+	return mode
+}
+
+// httpFrontend 优先选择监听 80 端口的 frontend/listen
+func (c *haproxyConfig) httpFrontend() *haproxySection {
+	var first *haproxySection
+	for _, s := range c.sections {
+		if s.kind != "frontend" && s.kind != "listen" {
+			continue
+		}
+		if s.hasPort("80") {
+			return s
+		}
+		if first == nil {
+			first = s
+		}
+	}
+	return first
+}
+
+// hasPort 配置段是否监听指定端口
+func (s *haproxySection) hasPort(port string) bool {
+	for _, b := range s.binds {
+		if b.port() == port {
+			return true
+		}
+	}
+	return false
+}
+
+// hasHTTPSRedirect 配置段中是否已有 HTTPS 重定向
+func (c *haproxyConfig) hasHTTPSRedirect(s *haproxySection) bool {
+	for _, line := range c.lines[s.start:s.end] {
+		fields := haproxyFields(line)
+		if len(fields) >= 3 && (fields[0] == "redirect" || fields[1] == "redirect") && strings.Contains(line, "scheme https") {
+			return true
+		}
+	}
+	return false
+}
+
+// haproxyIndent 配置段中指令的缩进
+func haproxyIndent(lines []string, s *haproxySection) string {
+	for _, line := range lines[s.start+1 : s.end] {
+		if trimmed := strings.TrimLeft(line, " \t"); trimmed != "" && trimmed != line {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "    "
+}
+
+// Test 测试 HAProxy 配置
+func (h *HAProxyConfigurator) Test() error {
+	r, err := h.reloadStrategy()
+	if err != nil {
+		return err
+	}
+	if err := r.runTest(); err != nil {
+		return fmt.Errorf("HAProxy 配置测试失败: %w", err)
+	}
+	logger.Info("HAProxy 配置测试成功")
+	return nil
+}
+
+// Reload 重载 HAProxy 配置
+func (h *HAProxyConfigurator) Reload() error {
+	r, err := h.reloadStrategy()
+	if err != nil {
+		return err
+	}
+	if err := r.runReload(); err != nil {
+		return fmt.Errorf("重载 HAProxy 失败: %w", err)
+	}
+	logger.Info("HAProxy 配置重载成功")
+	return nil
+}
+
+// reloadStrategy 根据配置的重载方式创建重载器
+// 未配置时优先通过 master socket 重载，否则使用 systemctl reload haproxy
+func (h *HAProxyConfigurator) reloadStrategy() (*reloader, error) {
+	r, err := newReloader(h.reloadOpts, "haproxy", h.testCommand(), []string{"systemctl", "reload", "haproxy"})
+	if err != nil {
+		return nil, err
+	}
+
+	socket := h.options.MasterSocket
+	if socket == "" {
+		socket = DefaultHAProxyMasterSocket
+	}
+	if info, err := os.Stat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+		r.reloadFunc = func() error { return haproxyMasterReload(socket, r.opts.Timeout) }
+		r.reloadDesc = fmt.Sprintf("echo reload | socat %s -", socket)
+	}
+	return r, nil
+}
+
+// testCommand 配置测试命令
+func (h *HAProxyConfigurator) testCommand() []string {
+	path := h.configPath
+	if path == "" {
+		path = defaultHAProxyConfigPaths[0]
+	}
+	return []string{"haproxy", "-c", "-f", path}
+}
+
+// haproxyMasterReload 通过 master CLI 重载
+// HAProxy 2.7 及以上会返回 Success=0/1，更早的版本不返回内容
+func haproxyMasterReload(socket string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("unix", socket, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("连接 master socket 失败: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := io.WriteString(conn, "reload\n"); err != nil {
+		return fmt.Errorf("发送 reload 命令失败: %w", err)
+	}
+
+	var output strings.Builder
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		output.WriteString(line + "\n")
+		if strings.HasPrefix(line, "Success=0") {
+			for scanner.Scan() {
+				output.WriteString(scanner.Text() + "\n")
+			}
+			return fmt.Errorf("%s", strings.TrimSpace(output.String()))
+		}
+	}
+	return nil
+}
+
+// Rollback 撤销最近一次 Configure 对文件的修改
+func (h *HAProxyConfigurator) Rollback() ([]string, error) {
+	if h.changes == nil {
+		return nil, nil
+	}
+	return h.changes.rollback()
+}
+
+// GetConfigPath 获取配置路径
+func (h *HAProxyConfigurator) GetConfigPath() string {
+	return h.configPath
+}
+
+// IsSSLEnabled 检查 HAProxy 加载的证书中是否有覆盖该域名的证书
+func (h *HAProxyConfigurator) IsSSLEnabled(domain string) bool {
+	if h.configPath == "" {
+		if err := h.findConfigPath(); err != nil {
+			return false
+		}
+	}
+	content, err := os.ReadFile(h.configPath)
+	if err != nil {
+		return false
+	}
+	cfg := parseHAProxyConfig(h.configPath, string(content))
+
+	var files []string
+	for _, b := range cfg.sslBinds() {
+		for _, crt := range b.values("crt") {
+			path := cfg.resolve(crt)
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				matches, _ := filepath.Glob(filepath.Join(path, "*"))
+				files = append(files, matches...)
+			} else {
+				files = append(files, path)
+			}
+		}
+		for _, list := range b.values("crt-list") {
+			data, err := os.ReadFile(cfg.resolve(list))
+			if err != nil {
+				continue
+			}
+			for _, line := range strings.Split(string(data), "\n") {
+				if fields := haproxyFields(line); len(fields) > 0 {
+					files = append(files, cfg.resolve(fields[0]))
+				}
+			}
+		}
+	}
+
+	for _, f := range files {
+		if pemCoversDomain(f, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// pemCoversDomain PEM 文件中的第一张证书是否包含该域名
+func pemCoversDomain(path, domain string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return false
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return false
+		}
+		return cert.VerifyHostname(domain) == nil
+	}
+}
+
+// findConfigPath 查找 haproxy.cfg
+func (h *HAProxyConfigurator) findConfigPath() error {
+	if h.options.ConfigPath != "" {
+		h.configPath = h.options.ConfigPath
+		return nil
+	}
+	for _, path := range defaultHAProxyConfigPaths {
+		if _, err := os.Stat(path); err == nil {
+			h.configPath = path
+			return nil
+		}
+	}
+	return fmt.Errorf("未找到 HAProxy 配置文件")
+}
@@ -0,0 +1,260 @@
+package webserver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHAProxyFields(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"    bind :443 ssl crt /etc/haproxy/certs", []string{"bind", ":443", "ssl", "crt", "/etc/haproxy/certs"}},
+		{"\tmode http\r", []string{"mode", "http"}},
+		{"bind :80 # 注释", []string{"bind", ":80"}},
+		{"bind :80# 注释", []string{"bind", ":80"}},
+		{"# bind :443 ssl", nil},
+		{`http-request set-header X-Note "a # b"`, []string{"http-request", "set-header", "X-Note", "a # b"}},
+		{`crt '/etc/haproxy/my certs'`, []string{"crt", "/etc/haproxy/my certs"}},
+		{`acl empty ""`, []string{"acl", "empty", ""}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		got := haproxyFields(tt.line)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("haproxyFields(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseHAProxyConfig(t *testing.T) {
+	content := `global
+    crt-base /etc/ssl/haproxy
+
+defaults
+    mode http
+
+frontend web
+    bind :80
+    bind :443 ssl crt site.pem crt-list /etc/haproxy/list.txt
+
+frontend db
+    mode tcp
+    bind 127.0.0.1:5432
+
+backend app
+    server app1 127.0.0.1:8080
+`
+	cfg := parseHAProxyConfig("/etc/haproxy/haproxy.cfg", content)
+
+	var kinds []string
+	for _, s := range cfg.sections {
+		kinds = append(kinds, s.kind+":"+s.name)
+	}
+	if got := strings.Join(kinds, ","); got != "global:,defaults:,frontend:web,frontend:db,backend:app" {
+		t.Fatalf("sections = %s", got)
+	}
+	if cfg.crtBase != "/etc/ssl/haproxy" {
+		t.Errorf("crtBase = %q", cfg.crtBase)
+	}
+
+	web, db := cfg.sections[2], cfg.sections[3]
+	if len(web.binds) != 2 || !web.hasPort("80") || !web.hasPort("443") || web.binds[0].ssl() || !web.binds[1].ssl() {
+		t.Errorf("unexpected web binds: %+v", web.binds)
+	}
+	if got := web.binds[1].values("crt"); len(got) != 1 || cfg.resolve(got[0]) != "/etc/ssl/haproxy/site.pem" {
+		t.Errorf("crt values = %v", got)
+	}
+	if got := web.binds[1].values("crt-list"); len(got) != 1 || cfg.resolve(got[0]) != "/etc/haproxy/list.txt" {
+		t.Errorf("crt-list values = %v", got)
+	}
+	if cfg.mode(web) != "http" || cfg.mode(db) != "tcp" {
+		t.Errorf("modes = %s, %s", cfg.mode(web), cfg.mode(db))
+	}
+	if cfg.httpFrontend() != web || cfg.sectionOf(web.binds[1]) != web {
+		t.Error("web frontend not found")
+	}
+	if binds := cfg.sslBinds(); len(binds) != 1 || binds[0] != web.binds[1] {
+		t.Errorf("sslBinds = %+v", binds)
+	}
+}
+
+// updateFrontendPlan 在临时文件中写入 haproxy.cfg，以预览模式执行 updateFrontend
+// 返回修改后的内容，没有修改时返回空字符串
+func updateFrontendPlan(t *testing.T, content, crtList, certDir string, redirect bool) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "haproxy.cfg")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &HAProxyConfigurator{configPath: path, changes: newChangeSet(true)}
+	cfg := parseHAProxyConfig(path, content)
+	if err := h.updateFrontend(cfg, crtList, certDir, &Config{Redirect: redirect}); err != nil {
+		t.Fatalf("updateFrontend: %v", err)
+	}
+	if len(h.changes.plan.Files) == 0 {
+		return ""
+	}
+	return h.changes.plan.Files[0].New
+}
+
+func TestHAProxyUpdateFrontend(t *testing.T) {
+	const certDir = "/etc/haproxy/certs"
+	const crtList = "/etc/haproxy/crt-list.txt"
+
+	tests := []struct {
+		name     string
+		content  string
+		crtList  string
+		redirect bool
+		want     string // 为空时期望不修改
+	}{
+		{
+			name: "add ssl bind and redirect to http frontend",
+			content: "defaults\n    mode http\n\n" +
+				"frontend web\n    bind :80\n    default_backend app\n",
+			redirect: true,
+			want: "defaults\n    mode http\n\n" +
+				"frontend web\n    bind :80\n" +
+				"    bind :443 ssl crt /etc/haproxy/certs alpn h2,http/1.1\n" +
+				"    http-request redirect scheme https code 301 unless { ssl_fc }\n" +
+				"    default_backend app\n",
+		},
+		{
+			name:    "tcp frontend gets no alpn or redirect",
+			content: "frontend tls\n\tbind :80\n\tdefault_backend app\n",
+			want:    "frontend tls\n\tbind :80\n\tbind :443 ssl crt /etc/haproxy/certs\n\tdefault_backend app\n",
+		},
+		{
+			name:    "append crt-list to existing ssl bind",
+			content: "frontend web\n    bind :443 ssl crt /etc/ssl/default.pem\n",
+			crtList: crtList,
+			want:    "frontend web\n    bind :443 ssl crt /etc/ssl/default.pem crt-list /etc/haproxy/crt-list.txt\n",
+		},
+		{
+			name:    "bind already references cert dir",
+			content: "frontend web\n    bind :443 ssl crt /etc/haproxy/certs/\n",
+		},
+		{
+			name:    "bind references cert dir through crt-base",
+			content: "global\n    crt-base /etc/haproxy\n\nfrontend web\n    bind :443 ssl crt certs\n",
+		},
+		{
+			name: "existing redirect is kept",
+			content: "frontend web\n    mode http\n    bind :80\n    bind :443 ssl crt /etc/haproxy/certs\n" +
+				"    redirect scheme https code 301 if !{ ssl_fc }\n",
+			redirect: true,
+		},
+		{
+			name:     "redirect added next to existing ssl bind",
+			content:  "frontend web\n    mode http\n    bind :80\n    bind :443 ssl crt /etc/haproxy/certs\n",
+			redirect: true,
+			want: "frontend web\n    mode http\n    bind :80\n    bind :443 ssl crt /etc/haproxy/certs\n" +
+				"    http-request redirect scheme https code 301 unless { ssl_fc }\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := certDir
+			if tt.crtList != "" {
+				dir = ""
+			}
+			got := updateFrontendPlan(t, tt.content, tt.crtList, dir, tt.redirect)
+			if got != tt.want {
+				t.Fatalf("updated config:\n%s\nwant:\n%s", got, tt.want)
+			}
+
+			// 再次执行不应产生修改
+			if got != "" {
+				if again := updateFrontendPlan(t, got, tt.crtList, dir, tt.redirect); again != "" {
+					t.Errorf("second run changed config:\n%s", again)
+				}
+			}
+		})
+	}
+}
+
+func TestHAProxyUpdateFrontendWithoutFrontend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "haproxy.cfg")
+	h := &HAProxyConfigurator{changes: newChangeSet(true)}
+	cfg := parseHAProxyConfig(path, "global\n    daemon\n")
+	if err := h.updateFrontend(cfg, "", "/etc/haproxy/certs", &Config{}); err == nil {
+		t.Error("expected error for config without frontend")
+	}
+}
+
+func TestHAProxyInstallCrtList(t *testing.T) {
+	const combined = "/etc/autocert/certs/example.com/combined.pem"
+
+	tests := []struct {
+		name    string
+		current string // 为空时 crt-list 不存在
+		want    string // 为空时期望不修改
+	}{
+		{
+			name: "create crt-list",
+			want: combined + "\n",
+		},
+		{
+			name:    "append to existing entries",
+			current: "/etc/ssl/other.pem [alpn h2]\n# 注释\n",
+			want:    "/etc/ssl/other.pem [alpn h2]\n# 注释\n" + combined + "\n",
+		},
+		{
+			name:    "existing entry without trailing newline",
+			current: "/etc/ssl/other.pem",
+			want:    "/etc/ssl/other.pem\n" + combined + "\n",
+		},
+		{
+			name:    "entry already present with options",
+			current: "/etc/ssl/other.pem\n" + combined + " [ocsp-update on] example.com\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crtList := filepath.Join(t.TempDir(), "crt-list.txt")
+			if tt.current != "" {
+				if err := os.WriteFile(crtList, []byte(tt.current), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			h := &HAProxyConfigurator{changes: newChangeSet(true)}
+			config := &Config{CombinedPath: combined}
+			if err := h.installCrtList(crtList, config); err != nil {
+				t.Fatalf("installCrtList: %v", err)
+			}
+
+			got := ""
+			for _, f := range h.changes.plan.Files {
+				if f.Path == crtList {
+					got = f.New
+				}
+			}
+			if got != tt.want {
+				t.Fatalf("crt-list:\n%s\nwant:\n%s", got, tt.want)
+			}
+
+			// 写入后再次执行不应产生修改
+			if got != "" {
+				if err := os.WriteFile(crtList, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				h.changes = newChangeSet(true)
+				if err := h.installCrtList(crtList, config); err != nil {
+					t.Fatal(err)
+				}
+				if len(h.changes.plan.Files) != 0 {
+					t.Errorf("second run changed crt-list:\n%s", h.changes.plan.Files[0].New)
+				}
+			}
+		})
+	}
+}
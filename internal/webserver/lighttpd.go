@@ -0,0 +1,381 @@
+package webserver
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// lighttpdConfigPaths lighttpd.conf 的常见位置，包括嵌入式设备（Entware、OpenWrt）
+var lighttpdConfigPaths = []string{
+	"/etc/lighttpd/lighttpd.conf",
+	"/usr/local/etc/lighttpd/lighttpd.conf",
+	"/opt/etc/lighttpd/lighttpd.conf",
+}
+
+// lighttpdPIDFiles 没有 systemd 时用于发送重载信号的 PID 文件
+var lighttpdPIDFiles = []string{
+	"/run/lighttpd.pid",
+	"/var/run/lighttpd.pid",
+}
+
+// lighttpdSiteDir AutoCert 写入证书配置的目录（相对 lighttpd.conf 所在目录）
+const lighttpdSiteDir = "autocert.d"
+
+// lighttpdSocketFile 监听 443 端口的配置，文件名排在证书配置之前
+const lighttpdSocketFile = "00-autocert-socket.conf"
+
+var (
+	lighttpdIncludePattern = regexp.MustCompile(`(?m)^\s*include\s+"([^"]+)"`)
+	lighttpdSocket443      = regexp.MustCompile(`\$SERVER\["socket"\]\s*==\s*"[^"]*:443"`)
+	lighttpdVersionPattern = regexp.MustCompile(`lighttpd/(\d+)\.(\d+)\.(\d+)`)
+)
+
+// LighttpdConfigurator lighttpd 配置器
+// 每个证书在 autocert.d 中有一个按 Host 匹配的配置，通过 SNI 选择证书
+type LighttpdConfigurator struct {
+	configPath string
+	changes    *changeSet
+	reloadOpts config.ReloadConfig
+}
+
+// lighttpdVersion lighttpd 版本
+type lighttpdVersion [3]int
+
+// atLeast 版本是否不低于 major.minor.patch
+func (v lighttpdVersion) atLeast(major, minor, patch int) bool {
+	want := lighttpdVersion{major, minor, patch}
+	for i := range v {
+		if v[i] != want[i] {
+			return v[i] > want[i]
+		}
+	}
+	return true
+}
+
+// Configure 配置 lighttpd
+func (l *LighttpdConfigurator) Configure(config *Config) error {
+	logger.Info("开始配置 lighttpd", "domain", config.Domain)
+	l.changes = newChangeSet(false)
+	l.reloadOpts = config.Reload
+	return l.configure(config)
+}
+
+// Plan 计算 Configure 将执行的修改
+func (l *LighttpdConfigurator) Plan(config *Config) (*Plan, error) {
+	l.changes = newChangeSet(true)
+	l.reloadOpts = config.Reload
+	if err := l.configure(config); err != nil {
+		return nil, err
+	}
+
+	r, err := l.reloadStrategy()
+	if err != nil {
+		return nil, err
+	}
+	plan := l.changes.plan
+	plan.Commands = append(plan.Commands, r.planCommands()...)
+	return plan, nil
+}
+
+// configure 写入证书配置，必要时添加 443 端口监听并在主配置中包含 autocert.d
+func (l *LighttpdConfigurator) configure(config *Config) error {
+	if _, err := l.reloadStrategy(); err != nil {
+		return fmt.Errorf("重载配置无效: %w", err)
+	}
+
+	if err := l.findConfigPath(config.ConfigPath); err != nil {
+		return err
+	}
+
+	main, err := os.ReadFile(l.configPath)
+	if err != nil {
+		return fmt.Errorf("读取 lighttpd 配置失败: %w", err)
+	}
+	siteDir := filepath.Join(filepath.Dir(l.configPath), lighttpdSiteDir)
+	existing := l.loadConfigText(siteDir)
+	version := l.version()
+
+	if err := l.changes.mkdirAll(siteDir); err != nil {
+		return err
+	}
+
+	// 1. 没有监听 443 端口时添加 $SERVER["socket"] 块，默认证书使用本证书
+	socketFile := filepath.Join(siteDir, lighttpdSocketFile)
+	socketContent := readFileString(socketFile)
+	if socketContent == "" {
+		socketContent = lighttpdSocketConfig(config, version, existing)
+		if err := l.changes.writeFile(socketFile, []byte(socketContent), 0644); err != nil {
+			return err
+		}
+		logger.Info("添加 lighttpd HTTPS 监听", "file", socketFile)
+	}
+
+	// 2. 证书配置，重定向需要 mod_redirect 和 1.4.50 起支持的 ${url.authority}
+	redirect := config.Redirect
+	if redirect && (!version.atLeast(1, 4, 50) || !strings.Contains(existing+socketContent, "mod_redirect")) {
+		logger.Warn("lighttpd 版本低于 1.4.50 或没有加载 mod_redirect，跳过 HTTP 重定向")
+		redirect = false
+	}
+	siteFile := filepath.Join(siteDir, siteFileName(config)+".conf")
+	content := []byte(lighttpdSiteConfig(config, version, redirect))
+	if old, err := os.ReadFile(siteFile); err != nil || !bytes.Equal(old, content) {
+		if err := l.changes.writeFile(siteFile, content, 0644); err != nil {
+			return err
+		}
+		logger.Info("写入 lighttpd 证书配置", "file", siteFile)
+	}
+
+	// 3. 主配置包含 autocert.d
+	include := filepath.ToSlash(filepath.Join(siteDir, "*.conf"))
+	if !strings.Contains(string(main), include) {
+		updated := strings.TrimRight(string(main), "\n") +
+			fmt.Sprintf("\n\n# AutoCert 证书配置\ninclude \"%s\"\n", include)
+		backupPath, err := l.changes.updateFile(l.configPath, []byte(updated))
+		if err != nil {
+			return err
+		}
+		logger.Info("在 lighttpd 主配置中包含证书配置", "configFile", l.configPath, "backup", backupPath)
+	}
+
+	logger.Info("lighttpd 配置完成", "domain", config.Domain)
+	return nil
+}
+
+// lighttpdSocketConfig 监听 443 端口的配置和需要加载的模块
+// 1.4.56 起 TLS 需要单独加载 mod_openssl；已有的配置中加载过的模块不再重复加载
+func lighttpdSocketConfig(config *Config, version lighttpdVersion, existing string) string {
+	var sb strings.Builder
+	sb.WriteString("# 由 AutoCert 生成\n")
+
+	var modules []string
+	if version.atLeast(1, 4, 56) && !strings.Contains(existing, "mod_openssl") {
+		modules = append(modules, `"mod_openssl"`)
+	}
+	if config.Redirect && version.atLeast(1, 4, 50) && !strings.Contains(existing, "mod_redirect") {
+		modules = append(modules, `"mod_redirect"`)
+	}
+	if len(modules) > 0 {
+		fmt.Fprintf(&sb, "server.modules += ( %s )\n", strings.Join(modules, ", "))
+	}
+
+	if !lighttpdSocket443.MatchString(existing) {
+		sb.WriteString("\n$SERVER[\"socket\"] == \":443\" {\n")
+		sb.WriteString("    ssl.engine = \"enable\"\n")
+		sb.WriteString(lighttpdCertLines(config, version, "    "))
+		sb.WriteString("}\n")
+	}
+	return sb.String()
+}
+
+// lighttpdSiteConfig 按 Host 选择证书的配置
+func lighttpdSiteConfig(config *Config, version lighttpdVersion, redirect bool) string {
+	var sb strings.Builder
+	sb.WriteString("# 由 AutoCert 生成，请勿手动修改\n")
+	fmt.Fprintf(&sb, "# 证书: %s\n", config.Name)
+	fmt.Fprintf(&sb, "$HTTP[\"host\"] =~ \"%s\" {\n", lighttpdHostPattern(strings.Fields(config.Domain)))
+	sb.WriteString(lighttpdCertLines(config, version, "    "))
+	if redirect {
+		sb.WriteString("    $HTTP[\"scheme\"] == \"http\" {\n")
+		sb.WriteString("        url.redirect = ( \"\" => \"https://${url.authority}${url.path}${qsa}\" )\n")
+		sb.WriteString("        url.redirect-code = 301\n")
+		sb.WriteString("    }\n")
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// lighttpdCertLines 证书指令
+// 1.4.53 起 ssl.privkey 可以单独指定私钥，更早的版本 ssl.pemfile 需要同时包含私钥和证书链
+func lighttpdCertLines(config *Config, version lighttpdVersion, indent string) string {
+	if version.atLeast(1, 4, 53) {
+		return fmt.Sprintf("%sssl.pemfile = \"%s\"\n%sssl.privkey = \"%s\"\n",
+			indent, config.FullchainPath, indent, config.KeyPath)
+	}
+	return fmt.Sprintf("%sssl.pemfile = \"%s\"\n", indent, config.CombinedPath)
+}
+
+// lighttpdHostPattern 匹配证书中所有域名的正则表达式，泛域名只匹配一级子域名
+func lighttpdHostPattern(domains []string) string {
+	var parts []string
+	for _, d := range domains {
+		if strings.HasPrefix(d, "*.") {
+			parts = append(parts, `[^.]+\.`+regexp.QuoteMeta(d[2:]))
+		} else {
+			parts = append(parts, regexp.QuoteMeta(d))
+		}
+	}
+	return "^(" + strings.Join(parts, "|") + ")(:[0-9]+)?$"
+}
+
+// loadConfigText 读取主配置及其 include 的文件，不包括 AutoCert 自己的目录
+func (l *LighttpdConfigurator) loadConfigText(siteDir string) string {
+	var sb strings.Builder
+	visited := map[string]bool{siteDir: true}
+
+	var load func(path string)
+	load = func(path string) {
+		if visited[path] || filepath.Dir(path) == siteDir {
+			return
+		}
+		visited[path] = true
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		sb.Write(data)
+		sb.WriteString("\n")
+
+		for _, m := range lighttpdIncludePattern.FindAllStringSubmatch(string(data), -1) {
+			pattern := m[1]
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(l.configPath), pattern)
+			}
+			matches, _ := filepath.Glob(pattern)
+			for _, inc := range matches {
+				load(inc)
+			}
+		}
+	}
+
+	load(l.configPath)
+	return sb.String()
+}
+
+// readFileString 读取文件内容，文件不存在时返回空字符串
+func readFileString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// version 通过 lighttpd -v 获取版本，无法获取时按 1.4.53 之前处理
+func (l *LighttpdConfigurator) version() lighttpdVersion {
+	output, err := exec.Command("lighttpd", "-v").CombinedOutput()
+	if err != nil {
+		logger.Debug("获取 lighttpd 版本失败", "error", err)
+		return lighttpdVersion{}
+	}
+	return parseLighttpdVersion(string(output))
+}
+
+// parseLighttpdVersion 解析 lighttpd -v 的输出，例如 lighttpd/1.4.69 (ssl)
+func parseLighttpdVersion(output string) lighttpdVersion {
+	var v lighttpdVersion
+	m := lighttpdVersionPattern.FindStringSubmatch(output)
+	if m == nil {
+		return v
+	}
+	for i := range v {
+		fmt.Sscanf(m[i+1], "%d", &v[i])
+	}
+	return v
+}
+
+// findConfigPath 查找 lighttpd.conf
+func (l *LighttpdConfigurator) findConfigPath(configPath string) error {
+	if configPath != "" {
+		l.configPath = configPath
+		return nil
+	}
+	for _, path := range lighttpdConfigPaths {
+		if _, err := os.Stat(path); err == nil {
+			l.configPath = path
+			return nil
+		}
+	}
+	return fmt.Errorf("未找到 lighttpd 配置文件")
+}
+
+// Test 测试 lighttpd 配置
+func (l *LighttpdConfigurator) Test() error {
+	r, err := l.reloadStrategy()
+	if err != nil {
+		return err
+	}
+	if err := r.runTest(); err != nil {
+		return fmt.Errorf("lighttpd 配置测试失败: %w", err)
+	}
+	logger.Info("lighttpd 配置测试成功")
+	return nil
+}
+
+// Reload 重载 lighttpd 配置
+func (l *LighttpdConfigurator) Reload() error {
+	r, err := l.reloadStrategy()
+	if err != nil {
+		return err
+	}
+	if err := r.runReload(); err != nil {
+		return fmt.Errorf("重载 lighttpd 失败: %w", err)
+	}
+	logger.Info("lighttpd 配置重载成功")
+	return nil
+}
+
+// reloadStrategy 根据配置的重载方式创建重载器
+// lighttpd 收到 SIGUSR1 后平滑重启；没有 systemd 的嵌入式设备通过 PID 文件发送信号
+func (l *LighttpdConfigurator) reloadStrategy() (*reloader, error) {
+	test := []string{"lighttpd", "-tt"}
+	if l.configPath != "" {
+		test = append(test, "-f", l.configPath)
+	}
+
+	opts := l.reloadOpts
+	reload := []string{"systemctl", "reload", "lighttpd"}
+	if _, err := exec.LookPath("systemctl"); err != nil && opts.Method == "" {
+		reload = []string{"killall", "-USR1", "lighttpd"}
+		for _, pidFile := range lighttpdPIDFiles {
+			if _, err := os.Stat(pidFile); err == nil {
+				opts.Method = ReloadSignal
+				opts.PIDFile = pidFile
+				opts.Signal = "USR1"
+				break
+			}
+		}
+	}
+	return newReloader(opts, "lighttpd", test, reload)
+}
+
+// Rollback 撤销最近一次 Configure 对文件的修改
+func (l *LighttpdConfigurator) Rollback() ([]string, error) {
+	if l.changes == nil {
+		return nil, nil
+	}
+	return l.changes.rollback()
+}
+
+// GetConfigPath 获取配置路径
+func (l *LighttpdConfigurator) GetConfigPath() string {
+	if l.configPath == "" {
+		l.findConfigPath("")
+	}
+	return l.configPath
+}
+
+// IsSSLEnabled 检查 autocert.d 中是否有包含该域名的证书
+func (l *LighttpdConfigurator) IsSSLEnabled(domain string) bool {
+	if l.GetConfigPath() == "" {
+		return false
+	}
+
+	files, _ := filepath.Glob(filepath.Join(filepath.Dir(l.configPath), lighttpdSiteDir, "*.conf"))
+	pemPattern := regexp.MustCompile(`ssl\.pemfile\s*=\s*"([^"]+)"`)
+	for _, f := range files {
+		for _, m := range pemPattern.FindAllStringSubmatch(readFileString(f), -1) {
+			if pemCoversDomain(m[1], domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package webserver
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// nginxBinary Nginx 或兼容发行版（OpenResty、Tengine）的可执行文件
+type nginxBinary struct {
+	path string // 可执行文件
+	unit string // systemd 服务名
+}
+
+// nginxBinaryCandidates 依次尝试的可执行文件，包括 OpenResty、Tengine 的常见安装前缀
+var nginxBinaryCandidates = []string{
+	"nginx",
+	"openresty",
+	"tengine",
+	"/usr/local/openresty/bin/openresty",
+	"/usr/local/openresty/nginx/sbin/nginx",
+	"/usr/local/tengine/sbin/nginx",
+	"/opt/tengine/sbin/nginx",
+	"/usr/local/nginx/sbin/nginx",
+}
+
+// findNginxBinary 查找可执行文件，configured 为配置文件中的 webserver.binary
+// 都找不到时返回 nginx，由执行命令时报告错误
+func findNginxBinary(configured string) nginxBinary {
+	candidates := nginxBinaryCandidates
+	if configured != "" {
+		candidates = []string{configured}
+	}
+
+	for _, candidate := range candidates {
+		path, err := exec.LookPath(candidate)
+		if err != nil {
+			continue
+		}
+		output, _ := exec.Command(path, "-v").CombinedOutput()
+		return nginxBinary{path: path, unit: nginxVariantUnit(string(output))}
+	}
+	return nginxBinary{path: "nginx", unit: "nginx"}
+}
+
+// nginxVariantUnit 根据 -v 的输出判断发行版对应的服务名
+// 例如 "nginx version: openresty/1.25.3.1"、"Tengine version: Tengine/3.1.0"
+func nginxVariantUnit(versionOutput string) string {
+	lower := strings.ToLower(versionOutput)
+	switch {
+	case strings.Contains(lower, "openresty"):
+		return "openresty"
+	case strings.Contains(lower, "tengine"):
+		return "tengine"
+	default:
+		return "nginx"
+	}
+}
@@ -0,0 +1,318 @@
+package webserver
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// nginxEdit 一次文本替换
+type nginxEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// listenIsSSL listen 指令是否为 HTTPS 监听
+func listenIsSSL(args []string) bool {
+	for _, arg := range args {
+		if arg == "ssl" || arg == "quic" {
+			return true
+		}
+	}
+	return len(args) > 0 && listenPort(args[0]) == "443"
+}
+
+// listenIsHTTP listen 指令是否为 80 端口的 HTTP 监听
+func listenIsHTTP(args []string) bool {
+	return len(args) > 0 && !listenIsSSL(args) && listenPort(args[0]) == "80"
+}
+
+// listenPort 解析 listen 地址中的端口，未指定端口时为 80
+func listenPort(addr string) string {
+	if strings.HasPrefix(addr, "unix:") {
+		return ""
+	}
+	if i := strings.LastIndex(addr, "]"); i >= 0 {
+		addr = addr[i+1:]
+	}
+	if i := strings.LastIndex(addr, ":"); i >= 0 {
+		return addr[i+1:]
+	}
+	if addr != "" && strings.Trim(addr, "0123456789") == "" {
+		return addr
+	}
+	return "80"
+}
+
+// configureExistingSites 在已有的 server 块中添加或更新 SSL 配置
+// 返回被修改的文件列表；没有找到匹配的 server 块时返回空列表
+func (n *NginxConfigurator) configureExistingSites(config *Config) ([]string, error) {
+	domains := strings.Fields(config.Domain)
+	var modified []string
+
+	parsed, err := n.loadConfig()
+	if err != nil {
+		logger.Warn("解析 Nginx 配置失败，将创建新的站点配置", "error", err)
+		return nil, nil
+	}
+
+	tls, err := newTLSSettings(config.Security)
+	if err != nil {
+		return nil, err
+	}
+
+	// 只修改所有名称都在证书范围内的 server 块，否则替换证书会影响块中其他名称的 TLS
+	var matched []nginxServer
+	for _, server := range parsed.Servers() {
+		// 旧版本生成的站点配置由 createSiteConfig 按新的文件名重新生成
+		if !server.MatchesAny(domains) || isLegacySite(server.File.Path, config) {
+			continue
+		}
+		if !server.coveredBy(domains) {
+			logger.Warn("server 块包含证书未覆盖的名称，不修改",
+				"file", server.File.Path, "line", server.Directive.Line, "serverName", strings.Join(server.serverNames(), " "))
+			continue
+		}
+		matched = append(matched, server)
+	}
+
+	// 已有 HTTPS server 块时只更新证书路径，避免给 HTTP 重定向块也加上 SSL
+	// HTTP 和 HTTPS 的 server 块常常分在不同文件中，需要在所有匹配的 server 块中判断
+	sslOnly := false
+	for _, server := range matched {
+		if server.hasSSL() {
+			sslOnly = true
+			break
+		}
+	}
+
+	// 按文件分组，保持解析顺序
+	byFile := make(map[string][]nginxServer)
+	var files []*nginxConfigFile
+	for _, server := range matched {
+		if sslOnly && !server.hasSSL() {
+			continue
+		}
+		if _, ok := byFile[server.File.Path]; !ok {
+			files = append(files, server.File)
+		}
+		byFile[server.File.Path] = append(byFile[server.File.Path], server)
+	}
+
+	for _, file := range files {
+		servers := byFile[file.Path]
+
+		// 偏移量基于解析时的内容，文件已被修改时不能直接应用
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			return modified, err
+		}
+		content := string(data)
+		if content != file.Content {
+			return modified, fmt.Errorf("配置文件 %s 在解析后被修改", file.Path)
+		}
+
+		var edits []nginxEdit
+		for _, server := range servers {
+			edits = append(edits, planServerBlockEdits(content, server, config, tls)...)
+		}
+
+		newContent := applyEdits(content, edits)
+		if newContent == content {
+			logger.Info("Nginx 站点配置已是最新", "configFile", file.Path)
+			modified = append(modified, file.Path)
+			continue
+		}
+
+		backupPath, err := n.changes.updateFile(file.Path, []byte(newContent))
+		if err != nil {
+			return modified, err
+		}
+
+		logger.Info("更新已有 Nginx 站点配置", "configFile", file.Path, "backup", backupPath, "serverBlocks", len(servers))
+		modified = append(modified, file.Path)
+	}
+
+	return modified, nil
+}
+
+// planServerBlockEdits 计算一个 server 块需要的修改
+func planServerBlockEdits(content string, server nginxServer, config *Config, tls *TLSSettings) []nginxEdit {
+	var edits []nginxEdit
+	block := server.Directive
+	indent := blockIndent(content, block)
+
+	// 1. 更新或添加证书路径
+	var missing []string
+	certDirectives := [][]string{
+		{"ssl_certificate", config.FullchainPath},
+		{"ssl_certificate_key", config.KeyPath},
+	}
+	if tls.OCSPStapling {
+		certDirectives = append(certDirectives, []string{"ssl_trusted_certificate", config.ChainPath})
+	}
+	for _, cd := range certDirectives {
+		existing := server.find(cd[0])
+		if len(existing) == 0 {
+			missing = append(missing, fmt.Sprintf("%s%s;\n", indent, strings.Join(cd, " ")))
+			continue
+		}
+		for _, d := range existing {
+			edits = append(edits, nginxEdit{start: d.Start, end: d.End, text: strings.Join(cd, " ") + ";"})
+		}
+	}
+
+	// 安全配置：新启用 HTTPS 的 server 块补充缺少的指令，显式配置了安全选项时同时更新已有的指令
+	explicit := securityConfigured(config.Security)
+	for _, sd := range tls.nginxDirectives() {
+		existing := server.findSecurityDirective(sd)
+		text := strings.Join(sd, " ") + ";"
+		switch {
+		case existing == nil && (explicit || !server.hasSSL()):
+			missing = append(missing, indent+text+"\n")
+		case existing != nil && explicit:
+			edits = append(edits, nginxEdit{start: existing.Start, end: existing.End, text: text})
+		}
+	}
+
+	// 2. 添加 HTTPS 监听
+	listens := server.find("listen")
+	var insert []string
+	if !server.hasSSL() {
+		insert = append(insert, indent+"listen 443 ssl;\n")
+		for _, d := range listens {
+			if len(d.Args) > 0 && strings.HasPrefix(d.Args[0], "[") {
+				insert = append(insert, indent+"listen [::]:443 ssl;\n")
+				break
+			}
+		}
+	}
+	insert = append(insert, missing...)
+
+	// 3. 可选的 HTTP 重定向：把 80 端口的监听移到新的重定向 server 块中
+	var httpListens []*nginxDirective
+	if config.Redirect && !server.hasSSL() {
+		for _, d := range listens {
+			if listenIsHTTP(d.Args) {
+				httpListens = append(httpListens, d)
+			}
+		}
+		for _, d := range httpListens {
+			edits = append(edits, nginxEdit{start: d.LineStart, end: lineEnd(content, d.End), text: ""})
+		}
+	}
+
+	if len(insert) > 0 {
+		pos := insertPosition(content, block, listens)
+		edits = append(edits, nginxEdit{start: pos, end: pos, text: strings.Join(insert, "")})
+	}
+
+	if len(httpListens) > 0 {
+		edits = append(edits, nginxEdit{
+			start: block.Close + 1,
+			end:   block.Close + 1,
+			text:  redirectServerBlock(server, httpListens, config),
+		})
+	}
+
+	return edits
+}
+
+// findSecurityDirective 查找与安全配置指令对应的已有指令，add_header 按头名称匹配
+func (s *nginxServer) findSecurityDirective(sd []string) *nginxDirective {
+	for _, d := range s.find(sd[0]) {
+		if sd[0] != "add_header" || len(d.Args) > 0 && strings.EqualFold(d.Args[0], sd[1]) {
+			return d
+		}
+	}
+	return nil
+}
+
+// redirectServerBlock 生成 HTTP 到 HTTPS 的重定向 server 块
+func redirectServerBlock(server nginxServer, listens []*nginxDirective, config *Config) string {
+	var sb strings.Builder
+	sb.WriteString("\n\n# AutoCert: HTTP 重定向到 HTTPS\nserver {\n")
+	for _, d := range listens {
+		sb.WriteString(fmt.Sprintf("    listen %s;\n", strings.Join(d.Args, " ")))
+	}
+	sb.WriteString(fmt.Sprintf("    server_name %s;\n", strings.Join(server.serverNames(), " ")))
+	if config.ChallengeDir != "" {
+		sb.WriteString("\n    location ^~ /.well-known/acme-challenge/ {\n")
+		sb.WriteString("        default_type \"text/plain\";\n")
+		sb.WriteString(fmt.Sprintf("        root %s;\n", config.ChallengeDir))
+		sb.WriteString("    }\n")
+	}
+	sb.WriteString("\n    location / {\n        return 301 https://$host$request_uri;\n    }\n}")
+	return sb.String()
+}
+
+// insertPosition 新指令插入位置：最后一个 listen 之后，没有 listen 时紧跟在 '{' 之后
+func insertPosition(content string, block *nginxDirective, listens []*nginxDirective) int {
+	if len(listens) > 0 {
+		return lineEnd(content, listens[len(listens)-1].End)
+	}
+	return lineEnd(content, block.Open+1)
+}
+
+// lineEnd 返回 pos 所在行换行符之后的位置
+func lineEnd(content string, pos int) int {
+	if i := strings.IndexByte(content[pos:], '\n'); i >= 0 {
+		return pos + i + 1
+	}
+	return len(content)
+}
+
+// blockIndent 推断 server 块内指令的缩进
+func blockIndent(content string, block *nginxDirective) string {
+	for _, d := range block.Block {
+		if d.LineStart <= d.Start {
+			prefix := content[d.LineStart:d.Start]
+			if strings.TrimSpace(prefix) == "" {
+				return prefix
+			}
+		}
+	}
+	return "    "
+}
+
+// applyEdits 从后往前应用修改，避免偏移量失效
+func applyEdits(content string, edits []nginxEdit) string {
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+	for _, e := range edits {
+		content = content[:e.start] + e.text + content[e.end:]
+	}
+	return content
+}
+
+// backupConfigFile 将配置文件备份到 AutoCert 配置目录
+// 不能备份到原目录，否则会被 include 通配符当作站点配置加载
+func backupConfigFile(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	backupDir := filepath.Join(config.GetConfigDir(), "backups", "webserver", time.Now().Format("20060102-150405"))
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return "", err
+	}
+
+	// 保留原始路径结构，Windows 盘符中的冒号不能出现在路径中间
+	relPath := strings.ReplaceAll(strings.TrimPrefix(filepath.ToSlash(file), "/"), ":", "")
+	backupPath := filepath.Join(backupDir, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(backupPath, data, 0600); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
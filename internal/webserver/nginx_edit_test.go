@@ -0,0 +1,261 @@
+package webserver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListenPort(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"80", "80"},
+		{"443", "443"},
+		{"127.0.0.1", "80"},
+		{"127.0.0.1:8080", "8080"},
+		{"[::]:443", "443"},
+		{"[::1]", "80"},
+		{"example.com", "80"},
+		{"unix:/run/nginx.sock", ""},
+	}
+	for _, tt := range tests {
+		if got := listenPort(tt.addr); got != tt.want {
+			t.Errorf("listenPort(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+// nginxSecurityLines 默认安全配置生成的指令，缩进为四个空格
+func nginxSecurityLines(t *testing.T) string {
+	t.Helper()
+	tls, err := newTLSSettings((&Config{}).Security)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	for _, sd := range tls.nginxDirectives() {
+		sb.WriteString("    " + strings.Join(sd, " ") + ";\n")
+	}
+	return sb.String()
+}
+
+// configureExistingSitesPlan 在临时目录中写入 Nginx 配置，以预览模式执行 configureExistingSites
+// files 的键为相对 sites 目录的文件名，返回被修改的文件名和修改后的内容
+func configureExistingSitesPlan(t *testing.T, files map[string]string, config *Config) ([]string, map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	siteDir := filepath.Join(dir, "sites")
+	if err := os.MkdirAll(siteDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	mainConfig := filepath.Join(dir, "nginx.conf")
+	if err := os.WriteFile(mainConfig, []byte("http {\n    include sites/*.conf;\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(siteDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n := &NginxConfigurator{configPath: mainConfig, changes: newChangeSet(true)}
+	modified, err := n.configureExistingSites(config)
+	if err != nil {
+		t.Fatalf("configureExistingSites: %v", err)
+	}
+
+	var names []string
+	for _, path := range modified {
+		names = append(names, filepath.Base(path))
+	}
+	updated := make(map[string]string)
+	for _, f := range n.changes.plan.Files {
+		updated[filepath.Base(f.Path)] = f.New
+	}
+	return names, updated
+}
+
+func testNginxEditConfig(domain string, redirect bool) *Config {
+	return &Config{
+		Domain:        domain,
+		FullchainPath: "/etc/autocert/certs/example.com/fullchain.pem",
+		KeyPath:       "/etc/autocert/certs/example.com/key.pem",
+		ChainPath:     "/etc/autocert/certs/example.com/chain.pem",
+		ChallengeDir:  "/var/lib/autocert/acme",
+		Redirect:      redirect,
+	}
+}
+
+func TestConfigureExistingSitesRedirectSplit(t *testing.T) {
+	site := "server {\n" +
+		"    listen 80;\n" +
+		"    listen [::]:80;\n" +
+		"    server_name example.com www.example.com;\n" +
+		"    root /var/www/example;\n" +
+		"}\n"
+
+	modified, updated := configureExistingSitesPlan(t, map[string]string{"example.conf": site},
+		testNginxEditConfig("example.com www.example.com", true))
+
+	want := "server {\n" +
+		"    listen 443 ssl;\n" +
+		"    listen [::]:443 ssl;\n" +
+		"    ssl_certificate /etc/autocert/certs/example.com/fullchain.pem;\n" +
+		"    ssl_certificate_key /etc/autocert/certs/example.com/key.pem;\n" +
+		nginxSecurityLines(t) +
+		"    server_name example.com www.example.com;\n" +
+		"    root /var/www/example;\n" +
+		"}\n" +
+		"\n# AutoCert: HTTP 重定向到 HTTPS\n" +
+		"server {\n" +
+		"    listen 80;\n" +
+		"    listen [::]:80;\n" +
+		"    server_name example.com www.example.com;\n" +
+		"\n" +
+		"    location ^~ /.well-known/acme-challenge/ {\n" +
+		"        default_type \"text/plain\";\n" +
+		"        root /var/lib/autocert/acme;\n" +
+		"    }\n" +
+		"\n" +
+		"    location / {\n" +
+		"        return 301 https://$host$request_uri;\n" +
+		"    }\n" +
+		"}\n"
+
+	if strings.Join(modified, ",") != "example.conf" {
+		t.Fatalf("modified = %v", modified)
+	}
+	if got := updated["example.conf"]; got != want {
+		t.Errorf("updated config:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestConfigureExistingSites(t *testing.T) {
+	const fullchain = "ssl_certificate /etc/autocert/certs/example.com/fullchain.pem;"
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		domain   string
+		redirect bool
+		modified []string            // 被修改（或已是最新）的文件
+		contains map[string][]string // 文件修改后应包含的内容
+		excludes map[string][]string // 文件修改后不应包含的内容
+	}{
+		{
+			name: "no redirect keeps http listen",
+			files: map[string]string{
+				"a.conf": "server {\n\tlisten 80;\n\tserver_name example.com;\n}\n",
+			},
+			domain:   "example.com",
+			modified: []string{"a.conf"},
+			contains: map[string][]string{"a.conf": {"\tlisten 80;\n\tlisten 443 ssl;\n\t" + fullchain}},
+			excludes: map[string][]string{"a.conf": {"return 301"}},
+		},
+		{
+			name: "existing ssl block updates certificate in place",
+			files: map[string]string{
+				"a.conf": "server {\n    listen 443 ssl;\n    server_name example.com;\n" +
+					"    ssl_certificate /old/fullchain.pem;\n    ssl_certificate_key /old/key.pem;\n    ssl_protocols TLSv1.2;\n}\n",
+			},
+			domain:   "example.com",
+			redirect: true,
+			modified: []string{"a.conf"},
+			contains: map[string][]string{"a.conf": {"    server_name example.com;\n    " + fullchain +
+				"\n    ssl_certificate_key /etc/autocert/certs/example.com/key.pem;\n    ssl_protocols TLSv1.2;\n}\n"}},
+			excludes: map[string][]string{"a.conf": {"/old/"}},
+		},
+		{
+			name: "ssl-only decided across files",
+			files: map[string]string{
+				"a-http.conf":  "server {\n    listen 80;\n    server_name example.com;\n    return 301 https://$host$request_uri;\n}\n",
+				"b-https.conf": "server {\n    listen 443 ssl;\n    server_name example.com;\n    ssl_certificate /old/fullchain.pem;\n}\n",
+			},
+			domain:   "example.com",
+			redirect: true,
+			modified: []string{"b-https.conf"},
+			contains: map[string][]string{"b-https.conf": {fullchain}},
+		},
+		{
+			name: "ssl-only within one file",
+			files: map[string]string{
+				"a.conf": "server {\n    listen 80;\n    server_name example.com;\n    return 301 https://$host$request_uri;\n}\n" +
+					"server {\n    listen 443 ssl;\n    server_name example.com;\n}\n",
+			},
+			domain:   "example.com",
+			redirect: true,
+			modified: []string{"a.conf"},
+			contains: map[string][]string{"a.conf": {"server {\n    listen 80;\n    server_name example.com;\n    return 301 https://$host$request_uri;\n}\n" +
+				"server {\n    listen 443 ssl;\n    " + fullchain}},
+		},
+		{
+			name: "uncovered names are left alone",
+			files: map[string]string{
+				"a.conf": "server {\n    listen 80;\n    server_name example.com api.example.com;\n}\n",
+				"b.conf": "server {\n    listen 80;\n    server_name *.example.com;\n}\n",
+			},
+			domain: "example.com",
+		},
+		{
+			name: "quoted tokens and comments are preserved",
+			files: map[string]string{
+				"a.conf": "server {\n    listen 80; # listen 443 ssl;\n    server_name \"example.com\";\n" +
+					"    add_header X-Note \"a;b } # c\";\n}\n",
+			},
+			domain:   "example.com",
+			modified: []string{"a.conf"},
+			contains: map[string][]string{"a.conf": {
+				"    listen 80; # listen 443 ssl;\n    listen 443 ssl;\n    " + fullchain,
+				"    server_name \"example.com\";\n    add_header X-Note \"a;b } # c\";\n}\n",
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modified, updated := configureExistingSitesPlan(t, tt.files, testNginxEditConfig(tt.domain, tt.redirect))
+			if strings.Join(modified, ",") != strings.Join(tt.modified, ",") {
+				t.Errorf("modified = %v, want %v", modified, tt.modified)
+			}
+			for name := range tt.files {
+				_, changed := updated[name]
+				_, want := tt.contains[name]
+				if changed != want {
+					t.Errorf("%s changed = %v, want %v", name, changed, want)
+				}
+			}
+			for name, want := range tt.contains {
+				for _, s := range want {
+					if !strings.Contains(updated[name], s) {
+						t.Errorf("%s does not contain %q:\n%s", name, s, updated[name])
+					}
+				}
+			}
+			for name, unwanted := range tt.excludes {
+				for _, s := range unwanted {
+					if strings.Contains(updated[name], s) {
+						t.Errorf("%s contains %q:\n%s", name, s, updated[name])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestConfigureExistingSitesIdempotent(t *testing.T) {
+	config := testNginxEditConfig("example.com", true)
+	_, first := configureExistingSitesPlan(t, map[string]string{
+		"a.conf": "server {\n    listen 80;\n    server_name example.com;\n}\n",
+	}, config)
+
+	modified, second := configureExistingSitesPlan(t, map[string]string{"a.conf": first["a.conf"]}, config)
+	if strings.Join(modified, ",") != "a.conf" {
+		t.Errorf("modified = %v, want [a.conf]", modified)
+	}
+	if len(second) != 0 {
+		t.Errorf("second run changed files:\n%s", second["a.conf"])
+	}
+}
@@ -0,0 +1,204 @@
+package webserver
+
+import (
+	"autocert/internal/logger"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// nginxLayout Nginx 的站点目录布局
+type nginxLayout struct {
+	siteDir    string // 站点配置写入目录
+	enabledDir string // 站点启用目录，只有 sites-available/sites-enabled 布局需要
+	pattern    string // 包含站点目录的 include 通配符（绝对路径）
+}
+
+// siteFile 站点配置文件路径，文件名需要能被 include 通配符匹配
+func (l *nginxLayout) siteFile(name string) string {
+	if l.enabledDir != "" {
+		return filepath.Join(l.siteDir, name)
+	}
+
+	pattern := filepath.Base(l.pattern)
+	for _, candidate := range []string{name + ".conf", name} {
+		if ok, _ := filepath.Match(pattern, candidate); ok {
+			return filepath.Join(l.siteDir, candidate)
+		}
+	}
+	return filepath.Join(l.siteDir, name+".conf")
+}
+
+var (
+	nginxPrefixPattern   = regexp.MustCompile(`--prefix=(\S+)`)
+	nginxConfPathPattern = regexp.MustCompile(`--conf-path=(\S+)`)
+)
+
+// nginxBuildPaths 从 nginx -V 的编译参数中获取安装前缀和主配置文件路径
+func nginxBuildPaths(binary string) (prefix, confPath string, err error) {
+	output, err := exec.Command(binary, "-V").CombinedOutput()
+	if err != nil {
+		return "", "", fmt.Errorf("执行 %s -V 失败: %w", binary, err)
+	}
+	prefix, confPath = parseNginxBuildPaths(string(output))
+	return prefix, confPath, nil
+}
+
+// parseNginxBuildPaths 解析 nginx -V 的输出，未指定时使用 Nginx 的编译默认值
+func parseNginxBuildPaths(output string) (prefix, confPath string) {
+	prefix = "/usr/local/nginx"
+	if m := nginxPrefixPattern.FindStringSubmatch(output); m != nil {
+		prefix = strings.Trim(m[1], `'"`)
+	}
+
+	confPath = "conf/nginx.conf"
+	if m := nginxConfPathPattern.FindStringSubmatch(output); m != nil {
+		confPath = strings.Trim(m[1], `'"`)
+	}
+	if !filepath.IsAbs(confPath) {
+		confPath = filepath.Join(prefix, confPath)
+	}
+	return prefix, confPath
+}
+
+// httpIncludes 获取 http 上下文中的 include 通配符（不包括 server 等块内的 include）
+func (c *nginxConfig) httpIncludes() []*nginxDirective {
+	var includes []*nginxDirective
+	visited := make(map[string]bool)
+
+	var walk func(ds []*nginxDirective, inHTTP bool)
+	walk = func(ds []*nginxDirective, inHTTP bool) {
+		for _, d := range ds {
+			switch {
+			case d.Name == "include":
+				if inHTTP && len(d.Args) == 1 {
+					includes = append(includes, d)
+				}
+				for _, inc := range c.includedFiles(d) {
+					if !visited[inc.Path] {
+						visited[inc.Path] = true
+						walk(inc.Directives, inHTTP)
+					}
+				}
+			case d.Name == "http" && d.IsBlock():
+				walk(d.Block, true)
+			}
+		}
+	}
+
+	if main, ok := c.Files[c.Main]; ok {
+		visited[main.Path] = true
+		walk(main.Directives, false)
+	}
+	return includes
+}
+
+// detectLayout 根据主配置中 http 块的 include 确定站点配置目录
+func (n *NginxConfigurator) detectLayout(parsed *nginxConfig) error {
+	var dirs []*nginxLayout
+	for _, d := range parsed.httpIncludes() {
+		pattern := d.Args[0]
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(parsed.Main), pattern)
+		}
+		// 只有通配符 include 才是站点目录，mime.types 等单个文件不算
+		if !strings.ContainsAny(filepath.Base(pattern), "*?[") || strings.ContainsAny(filepath.Dir(pattern), "*?[") {
+			continue
+		}
+		dirs = append(dirs, &nginxLayout{siteDir: filepath.Dir(pattern), pattern: pattern})
+	}
+
+	// Debian 风格：sites-enabled 中放指向 sites-available 的符号链接
+	for _, l := range dirs {
+		if filepath.Base(l.siteDir) == "sites-enabled" {
+			available := filepath.Join(filepath.Dir(l.siteDir), "sites-available")
+			if info, err := os.Stat(available); err == nil && info.IsDir() {
+				n.layout = &nginxLayout{siteDir: available, enabledDir: l.siteDir, pattern: l.pattern}
+				logger.Debug("检测到 Nginx 站点目录", "siteDir", available, "enabledDir", l.siteDir)
+				return nil
+			}
+		}
+	}
+
+	// 其他发行版：conf.d（RHEL）、http.d（Alpine）、servers（Homebrew）等
+	for _, name := range []string{"conf.d", "http.d", "vhosts.d", "servers", "sites-enabled"} {
+		for _, l := range dirs {
+			if filepath.Base(l.siteDir) == name {
+				n.layout = l
+				logger.Debug("检测到 Nginx 站点目录", "siteDir", l.siteDir)
+				return nil
+			}
+		}
+	}
+	if len(dirs) > 0 {
+		n.layout = dirs[len(dirs)-1]
+		logger.Debug("检测到 Nginx 站点目录", "siteDir", n.layout.siteDir)
+		return nil
+	}
+
+	// 主配置没有包含任何站点目录（Windows、OpenResty 的默认配置），在 http 块中添加 include
+	return n.addSiteInclude(parsed)
+}
+
+// addSiteInclude 在主配置的 http 块末尾添加 include conf.d/*.conf
+func (n *NginxConfigurator) addSiteInclude(parsed *nginxConfig) error {
+	main, ok := parsed.Files[parsed.Main]
+	if !ok {
+		return fmt.Errorf("未找到 Nginx 主配置文件")
+	}
+
+	var http *nginxDirective
+	for _, d := range main.Directives {
+		if d.Name == "http" && d.IsBlock() {
+			http = d
+			break
+		}
+	}
+	if http == nil {
+		return fmt.Errorf("Nginx 主配置 %s 中没有 http 块", parsed.Main)
+	}
+
+	siteDir := filepath.Join(filepath.Dir(parsed.Main), "conf.d")
+	if err := n.changes.mkdirAll(siteDir); err != nil {
+		return err
+	}
+
+	indent := blockIndent(main.Content, http)
+	include := fmt.Sprintf("\n\n%s# AutoCert 站点配置目录\n%sinclude conf.d/*.conf;", indent, indent)
+	pos := strings.LastIndex(main.Content[:http.Close], "\n")
+	if pos < http.Open {
+		pos = http.Close
+	}
+	content := applyEdits(main.Content, []nginxEdit{{start: pos, end: pos, text: include}})
+
+	backupPath, err := n.changes.updateFile(main.Path, []byte(content))
+	if err != nil {
+		return err
+	}
+
+	n.layout = &nginxLayout{siteDir: siteDir, pattern: filepath.Join(siteDir, "*.conf")}
+	logger.Info("在 Nginx 主配置中添加站点目录", "configFile", main.Path, "include", n.layout.pattern, "backup", backupPath)
+	return nil
+}
+
+// defaultNginxConfigPaths 无法执行 nginx -V 时尝试的主配置文件路径
+func defaultNginxConfigPaths() []string {
+	if runtime.GOOS == "windows" {
+		return []string{
+			`C:\nginx\conf\nginx.conf`,
+			`C:\Program Files\nginx\conf\nginx.conf`,
+		}
+	}
+	return []string{
+		"/etc/nginx/nginx.conf",
+		"/usr/local/nginx/conf/nginx.conf",
+		"/usr/local/etc/nginx/nginx.conf",
+		"/usr/local/openresty/nginx/conf/nginx.conf",
+		"/usr/local/tengine/conf/nginx.conf",
+		"/opt/tengine/conf/nginx.conf",
+	}
+}
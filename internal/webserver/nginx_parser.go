@@ -0,0 +1,578 @@
+package webserver
+
+import (
+	"autocert/internal/logger"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// nginxTokenType 词法单元类型
+type nginxTokenType int
+
+const (
+	nginxTokenWord nginxTokenType = iota
+	nginxTokenSemicolon
+	nginxTokenOpenBrace
+	nginxTokenCloseBrace
+)
+
+// nginxToken 词法单元
+type nginxToken struct {
+	Type      nginxTokenType
+	Value     string // 去掉引号后的值
+	Line      int
+	LineStart int // 所在行的起始位置
+	Start     int
+	End       int
+}
+
+// nginxDirective 配置语法树节点
+type nginxDirective struct {
+	Name      string
+	Args      []string
+	File      string
+	Line      int
+	LineStart int // 指令所在行的起始位置
+	Start     int // 指令名的起始位置
+	End       int // 结束分号（或块的 '}'）之后的位置
+	Open      int // 块的 '{' 位置，非块指令为 -1
+	Close     int // 块的 '}' 位置，非块指令为 -1
+	Block     []*nginxDirective
+}
+
+// IsBlock 是否为块指令
+func (d *nginxDirective) IsBlock() bool {
+	return d.Open >= 0
+}
+
+// nginxConfigFile 一个已解析的配置文件
+type nginxConfigFile struct {
+	Path       string
+	Content    string
+	Directives []*nginxDirective
+}
+
+// nginxConfig 从主配置文件开始、跟随 include 解析得到的完整配置
+type nginxConfig struct {
+	Main  string
+	Files map[string]*nginxConfigFile
+	Order []string // 文件的解析顺序
+
+	read func(path string) (string, error)
+	glob func(pattern string) []string
+}
+
+// nginxServer http 上下文中的 server 块
+type nginxServer struct {
+	File      *nginxConfigFile
+	Directive *nginxDirective
+	HTTP      *nginxDirective // 所属的 http 块，用于查找继承的指令
+}
+
+// tokenizeNginx 将配置内容拆分为词法单元，跳过注释
+func tokenizeNginx(content string) ([]nginxToken, error) {
+	var tokens []nginxToken
+	line := 1
+	lineStart := 0
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '\n':
+			line++
+			lineStart = i + 1
+		case c == ' ' || c == '\t' || c == '\r':
+		case c == '#':
+			for i+1 < len(content) && content[i+1] != '\n' {
+				i++
+			}
+		case c == ';':
+			tokens = append(tokens, nginxToken{Type: nginxTokenSemicolon, Line: line, LineStart: lineStart, Start: i, End: i + 1})
+		case c == '{':
+			tokens = append(tokens, nginxToken{Type: nginxTokenOpenBrace, Line: line, LineStart: lineStart, Start: i, End: i + 1})
+		case c == '}':
+			tokens = append(tokens, nginxToken{Type: nginxTokenCloseBrace, Line: line, LineStart: lineStart, Start: i, End: i + 1})
+		case c == '"' || c == '\'':
+			start, startLine, startLineStart := i, line, lineStart
+			var value strings.Builder
+			for i++; i < len(content) && content[i] != c; i++ {
+				if content[i] == '\\' && i+1 < len(content) {
+					i++
+				}
+				if content[i] == '\n' {
+					line++
+					lineStart = i + 1
+				}
+				value.WriteByte(content[i])
+			}
+			if i >= len(content) {
+				return nil, fmt.Errorf("第 %d 行: 引号未闭合", startLine)
+			}
+			tokens = append(tokens, nginxToken{Type: nginxTokenWord, Value: value.String(), Line: startLine, LineStart: startLineStart, Start: start, End: i + 1})
+		default:
+			start := i
+			for i < len(content) && !strings.ContainsRune(" \t\r\n;{}#\"'", rune(content[i])) {
+				// ${var} 中的大括号属于变量名
+				if content[i] == '$' && i+1 < len(content) && content[i+1] == '{' {
+					if j := strings.IndexByte(content[i:], '}'); j > 0 {
+						i += j
+					}
+				}
+				i++
+			}
+			tokens = append(tokens, nginxToken{Type: nginxTokenWord, Value: content[start:i], Line: line, LineStart: lineStart, Start: start, End: i})
+			i--
+		}
+	}
+
+	return tokens, nil
+}
+
+// parseNginx 将配置内容解析为语法树
+func parseNginx(file, content string) ([]*nginxDirective, error) {
+	tokens, err := tokenizeNginx(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	pos := 0
+	directives, err := parseNginxBlock(file, tokens, &pos, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return directives, nil
+}
+
+// parseNginxBlock 解析一个块中的指令，遇到 '}' 时返回
+func parseNginxBlock(file string, tokens []nginxToken, pos *int, nested bool) ([]*nginxDirective, error) {
+	var directives []*nginxDirective
+	var current *nginxDirective
+
+	for *pos < len(tokens) {
+		tok := tokens[*pos]
+		*pos++
+
+		switch tok.Type {
+		case nginxTokenWord:
+			if current == nil {
+				current = &nginxDirective{
+					Name:      tok.Value,
+					File:      file,
+					Line:      tok.Line,
+					LineStart: tok.LineStart,
+					Start:     tok.Start,
+					Open:      -1,
+					Close:     -1,
+				}
+			} else {
+				current.Args = append(current.Args, tok.Value)
+			}
+		case nginxTokenSemicolon:
+			if current == nil {
+				// 空语句
+				continue
+			}
+			current.End = tok.End
+			directives = append(directives, current)
+			current = nil
+		case nginxTokenOpenBrace:
+			if current == nil {
+				return nil, fmt.Errorf("第 %d 行: 意外的 '{'", tok.Line)
+			}
+			current.Open = tok.Start
+			block, err := parseNginxBlock(file, tokens, pos, true)
+			if err != nil {
+				return nil, err
+			}
+			current.Block = block
+			closing := tokens[*pos-1]
+			current.Close = closing.Start
+			current.End = closing.End
+			directives = append(directives, current)
+			current = nil
+		case nginxTokenCloseBrace:
+			if !nested {
+				return nil, fmt.Errorf("第 %d 行: 意外的 '}'", tok.Line)
+			}
+			if current != nil {
+				return nil, fmt.Errorf("第 %d 行: 指令 %s 缺少 ';'", current.Line, current.Name)
+			}
+			return directives, nil
+		}
+	}
+
+	if nested {
+		return nil, fmt.Errorf("缺少 '}'")
+	}
+	if current != nil {
+		return nil, fmt.Errorf("第 %d 行: 指令 %s 缺少 ';'", current.Line, current.Name)
+	}
+	return directives, nil
+}
+
+// loadNginxConfig 从主配置文件开始解析，跟随 include 指令
+func loadNginxConfig(mainConfig string) (*nginxConfig, error) {
+	cfg := &nginxConfig{
+		Main:  mainConfig,
+		Files: make(map[string]*nginxConfigFile),
+		read: func(path string) (string, error) {
+			data, err := os.ReadFile(path)
+			return string(data), err
+		},
+		glob: globFiles,
+	}
+
+	if err := cfg.load(mainConfig); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadNginxDump 解析 nginx -T 的输出
+func loadNginxDump(output string) (*nginxConfig, error) {
+	contents := splitNginxDump(output)
+	if len(contents) == 0 {
+		return nil, fmt.Errorf("nginx -T 输出中没有配置文件")
+	}
+
+	// nginx -T 输出的第一个文件就是主配置文件
+	mainConfig := nginxDumpFilePattern.FindStringSubmatch(output)[1]
+
+	cfg := &nginxConfig{
+		Main:  mainConfig,
+		Files: make(map[string]*nginxConfigFile),
+		read: func(path string) (string, error) {
+			content, ok := contents[path]
+			if !ok {
+				return "", os.ErrNotExist
+			}
+			return content, nil
+		},
+		glob: func(pattern string) []string {
+			var result []string
+			for path := range contents {
+				if ok, _ := filepath.Match(pattern, path); ok {
+					result = append(result, path)
+				}
+			}
+			// 与 nginx 一样按字母顺序加载
+			sort.Strings(result)
+			return result
+		},
+	}
+
+	if err := cfg.load(mainConfig); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadNginxFromBinary 通过 nginx -T 获取并解析完整配置
+func loadNginxFromBinary(binary string) (*nginxConfig, error) {
+	output, err := exec.Command(binary, "-T").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("执行 %s -T 失败: %s", binary, string(output))
+	}
+	return loadNginxDump(string(output))
+}
+
+// nginxDumpFilePattern nginx -T 输出中的文件分隔行
+var nginxDumpFilePattern = regexp.MustCompile(`(?m)^# configuration file (.+):$`)
+
+// splitNginxDump 按文件拆分 nginx -T 的输出
+func splitNginxDump(output string) map[string]string {
+	result := make(map[string]string)
+	matches := nginxDumpFilePattern.FindAllStringSubmatchIndex(output, -1)
+	for i, m := range matches {
+		path := output[m[2]:m[3]]
+		start := m[1] + 1
+		end := len(output)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		if start > end {
+			start = end
+		}
+		result[path] = output[start:end]
+	}
+	return result
+}
+
+// load 解析文件并递归处理其中的 include
+func (c *nginxConfig) load(path string) error {
+	if _, ok := c.Files[path]; ok {
+		return nil
+	}
+
+	content, err := c.read(path)
+	if err != nil {
+		return err
+	}
+
+	directives, err := parseNginx(path, content)
+	if err != nil {
+		return err
+	}
+
+	file := &nginxConfigFile{Path: path, Content: content, Directives: directives}
+	c.Files[path] = file
+	c.Order = append(c.Order, path)
+
+	var loadIncludes func(ds []*nginxDirective) error
+	loadIncludes = func(ds []*nginxDirective) error {
+		for _, d := range ds {
+			if d.Name == "include" && len(d.Args) == 1 {
+				for _, inc := range c.resolveInclude(d.Args[0]) {
+					if err := c.load(inc); err != nil {
+						logger.Warn("解析 Nginx include 文件失败", "file", inc, "error", err)
+					}
+				}
+			}
+			if err := loadIncludes(d.Block); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return loadIncludes(directives)
+}
+
+// resolveInclude 解析 include 路径，相对路径以主配置文件所在目录为基准
+func (c *nginxConfig) resolveInclude(pattern string) []string {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(c.Main), pattern)
+	}
+
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{pattern}
+	}
+	return c.glob(pattern)
+}
+
+// globFiles 匹配磁盘上的文件（不包括目录）
+func globFiles(pattern string) []string {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil
+	}
+
+	var result []string
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && !info.IsDir() {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// includedFiles 获取 include 指令实际包含的文件（按解析结果）
+func (c *nginxConfig) includedFiles(d *nginxDirective) []*nginxConfigFile {
+	if d.Name != "include" || len(d.Args) != 1 {
+		return nil
+	}
+
+	pattern := d.Args[0]
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(c.Main), pattern)
+	}
+
+	var result []*nginxConfigFile
+	for _, path := range c.Order {
+		if ok, _ := filepath.Match(pattern, path); ok || path == pattern {
+			result = append(result, c.Files[path])
+		}
+	}
+	return result
+}
+
+// Servers 获取所有 http 上下文中的 server 块（stream/mail 中的 server 除外）
+func (c *nginxConfig) Servers() []nginxServer {
+	var servers []nginxServer
+	visited := make(map[string]bool)
+
+	var walk func(file *nginxConfigFile, ds []*nginxDirective, http *nginxDirective)
+	walk = func(file *nginxConfigFile, ds []*nginxDirective, http *nginxDirective) {
+		for _, d := range ds {
+			switch {
+			case d.Name == "include":
+				for _, inc := range c.includedFiles(d) {
+					if visited[inc.Path] {
+						continue
+					}
+					visited[inc.Path] = true
+					walk(inc, inc.Directives, http)
+				}
+			case d.Name == "http" && d.IsBlock():
+				walk(file, d.Block, d)
+			case d.Name == "server" && d.IsBlock() && http != nil:
+				servers = append(servers, nginxServer{File: file, Directive: d, HTTP: http})
+			}
+		}
+	}
+
+	if main, ok := c.Files[c.Main]; ok {
+		visited[main.Path] = true
+		walk(main, main.Directives, nil)
+	}
+	return servers
+}
+
+// ServersFor 获取 server_name 匹配指定域名的 server 块
+func (c *nginxConfig) ServersFor(domain string) []nginxServer {
+	var result []nginxServer
+	for _, s := range c.Servers() {
+		if s.Matches(domain) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// find 查找 server 块中的顶层指令
+func (s *nginxServer) find(name string) []*nginxDirective {
+	var result []*nginxDirective
+	for _, d := range s.Directive.Block {
+		if d.Name == name {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// serverNames 获取 server_name
+func (s *nginxServer) serverNames() []string {
+	var names []string
+	for _, d := range s.find("server_name") {
+		names = append(names, d.Args...)
+	}
+	return names
+}
+
+// hasSSL 是否监听 HTTPS
+func (s *nginxServer) hasSSL() bool {
+	for _, d := range s.find("listen") {
+		if listenIsSSL(d.Args) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCertificate 是否配置了证书（包括从 http 块继承）
+func (s *nginxServer) hasCertificate() bool {
+	if len(s.find("ssl_certificate")) > 0 {
+		return true
+	}
+	if s.HTTP != nil {
+		for _, d := range s.HTTP.Block {
+			if d.Name == "ssl_certificate" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Matches server_name 是否匹配指定域名
+func (s *nginxServer) Matches(domain string) bool {
+	for _, name := range s.serverNames() {
+		if nginxServerNameMatches(name, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesAny server_name 是否匹配任一域名
+func (s *nginxServer) MatchesAny(domains []string) bool {
+	for _, domain := range domains {
+		if s.Matches(domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// coveredBy server_name 中的所有名称是否都在证书的域名范围内
+// 与 Matches 不同，通配符、正则表达式和默认 server 的名称不能确定范围，视为未覆盖
+func (s *nginxServer) coveredBy(domains []string) bool {
+	names := s.serverNames()
+	if len(names) == 0 {
+		return false
+	}
+	for _, name := range names {
+		if !certCoversName(domains, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// certCoversName 证书域名是否覆盖 server_name 中的一个名称
+// .example.com 需要证书同时包含 example.com 和 *.example.com
+func certCoversName(domains []string, name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if strings.HasPrefix(name, ".") {
+		return certCoversName(domains, name[1:]) && certCoversName(domains, "*"+name)
+	}
+	if name == "" || name == "_" || strings.HasPrefix(name, "~") || strings.ContainsAny(strings.TrimPrefix(name, "*."), "*?$") {
+		return false
+	}
+
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		if domain == name {
+			return true
+		}
+		// *.example.com 覆盖 www.example.com，不覆盖 example.com 和 a.b.example.com
+		if strings.HasPrefix(domain, "*.") && !strings.HasPrefix(name, "*.") {
+			label, ok := strings.CutSuffix(name, domain[1:])
+			if ok && label != "" && !strings.Contains(label, ".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nginxServerNameMatches 按 Nginx 的规则匹配 server_name
+// 支持精确名称、*.example.com、www.example.*、.example.com 和 ~ 开头的正则表达式
+func nginxServerNameMatches(pattern, domain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if pattern == "" || pattern == "_" || domain == "" {
+		return false
+	}
+
+	if strings.HasPrefix(pattern, "~") {
+		re, err := regexp.Compile("(?i)" + strings.TrimPrefix(pattern, "~"))
+		if err != nil {
+			logger.Debug("无法解析 server_name 正则表达式", "pattern", pattern, "error", err)
+			return false
+		}
+		return re.MatchString(domain)
+	}
+
+	pattern = strings.ToLower(pattern)
+	if pattern == domain {
+		// 也覆盖了证书本身就是泛域名（*.example.com）的情况
+		return true
+	}
+
+	switch {
+	case strings.HasPrefix(pattern, "*."):
+		return strings.HasSuffix(domain, pattern[1:]) && len(domain) > len(pattern)-1
+	case strings.HasPrefix(pattern, "."):
+		return domain == pattern[1:] || strings.HasSuffix(domain, pattern)
+	case strings.HasSuffix(pattern, ".*"):
+		prefix := pattern[:len(pattern)-1]
+		return strings.HasPrefix(domain, prefix) && len(domain) > len(prefix)
+	}
+
+	return false
+}
@@ -0,0 +1,236 @@
+package webserver
+
+import (
+	"strings"
+	"testing"
+)
+
+// tokenStrings 将词法单元转换为便于比较的字符串，标点用原字符表示
+func tokenStrings(tokens []nginxToken) []string {
+	result := make([]string, len(tokens))
+	for i, tok := range tokens {
+		switch tok.Type {
+		case nginxTokenSemicolon:
+			result[i] = ";"
+		case nginxTokenOpenBrace:
+			result[i] = "{"
+		case nginxTokenCloseBrace:
+			result[i] = "}"
+		default:
+			result[i] = tok.Value
+		}
+	}
+	return result
+}
+
+func TestTokenizeNginx(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "plain directives and blocks",
+			content: "server {\n    listen 80;\n}\n",
+			want:    []string{"server", "{", "listen", "80", ";", "}"},
+		},
+		{
+			name:    "comments",
+			content: "# 注释 { ;\nlisten 80; # 行尾注释 }\n",
+			want:    []string{"listen", "80", ";"},
+		},
+		{
+			name:    "double quoted with separators",
+			content: `add_header X-Test "a;b {c} d";`,
+			want:    []string{"add_header", "X-Test", "a;b {c} d", ";"},
+		},
+		{
+			name:    "hash inside strings",
+			content: `return 200 "#not-a-comment"; set $x '#also';`,
+			want:    []string{"return", "200", "#not-a-comment", ";", "set", "$x", "#also", ";"},
+		},
+		{
+			name:    "escaped quotes",
+			content: `log_format main "a \"b\" c"; return 200 'it\'s';`,
+			want:    []string{"log_format", "main", `a "b" c`, ";", "return", "200", "it's", ";"},
+		},
+		{
+			name:    "quoted string spanning lines",
+			content: "return 200 \"a\nb\";",
+			want:    []string{"return", "200", "a\nb", ";"},
+		},
+		{
+			name:    "variable braces",
+			content: `rewrite ^/(.*)$ /${1}x last;`,
+			want:    []string{"rewrite", "^/(.*)$", "/${1}x", "last", ";"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := tokenizeNginx(tt.content)
+			if err != nil {
+				t.Fatalf("tokenizeNginx: %v", err)
+			}
+			if got := tokenStrings(tokens); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("tokens = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeNginxPositions(t *testing.T) {
+	content := "http {\n    add_header X \"a\nb\";\n    listen 80;\n}\n"
+	tokens, err := tokenizeNginx(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tok := range tokens {
+		if tok.Type == nginxTokenWord && tok.Value == "listen" {
+			if tok.Line != 4 || content[tok.LineStart:tok.Start] != "    " {
+				t.Errorf("listen at line %d, prefix %q", tok.Line, content[tok.LineStart:tok.Start])
+			}
+			return
+		}
+	}
+	t.Fatal("listen not found")
+}
+
+func TestParseNginxErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unclosed quote", `return 200 "abc;`, "引号未闭合"},
+		{"missing close brace", "server {\n listen 80;\n", "缺少 '}'"},
+		{"unexpected close brace", "listen 80;\n}\n", "意外的 '}'"},
+		{"missing semicolon", "server {\n listen 80\n}\n", "缺少 ';'"},
+		{"unexpected open brace", "{\n}\n", "意外的 '{'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseNginx("test.conf", tt.content)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNginxBlockOffsets(t *testing.T) {
+	content := "server {\n    listen 80;\n    location / { return 200 \"}\"; }\n}\n"
+	directives, err := parseNginx("test.conf", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := directives[0]
+	if content[server.Open] != '{' || content[server.Close] != '}' || server.End != len(content)-1 {
+		t.Errorf("server block offsets: open %d close %d end %d", server.Open, server.Close, server.End)
+	}
+	if len(server.Block) != 2 || server.Block[1].Name != "location" || server.Block[1].Block[0].Args[1] != "}" {
+		t.Errorf("unexpected server block: %+v", server.Block)
+	}
+	listen := server.Block[0]
+	if got := content[listen.Start:listen.End]; got != "listen 80;" {
+		t.Errorf("listen directive text = %q", got)
+	}
+}
+
+func TestNginxServerNameMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		domain  string
+		want    bool
+	}{
+		{"example.com", "example.com", true},
+		{"Example.COM", "example.com", true},
+		{"example.com", "example.com.", true},
+		{"example.com", "www.example.com", false},
+		{"*.example.com", "www.example.com", true},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "*.example.com", true},
+		{".example.com", "example.com", true},
+		{".example.com", "www.example.com", true},
+		{".example.com", "badexample.com", false},
+		{"www.example.*", "www.example.org", true},
+		{"www.example.*", "www.example.", false},
+		{"~^www\\d+\\.example\\.com$", "www12.example.com", true},
+		{"~^www\\d+\\.example\\.com$", "www.example.com", false},
+		{"~[", "example.com", false},
+		{"_", "example.com", false},
+		{"", "example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := nginxServerNameMatches(tt.pattern, tt.domain); got != tt.want {
+			t.Errorf("nginxServerNameMatches(%q, %q) = %v, want %v", tt.pattern, tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestCertCoversName(t *testing.T) {
+	domains := []string{"example.com", "www.example.com", "*.example.org"}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"example.com", true},
+		{"WWW.Example.com.", true},
+		{"api.example.com", false},
+		{"www.example.org", true},
+		{"example.org", false},
+		{"a.b.example.org", false},
+		{"*.example.org", true},
+		{"*.example.com", false},
+		{".example.com", false},
+		{".example.org", false},
+		{"www.example.*", false},
+		{"~^www\\.example\\.com$", false},
+		{"_", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := certCoversName(domains, tt.name); got != tt.want {
+			t.Errorf("certCoversName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// .example.com 同时需要 example.com 和 *.example.com
+	if !certCoversName([]string{"example.com", "*.example.com"}, ".example.com") {
+		t.Error(".example.com should be covered by example.com and *.example.com")
+	}
+}
+
+func TestNginxServerCoveredBy(t *testing.T) {
+	domains := []string{"example.com", "www.example.com"}
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{"all names covered", "server_name example.com www.example.com;", true},
+		{"names split across directives", "server_name example.com;\n server_name www.example.com;", true},
+		{"extra name", "server_name example.com api.example.com;", false},
+		{"wildcard name", "server_name example.com *.example.com;", false},
+		{"regex name", `server_name ~^www\.example\.com$;`, false},
+		{"default server", "server_name _;", false},
+		{"no server_name", "listen 80;", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directives, err := parseNginx("test.conf", "server {\n "+tt.content+"\n}\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := nginxServer{Directive: directives[0]}
+			if got := server.coveredBy(domains); got != tt.want {
+				t.Errorf("coveredBy = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
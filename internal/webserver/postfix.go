@@ -0,0 +1,203 @@
+package webserver
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PostfixConfigurator Postfix 配置器
+// 通过 postconf -e 修改 main.cf 中的 TLS 证书参数
+type PostfixConfigurator struct {
+	configDir  string
+	changes    *changeSet
+	reloadOpts config.ReloadConfig
+}
+
+// Configure 配置 Postfix
+func (p *PostfixConfigurator) Configure(config *Config) error {
+	logger.Info("开始配置 Postfix", "domain", config.Domain)
+	p.changes = newChangeSet(false)
+	p.reloadOpts = config.Reload
+	return p.configure(config)
+}
+
+// Plan 计算 Configure 将执行的修改
+func (p *PostfixConfigurator) Plan(config *Config) (*Plan, error) {
+	p.changes = newChangeSet(true)
+	p.reloadOpts = config.Reload
+	if err := p.configure(config); err != nil {
+		return nil, err
+	}
+
+	r, err := p.reloadStrategy()
+	if err != nil {
+		return nil, err
+	}
+	plan := p.changes.plan
+	plan.Commands = append(plan.Commands, r.planCommands()...)
+	return plan, nil
+}
+
+// configure 修改与当前值不同的参数
+func (p *PostfixConfigurator) configure(config *Config) error {
+	if _, err := p.reloadStrategy(); err != nil {
+		return fmt.Errorf("重载配置无效: %w", err)
+	}
+
+	configDir, err := postconf("config_directory")
+	if err != nil {
+		return fmt.Errorf("查找 Postfix 配置目录失败: %w", err)
+	}
+	p.configDir = configDir
+
+	version, _ := postconf("mail_version")
+	settings, err := postfixSettings(config, version)
+	if err != nil {
+		return err
+	}
+
+	var args []string
+	for _, s := range settings {
+		current, err := postconf(s[0])
+		if err != nil {
+			return fmt.Errorf("读取 Postfix 参数 %s 失败: %w", s[0], err)
+		}
+		// 只在未启用 TLS 时修改安全级别，不降低已有的 encrypt 等设置
+		if s[0] == "smtpd_tls_security_level" && current != "" && current != "none" {
+			continue
+		}
+		if current != s[1] {
+			args = append(args, s[0]+"="+s[1])
+		}
+	}
+
+	if len(args) == 0 {
+		logger.Info("Postfix 已使用该证书，无需修改", "domain", config.Domain)
+		return nil
+	}
+
+	// postconf -e 直接修改 main.cf，先记录以便回滚
+	if err := p.changes.track(filepath.Join(p.configDir, "main.cf")); err != nil {
+		return err
+	}
+	if output, err := p.changes.run("postconf", append([]string{"-e"}, args...)...); err != nil {
+		return fmt.Errorf("修改 Postfix 配置失败: %s", strings.TrimSpace(string(output)))
+	}
+
+	logger.Info("Postfix 配置完成", "domain", config.Domain, "settings", args)
+	return nil
+}
+
+// postfixSettings 证书相关的参数
+// Postfix 3.4 及以上使用 smtpd_tls_chain_files（私钥在前），更早的版本使用 cert_file/key_file
+func postfixSettings(config *Config, version string) ([][2]string, error) {
+	if config.FullchainPath == "" || config.KeyPath == "" {
+		return nil, fmt.Errorf("缺少证书或私钥路径")
+	}
+
+	var settings [][2]string
+	if postfixVersionAtLeast(version, 3, 4) {
+		settings = append(settings, [2]string{"smtpd_tls_chain_files", config.KeyPath + ", " + config.FullchainPath})
+	} else {
+		settings = append(settings,
+			[2]string{"smtpd_tls_cert_file", config.FullchainPath},
+			[2]string{"smtpd_tls_key_file", config.KeyPath},
+		)
+	}
+	return append(settings, [2]string{"smtpd_tls_security_level", "may"}), nil
+}
+
+// postfixVersionAtLeast 比较 mail_version（例如 3.7.6）
+func postfixVersionAtLeast(version string, major, minor int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	maj, err1 := strconv.Atoi(parts[0])
+	min, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return maj > major || (maj == major && min >= minor)
+}
+
+// postconf 读取参数的当前值
+func postconf(name string) (string, error) {
+	output, err := exec.Command("postconf", "-h", name).Output()
+	if err != nil {
+		return "", fmt.Errorf("执行 postconf 失败: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Test 测试 Postfix 配置
+func (p *PostfixConfigurator) Test() error {
+	r, err := p.reloadStrategy()
+	if err != nil {
+		return err
+	}
+	if err := r.runTest(); err != nil {
+		return fmt.Errorf("Postfix 配置测试失败: %w", err)
+	}
+	logger.Info("Postfix 配置测试成功")
+	return nil
+}
+
+// Reload 重载 Postfix 配置
+func (p *PostfixConfigurator) Reload() error {
+	r, err := p.reloadStrategy()
+	if err != nil {
+		return err
+	}
+	if err := r.runReload(); err != nil {
+		return fmt.Errorf("重载 Postfix 失败: %w", err)
+	}
+	logger.Info("Postfix 配置重载成功")
+	return nil
+}
+
+// reloadStrategy 根据配置的重载方式创建重载器
+func (p *PostfixConfigurator) reloadStrategy() (*reloader, error) {
+	return newReloader(p.reloadOpts, "postfix", []string{"postfix", "check"}, []string{"postfix", "reload"})
+}
+
+// Rollback 撤销最近一次 Configure 对 main.cf 的修改
+func (p *PostfixConfigurator) Rollback() ([]string, error) {
+	if p.changes == nil {
+		return nil, nil
+	}
+	return p.changes.rollback()
+}
+
+// GetConfigPath 获取配置路径
+func (p *PostfixConfigurator) GetConfigPath() string {
+	if p.configDir == "" {
+		p.configDir, _ = postconf("config_directory")
+	}
+	return filepath.Join(p.configDir, "main.cf")
+}
+
+// IsSSLEnabled 检查 Postfix 使用的证书是否包含该域名
+func (p *PostfixConfigurator) IsSSLEnabled(domain string) bool {
+	var files []string
+	if chain, err := postconf("smtpd_tls_chain_files"); err == nil && chain != "" {
+		for _, f := range strings.FieldsFunc(chain, func(r rune) bool { return r == ',' || r == ' ' }) {
+			files = append(files, f)
+		}
+	}
+	if certFile, err := postconf("smtpd_tls_cert_file"); err == nil && certFile != "" {
+		files = append(files, certFile)
+	}
+
+	for _, f := range files {
+		if pemCoversDomain(f, domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,288 @@
+package webserver
+
+import (
+	"autocert/internal/config"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 重载方式
+const (
+	ReloadCommand = "command" // 执行自定义命令
+	ReloadSystemd = "systemd" // systemctl reload <unit>
+	ReloadSignal  = "signal"  // 向 PID 文件中的主进程发送信号
+	ReloadDocker  = "docker"  // docker exec 或 docker kill -s <signal>
+)
+
+// 默认值
+const (
+	DefaultReloadTimeout = 30 * time.Second
+	DefaultReloadSignal  = "HUP"
+)
+
+// ReloadMethods 支持的重载方式
+func ReloadMethods() []string {
+	return []string{ReloadCommand, ReloadSystemd, ReloadSignal, ReloadDocker}
+}
+
+// reloader 按配置的方式测试和重载 Web 服务器
+type reloader struct {
+	opts   config.ReloadConfig
+	unit   string   // 默认 systemd 服务名
+	test   []string // 默认配置测试命令
+	reload []string // 未配置重载方式时使用的命令
+
+	reloadFunc func() error // 未配置重载方式时代替 reload 命令，例如 HAProxy master socket
+	reloadDesc string       // reloadFunc 在计划中显示的内容
+}
+
+// newReloader 创建重载器，opts 为空时使用配置器的默认命令
+func newReloader(opts config.ReloadConfig, unit string, test, reload []string) (*reloader, error) {
+	opts.Method = strings.ToLower(opts.Method)
+
+	switch opts.Method {
+	case "":
+	case ReloadCommand:
+		if opts.Command == "" {
+			return nil, fmt.Errorf("重载方式 command 需要设置 command")
+		}
+	case ReloadSystemd:
+	case ReloadSignal:
+		if opts.PIDFile == "" {
+			return nil, fmt.Errorf("重载方式 signal 需要设置 pid_file")
+		}
+		if _, err := lookupSignal(signalName(opts.Signal)); err != nil {
+			return nil, err
+		}
+	case ReloadDocker:
+		if opts.Container == "" {
+			return nil, fmt.Errorf("重载方式 docker 需要设置 container")
+		}
+	default:
+		return nil, fmt.Errorf("不支持的重载方式: %s（可选: %s）", opts.Method, strings.Join(ReloadMethods(), ", "))
+	}
+
+	if opts.Unit != "" {
+		unit = opts.Unit
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultReloadTimeout
+	}
+
+	return &reloader{opts: opts, unit: unit, test: test, reload: reload}, nil
+}
+
+// testCommand 配置测试命令，Web 服务器运行在容器中时在容器内测试
+func (r *reloader) testCommand() []string {
+	if len(r.test) == 0 {
+		return nil
+	}
+	if r.opts.Method == ReloadDocker {
+		return append([]string{"docker", "exec", r.opts.Container}, r.test...)
+	}
+	return r.test
+}
+
+// reloadCommand 重载命令，signal 方式不执行外部命令，返回 nil
+func (r *reloader) reloadCommand() []string {
+	switch r.opts.Method {
+	case ReloadCommand:
+		return shellCommand(r.opts.Command)
+	case ReloadSystemd:
+		return []string{"systemctl", "reload", r.unit}
+	case ReloadSignal:
+		return nil
+	case ReloadDocker:
+		if r.opts.Command != "" {
+			return []string{"docker", "exec", r.opts.Container, "sh", "-c", r.opts.Command}
+		}
+		return []string{"docker", "kill", "-s", signalName(r.opts.Signal), r.opts.Container}
+	default:
+		return r.reload
+	}
+}
+
+// planCommands 计划中显示的测试、重载和健康检查步骤
+func (r *reloader) planCommands() []string {
+	var commands []string
+	if test := r.testCommand(); len(test) > 0 {
+		commands = append(commands, strings.Join(test, " "))
+	}
+
+	switch r.opts.Method {
+	case ReloadCommand:
+		commands = append(commands, r.opts.Command)
+	case ReloadSignal:
+		commands = append(commands, fmt.Sprintf("kill -%s $(cat %s)", signalName(r.opts.Signal), r.opts.PIDFile))
+	case "":
+		if r.reloadFunc != nil {
+			commands = append(commands, r.reloadDesc)
+		} else {
+			commands = append(commands, strings.Join(r.reload, " "))
+		}
+	default:
+		commands = append(commands, strings.Join(r.reloadCommand(), " "))
+	}
+
+	if r.opts.HealthCheck != "" {
+		commands = append(commands, fmt.Sprintf("# 健康检查: %s（超时 %s）", r.opts.HealthCheck, r.opts.Timeout))
+	}
+	return commands
+}
+
+// runTest 执行配置测试
+func (r *reloader) runTest() error {
+	test := r.testCommand()
+	if len(test) == 0 {
+		return nil
+	}
+	return r.run(test)
+}
+
+// runReload 重载 Web 服务器，配置了健康检查时等待服务恢复
+func (r *reloader) runReload() error {
+	var err error
+	switch {
+	case r.opts.Method == ReloadSignal:
+		err = r.signal()
+	case r.opts.Method == "" && r.reloadFunc != nil:
+		err = r.reloadFunc()
+	default:
+		err = r.run(r.reloadCommand())
+	}
+	if err != nil {
+		return err
+	}
+
+	if r.opts.HealthCheck == "" {
+		return nil
+	}
+	return r.healthCheck()
+}
+
+// run 在超时时间内执行命令
+func (r *reloader) run(args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.opts.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	// 超时后 shell 的子进程可能仍持有输出管道，限制等待时间
+	cmd.WaitDelay = 5 * time.Second
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	out := strings.TrimSpace(output.String())
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("执行 %s 超时（%s）", commandString(args[0], args[1:]...), r.opts.Timeout)
+	}
+	if err != nil {
+		if out == "" {
+			return err
+		}
+		return errors.New(out)
+	}
+	return nil
+}
+
+// signal 向 PID 文件中的进程发送信号
+func (r *reloader) signal() error {
+	data, err := os.ReadFile(r.opts.PIDFile)
+	if err != nil {
+		return fmt.Errorf("读取 PID 文件失败: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return fmt.Errorf("PID 文件 %s 内容无效", r.opts.PIDFile)
+	}
+
+	sig, err := lookupSignal(signalName(r.opts.Signal))
+	if err != nil {
+		return err
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("查找进程 %d 失败: %w", pid, err)
+	}
+	if err := process.Signal(sig); err != nil {
+		return fmt.Errorf("向进程 %d 发送 %s 信号失败: %w", pid, signalName(r.opts.Signal), err)
+	}
+	return nil
+}
+
+// healthCheck 在超时时间内反复检查，直到服务可以访问
+func (r *reloader) healthCheck() error {
+	deadline := time.Now().Add(r.opts.Timeout)
+	for {
+		err := probe(r.opts.HealthCheck)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("健康检查 %s 失败: %w", r.opts.HealthCheck, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// probe 检查一次服务状态：URL 要求返回非 5xx 状态码，host:port 要求可以建立 TCP 连接
+func probe(target string) error {
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		conn, err := net.DialTimeout("tcp", target, 5*time.Second)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	// 只检查服务是否恢复，证书本身不在这里校验（例如测试环境签发的证书）
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(target)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// shellCommand 通过系统 shell 执行命令
+func shellCommand(command string) []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C", command}
+	}
+	return []string{"sh", "-c", command}
+}
+
+// signalName 规范化信号名称，例如 SIGHUP、hup 均为 HUP
+func signalName(name string) string {
+	name = strings.TrimPrefix(strings.ToUpper(name), "SIG")
+	if name == "" {
+		return DefaultReloadSignal
+	}
+	return name
+}
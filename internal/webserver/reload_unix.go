@@ -0,0 +1,28 @@
+//go:build !windows
+
+package webserver
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// reloadSignals Web 服务器常用的重载信号
+var reloadSignals = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"QUIT":  syscall.SIGQUIT,
+	"TERM":  syscall.SIGTERM,
+	"WINCH": syscall.SIGWINCH,
+}
+
+// lookupSignal 根据名称查找信号
+func lookupSignal(name string) (os.Signal, error) {
+	sig, ok := reloadSignals[name]
+	if !ok {
+		return nil, fmt.Errorf("不支持的信号: %s", name)
+	}
+	return sig, nil
+}
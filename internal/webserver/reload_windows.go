@@ -0,0 +1,11 @@
+package webserver
+
+import (
+	"fmt"
+	"os"
+)
+
+// lookupSignal Windows 不支持向进程发送重载信号
+func lookupSignal(name string) (os.Signal, error) {
+	return nil, fmt.Errorf("Windows 不支持通过信号重载 Web 服务器，请使用 command 方式")
+}
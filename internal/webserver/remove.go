@@ -0,0 +1,434 @@
+package webserver
+
+import (
+	"autocert/internal/logger"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// SiteRemover 支持删除证书配置的配置器，证书被删除之前调用
+type SiteRemover interface {
+	Configurator
+	// RemoveSite 删除为证书生成的站点配置和启用链接，返回是否修改了文件
+	// 有修改时需要 Test 和 Reload，失败时 Rollback
+	RemoveSite(config *Config) (bool, error)
+	// PlanRemoveSite 计算 RemoveSite 将执行的修改
+	PlanRemoveSite(config *Config) (*Plan, error)
+}
+
+// planWithReload 有修改时在计划中加上测试和重载命令
+func planWithReload(plan *Plan, changed bool, strategy func() (*reloader, error)) (*Plan, error) {
+	if !changed {
+		return plan, nil
+	}
+	r, err := strategy()
+	if err != nil {
+		return nil, err
+	}
+	plan.Commands = append(plan.Commands, r.planCommands()...)
+	return plan, nil
+}
+
+// RemoveSite 删除 Nginx 站点配置，其他 server 块仍引用该证书时拒绝删除
+func (n *NginxConfigurator) RemoveSite(config *Config) (bool, error) {
+	n.changes = newChangeSet(false)
+	n.setup(config)
+	return n.removeSite(config)
+}
+
+// PlanRemoveSite 计算 RemoveSite 将执行的修改
+func (n *NginxConfigurator) PlanRemoveSite(config *Config) (*Plan, error) {
+	n.changes = newChangeSet(true)
+	n.setup(config)
+	changed, err := n.removeSite(config)
+	if err != nil {
+		return nil, err
+	}
+	return planWithReload(n.changes.plan, changed, n.reloadStrategy)
+}
+
+// removeSite 删除按证书名称（或旧版本按全部域名）命名、由 AutoCert 生成的站点配置
+// sites-enabled 中的链接和它指向的 sites-available 文件一起删除
+func (n *NginxConfigurator) removeSite(config *Config) (bool, error) {
+	if _, err := n.reloadStrategy(); err != nil {
+		return false, fmt.Errorf("重载配置无效: %w", err)
+	}
+	if err := n.findConfigPath(); err != nil {
+		return false, fmt.Errorf("查找 Nginx 配置路径失败: %w", err)
+	}
+	parsed, err := loadNginxConfig(n.configPath)
+	if err != nil {
+		return false, fmt.Errorf("解析 Nginx 配置失败: %w", err)
+	}
+
+	name := siteFileName(config)
+	var paths []string
+	removed := make(map[string]bool)
+	for _, path := range parsed.Order {
+		base := filepath.Base(path)
+		if base != name && base != name+".conf" && !isLegacySite(path, config) {
+			continue
+		}
+		if !strings.HasPrefix(readFileString(path), generatedMarker) {
+			logger.Warn("站点配置不是由 AutoCert 生成的，保留", "configFile", path)
+			continue
+		}
+
+		removed[path] = true
+		paths = append(paths, path)
+		if target, err := filepath.EvalSymlinks(path); err == nil && target != path {
+			paths = append(paths, target)
+		}
+	}
+
+	// 删除后仍有配置引用证书文件时，重载会失败
+	certDir := filepath.Dir(config.FullchainPath)
+	for _, path := range parsed.Order {
+		if removed[path] {
+			continue
+		}
+		if file := parsed.Files[path]; file != nil && nginxReferencesDir(file.Directives, certDir) {
+			return false, fmt.Errorf("%s 仍引用证书 %s，请先修改该配置", path, certDir)
+		}
+	}
+
+	for _, path := range paths {
+		if err := n.changes.remove(path); err != nil {
+			return false, err
+		}
+		logger.Info("删除 Nginx 站点配置", "file", path)
+	}
+	return len(paths) > 0, nil
+}
+
+// nginxReferencesDir 配置中是否有 ssl_certificate、ssl_certificate_key 引用目录中的文件
+func nginxReferencesDir(ds []*nginxDirective, dir string) bool {
+	for _, d := range ds {
+		if (d.Name == "ssl_certificate" || d.Name == "ssl_certificate_key") && len(d.Args) > 0 && isUnderDir(d.Args[0], dir) {
+			return true
+		}
+		if nginxReferencesDir(d.Block, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveSite 禁用并删除 Apache 站点配置，其他 VirtualHost 仍引用该证书时拒绝删除
+func (a *ApacheConfigurator) RemoveSite(config *Config) (bool, error) {
+	a.changes = newChangeSet(false)
+	a.reloadOpts = config.Reload
+	return a.removeSite(config)
+}
+
+// PlanRemoveSite 计算 RemoveSite 将执行的修改
+func (a *ApacheConfigurator) PlanRemoveSite(config *Config) (*Plan, error) {
+	a.changes = newChangeSet(true)
+	a.reloadOpts = config.Reload
+	changed, err := a.removeSite(config)
+	if err != nil {
+		return nil, err
+	}
+	return planWithReload(a.changes.plan, changed, a.reloadStrategy)
+}
+
+// removeSite 删除 createSiteConfig 创建的站点配置，Debian 布局先禁用站点
+func (a *ApacheConfigurator) removeSite(config *Config) (bool, error) {
+	if err := a.detectLayout(); err != nil {
+		return false, fmt.Errorf("查找 Apache 配置路径失败: %w", err)
+	}
+	if _, err := a.reloadStrategy(); err != nil {
+		return false, fmt.Errorf("重载配置无效: %w", err)
+	}
+
+	if len(strings.Fields(config.Domain)) == 0 {
+		return false, fmt.Errorf("域名不能为空")
+	}
+
+	var configFiles []string
+	configFile := filepath.Join(a.layout.siteDir, apacheSiteName(config)+".conf")
+	if content := readFileString(configFile); strings.HasPrefix(content, generatedMarker) {
+		configFiles = append(configFiles, configFile)
+	} else if content != "" {
+		logger.Warn("站点配置不是由 AutoCert 生成的，保留", "configFile", configFile)
+	}
+	configFiles = append(configFiles, a.legacySiteFiles(config)...)
+	if len(configFiles) == 0 {
+		return false, nil
+	}
+
+	certDir := filepath.Dir(config.FullchainPath)
+	for _, vhost := range parseApacheVirtualHosts(a.layout.mainConfig, a.layout.serverRoot) {
+		if !isUnderDir(vhost.CertFile, certDir) {
+			continue
+		}
+		if !slices.ContainsFunc(configFiles, func(f string) bool { return sameFile(vhost.File, f) }) {
+			return false, fmt.Errorf("%s 仍引用证书 %s，请先修改该配置", vhost.File, certDir)
+		}
+	}
+
+	for _, file := range configFiles {
+		if err := a.disableSite(file); err != nil {
+			return false, err
+		}
+		if err := a.changes.remove(file); err != nil {
+			return false, err
+		}
+		logger.Info("删除 Apache 站点配置", "configFile", file)
+	}
+	return true, nil
+}
+
+// disableSite Debian 布局中禁用站点，其他布局没有启用链接
+func (a *ApacheConfigurator) disableSite(configFile string) error {
+	if a.layout.enabledDir == "" {
+		return nil
+	}
+	linkPath := filepath.Join(a.layout.enabledDir, filepath.Base(configFile))
+	if _, err := os.Lstat(linkPath); err != nil {
+		return nil
+	}
+
+	siteName := strings.TrimSuffix(filepath.Base(configFile), ".conf")
+	if _, err := exec.LookPath("a2dissite"); err == nil {
+		if err := a.changes.track(linkPath); err != nil {
+			return err
+		}
+		if output, err := a.changes.run("a2dissite", "-q", siteName); err != nil {
+			return fmt.Errorf("a2dissite 执行失败: %s", string(output))
+		}
+	} else if err := a.changes.remove(linkPath); err != nil {
+		return err
+	}
+	logger.Info("禁用 Apache 站点", "site", siteName)
+	return nil
+}
+
+// lighttpdCertPattern 证书指令，用于替换 443 监听的默认证书
+var lighttpdCertPattern = regexp.MustCompile(`(?m)^[ \t]*ssl\.(pemfile|privkey)[ \t]*=.*\n`)
+
+// RemoveSite 删除 autocert.d 中的证书配置
+func (l *LighttpdConfigurator) RemoveSite(config *Config) (bool, error) {
+	l.changes = newChangeSet(false)
+	l.reloadOpts = config.Reload
+	return l.removeSite(config)
+}
+
+// PlanRemoveSite 计算 RemoveSite 将执行的修改
+func (l *LighttpdConfigurator) PlanRemoveSite(config *Config) (*Plan, error) {
+	l.changes = newChangeSet(true)
+	l.reloadOpts = config.Reload
+	changed, err := l.removeSite(config)
+	if err != nil {
+		return nil, err
+	}
+	return planWithReload(l.changes.plan, changed, l.reloadStrategy)
+}
+
+// removeSite 删除证书配置；443 监听的默认证书是该证书时改用剩余的第一个证书，没有剩余证书时删除监听配置
+func (l *LighttpdConfigurator) removeSite(config *Config) (bool, error) {
+	if _, err := l.reloadStrategy(); err != nil {
+		return false, fmt.Errorf("重载配置无效: %w", err)
+	}
+	if err := l.findConfigPath(config.ConfigPath); err != nil {
+		return false, err
+	}
+
+	siteDir := filepath.Join(filepath.Dir(l.configPath), lighttpdSiteDir)
+	siteFile := filepath.Join(siteDir, siteFileName(config)+".conf")
+	if _, err := os.Stat(siteFile); err != nil {
+		return false, nil
+	}
+	if err := l.changes.remove(siteFile); err != nil {
+		return false, err
+	}
+	logger.Info("删除 lighttpd 证书配置", "file", siteFile)
+
+	socketFile := filepath.Join(siteDir, lighttpdSocketFile)
+	socketContent := readFileString(socketFile)
+	if !strings.Contains(socketContent, filepath.Dir(config.FullchainPath)+string(filepath.Separator)) {
+		return true, nil
+	}
+
+	var replacement string
+	files, _ := filepath.Glob(filepath.Join(siteDir, "*.conf"))
+	for _, file := range files {
+		if file == siteFile || file == socketFile {
+			continue
+		}
+		if lines := lighttpdCertPattern.FindAllString(readFileString(file), -1); len(lines) > 0 {
+			replacement = strings.Join(lines, "")
+			break
+		}
+	}
+
+	if replacement == "" {
+		if err := l.changes.remove(socketFile); err != nil {
+			return false, err
+		}
+		logger.Info("没有其他证书，删除 lighttpd HTTPS 监听", "file", socketFile)
+		return true, nil
+	}
+
+	replaced := false
+	updated := lighttpdCertPattern.ReplaceAllStringFunc(socketContent, func(string) string {
+		if replaced {
+			return ""
+		}
+		replaced = true
+		return replacement
+	})
+	if _, err := l.changes.updateFile(socketFile, []byte(updated)); err != nil {
+		return false, err
+	}
+	logger.Info("lighttpd HTTPS 监听改用其他证书", "file", socketFile)
+	return true, nil
+}
+
+// RemoveSite 从 crt 目录或 crt-list 中删除证书
+func (h *HAProxyConfigurator) RemoveSite(config *Config) (bool, error) {
+	h.changes = newChangeSet(false)
+	h.reloadOpts = config.Reload
+	h.options = config.HAProxy
+	return h.removeSite(config)
+}
+
+// PlanRemoveSite 计算 RemoveSite 将执行的修改
+func (h *HAProxyConfigurator) PlanRemoveSite(config *Config) (*Plan, error) {
+	h.changes = newChangeSet(true)
+	h.reloadOpts = config.Reload
+	h.options = config.HAProxy
+	changed, err := h.removeSite(config)
+	if err != nil {
+		return nil, err
+	}
+	return planWithReload(h.changes.plan, changed, h.reloadStrategy)
+}
+
+// removeSite 删除证书后 crt 目录或 crt-list 为空时 HAProxy 无法启动，此时拒绝删除
+func (h *HAProxyConfigurator) removeSite(config *Config) (bool, error) {
+	if _, err := h.reloadStrategy(); err != nil {
+		return false, fmt.Errorf("重载配置无效: %w", err)
+	}
+	if err := h.findConfigPath(); err != nil {
+		return false, fmt.Errorf("查找 HAProxy 配置路径失败: %w", err)
+	}
+	content, err := os.ReadFile(h.configPath)
+	if err != nil {
+		return false, fmt.Errorf("读取 HAProxy 配置失败: %w", err)
+	}
+
+	crtList, certDir := h.certLocation(parseHAProxyConfig(h.configPath, string(content)))
+	switch {
+	case crtList != "":
+		data, err := os.ReadFile(crtList)
+		if err != nil {
+			return false, nil
+		}
+		var lines []string
+		found, remaining := false, 0
+		for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			fields := haproxyFields(line)
+			switch {
+			case len(fields) > 0 && fields[0] == config.CombinedPath:
+				found = true
+				continue
+			case len(fields) > 0:
+				remaining++
+			}
+			lines = append(lines, line)
+		}
+		if !found {
+			return false, nil
+		}
+		if remaining == 0 {
+			return false, fmt.Errorf("删除后 crt-list %s 中没有证书，HAProxy 将无法启动，请先修改 bind 配置", crtList)
+		}
+		backupPath, err := h.changes.updateFile(crtList, []byte(strings.Join(lines, "\n")+"\n"))
+		if err != nil {
+			return false, err
+		}
+		logger.Info("从 crt-list 中删除证书", "crtList", crtList, "file", config.CombinedPath, "backup", backupPath)
+		return true, nil
+
+	case certDir != "":
+		path := filepath.Join(certDir, siteFileName(config)+".pem")
+		if _, err := os.Stat(path); err != nil {
+			return false, nil
+		}
+		others, _ := filepath.Glob(filepath.Join(certDir, "*.pem"))
+		if len(others) <= 1 {
+			return false, fmt.Errorf("删除后 crt 目录 %s 中没有证书，HAProxy 将无法启动，请先修改 bind 配置", certDir)
+		}
+		if err := h.changes.remove(path); err != nil {
+			return false, err
+		}
+		logger.Info("删除 HAProxy 证书", "file", path)
+		return true, nil
+	}
+	return false, nil
+}
+
+// RemoveSite 从 Traefik 动态配置中删除证书条目
+func (t *TraefikConfigurator) RemoveSite(config *Config) (bool, error) {
+	t.changes = newChangeSet(false)
+	t.setup(config)
+	return t.removeSite(config)
+}
+
+// PlanRemoveSite 计算 RemoveSite 将执行的修改
+func (t *TraefikConfigurator) PlanRemoveSite(config *Config) (*Plan, error) {
+	t.changes = newChangeSet(true)
+	t.setup(config)
+	changed, err := t.removeSite(config)
+	if err != nil {
+		return nil, err
+	}
+	// Traefik 自动加载动态配置，只有配置了重载方式时才执行
+	if t.reloadOpts.Method == "" {
+		return t.changes.plan, nil
+	}
+	return planWithReload(t.changes.plan, changed, t.reloadStrategy)
+}
+
+// removeSite 删除 certFile 为该证书的条目
+func (t *TraefikConfigurator) removeSite(config *Config) (bool, error) {
+	if _, err := t.reloadStrategy(); err != nil {
+		return false, fmt.Errorf("重载配置无效: %w", err)
+	}
+	doc, exists, err := t.loadForEdit()
+	if err != nil {
+		return false, fmt.Errorf("读取 Traefik 动态配置失败: %w", err)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	certs := traefikCertificates(doc)
+	var kept []map[string]interface{}
+	for _, c := range certs {
+		if certFile, _ := c["certFile"].(string); certFile != config.FullchainPath {
+			kept = append(kept, c)
+		}
+	}
+	if len(kept) == len(certs) {
+		return false, nil
+	}
+	setTraefikCertificates(doc, kept)
+
+	data, err := t.encode(doc)
+	if err != nil {
+		return false, fmt.Errorf("生成 Traefik 动态配置失败: %w", err)
+	}
+	backupPath, err := t.changes.updateFile(t.dynamicFile, data)
+	if err != nil {
+		return false, err
+	}
+	logger.Info("从 Traefik 动态配置中删除证书", "file", t.dynamicFile, "backup", backupPath)
+	return true, nil
+}
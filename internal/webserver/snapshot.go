@@ -0,0 +1,169 @@
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// snapshotEntry 文件在第一次被修改之前的状态
+type snapshotEntry struct {
+	path    string
+	existed bool
+	dir     bool   // 新建的目录，回滚时为空才删除
+	link    string // 原来是符号链接时的链接目标
+	data    []byte
+	mode    os.FileMode
+}
+
+// snapshot 记录配置器修改过的文件，用于配置测试或重载失败时回滚
+type snapshot struct {
+	entries []snapshotEntry
+	seen    map[string]bool
+}
+
+// newSnapshot 创建空的快照
+func newSnapshot() *snapshot {
+	return &snapshot{seen: make(map[string]bool)}
+}
+
+// track 在修改文件（或符号链接）之前记录其当前状态，同一路径只记录第一次
+func (s *snapshot) track(path string) error {
+	if s.seen[path] {
+		return nil
+	}
+
+	entry := snapshotEntry{path: path}
+	info, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("记录文件状态失败: %w", err)
+	case info.Mode()&os.ModeSymlink != 0:
+		entry.existed = true
+		if entry.link, err = os.Readlink(path); err != nil {
+			return fmt.Errorf("读取符号链接失败: %w", err)
+		}
+	default:
+		entry.existed = true
+		entry.mode = info.Mode().Perm()
+		if entry.data, err = os.ReadFile(path); err != nil {
+			return fmt.Errorf("读取文件失败: %w", err)
+		}
+	}
+
+	s.seen[path] = true
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+// trackDir 在创建目录之前记录其中不存在的各级目录，从上到下记录，回滚时从下到上删除
+func (s *snapshot) trackDir(dir string) error {
+	var missing []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil {
+			break
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("记录目录状态失败: %w", err)
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		if s.seen[missing[i]] {
+			continue
+		}
+		s.seen[missing[i]] = true
+		s.entries = append(s.entries, snapshotEntry{path: missing[i], dir: true})
+	}
+	return nil
+}
+
+// restore 按修改的相反顺序恢复所有文件，返回每一项被撤销的修改
+func (s *snapshot) restore() ([]string, error) {
+	var reverted []string
+	var errs []error
+
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		e := s.entries[i]
+		desc, err := e.restore()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.path, err))
+			continue
+		}
+		if desc != "" {
+			reverted = append(reverted, desc)
+		}
+	}
+
+	s.entries = nil
+	s.seen = make(map[string]bool)
+	return reverted, errors.Join(errs...)
+}
+
+// restore 恢复单个文件，文件未被修改时返回空描述
+func (e snapshotEntry) restore() (string, error) {
+	info, statErr := os.Lstat(e.path)
+	exists := statErr == nil
+
+	if e.dir {
+		if !exists || !info.IsDir() {
+			return "", nil
+		}
+		// 目录中还有其他程序写入的文件时保留
+		if entries, err := os.ReadDir(e.path); err != nil || len(entries) > 0 {
+			return "", err
+		}
+		if err := os.Remove(e.path); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("删除新建的目录 %s", e.path), nil
+	}
+
+	if !e.existed {
+		if !exists {
+			return "", nil
+		}
+		if err := os.Remove(e.path); err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Sprintf("删除新建的符号链接 %s", e.path), nil
+		}
+		return fmt.Sprintf("删除新建的文件 %s", e.path), nil
+	}
+
+	if e.link != "" {
+		if current, err := os.Readlink(e.path); err == nil && current == e.link {
+			return "", nil
+		}
+		if exists {
+			if err := os.Remove(e.path); err != nil {
+				return "", err
+			}
+		}
+		if err := os.Symlink(e.link, e.path); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("恢复符号链接 %s -> %s", e.path, e.link), nil
+	}
+
+	if exists && info.Mode().IsRegular() {
+		if current, err := os.ReadFile(e.path); err == nil && string(current) == string(e.data) {
+			return "", nil
+		}
+	} else if exists {
+		// 原来的普通文件被替换成了符号链接
+		if err := os.Remove(e.path); err != nil {
+			return "", err
+		}
+	}
+	if err := os.WriteFile(e.path, e.data, e.mode); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("恢复文件 %s", e.path), nil
+}
@@ -0,0 +1,97 @@
+package webserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChangeSetRollback(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "existing.conf")
+	if err := os.WriteFile(existing, []byte("old\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(root, "enabled.conf")
+	if err := os.Symlink(existing, link); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newChangeSet(false)
+	siteDir := filepath.Join(root, "a", "b", "sites")
+	if err := c.mkdirAll(siteDir); err != nil {
+		t.Fatal(err)
+	}
+	site := filepath.Join(siteDir, "example.com.conf")
+	if err := c.writeFile(site, []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.writeFile(existing, []byte("changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.symlink(site, link); err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, err := c.rollback()
+	if err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if len(reverted) != 6 {
+		t.Errorf("reverted = %q", reverted)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "a")); !os.IsNotExist(err) {
+		t.Errorf("created directories not removed: %v", err)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "old\n" {
+		t.Errorf("existing file = %q", data)
+	}
+	if info, _ := os.Stat(existing); info.Mode().Perm() != 0640 {
+		t.Errorf("existing file mode = %v", info.Mode().Perm())
+	}
+	if target, _ := os.Readlink(link); target != existing {
+		t.Errorf("symlink target = %q", target)
+	}
+}
+
+func TestChangeSetRollbackKeepsNonEmptyDirs(t *testing.T) {
+	root := t.TempDir()
+	c := newChangeSet(false)
+	dir := filepath.Join(root, "a", "b")
+	if err := c.mkdirAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.writeFile(filepath.Join(dir, "site.conf"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 其他程序在新建的目录中写入的文件
+	other := filepath.Join(root, "a", "other.conf")
+	if err := os.WriteFile(other, []byte("keep\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.rollback(); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("empty directory %s not removed", dir)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("unrelated file removed: %v", err)
+	}
+}
+
+func TestChangeSetPlanDoesNotTouchDirs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	c := newChangeSet(true)
+	if err := c.mkdirAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("plan created %s", dir)
+	}
+	if len(c.plan.Dirs) != 1 || c.plan.Dirs[0] != dir {
+		t.Errorf("plan dirs = %v", c.plan.Dirs)
+	}
+}
@@ -0,0 +1,492 @@
+package webserver
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// 内置站点模板
+const (
+	TemplateStatic   = "static"   // 静态网站
+	TemplatePHP      = "php"      // PHP-FPM
+	TemplateProxy    = "proxy"    // 反向代理到上游服务
+	TemplateRedirect = "redirect" // 只做跳转
+)
+
+// DefaultPHPUpstream PHP-FPM 模板未指定上游时使用的地址
+const DefaultPHPUpstream = "unix:/run/php/php-fpm.sock"
+
+// TemplateData 站点模板中可以使用的变量
+//
+//	.Domain         空格分隔的全部域名（可直接用于 server_name）
+//	.Domains        域名列表，精确域名排在泛域名之前（用于 server_name）
+//	.ServerName     第一个精确域名，全部为泛域名时为空（Apache 的 ServerName 不支持通配符）
+//	.ServerAliases  其余域名
+//	.CertPath       叶子证书路径
+//	.ChainPath      中间证书链路径
+//	.FullchainPath  完整证书链路径
+//	.KeyPath        私钥路径
+//	.WebRoot        网站根目录
+//	.ChallengeDir   ACME HTTP-01 验证文件目录（提供其中的 /.well-known/acme-challenge/）
+//	.Upstream       上游地址：proxy 为代理地址，php 为 PHP-FPM 地址，redirect 为跳转目标
+//	.Redirect       是否将 HTTP 重定向到 HTTPS
+//	.LegacyChain    Apache 2.4.8 之前需要单独的 SSLCertificateChainFile
+//	.TLS            TLS 安全配置（协议、加密套件、会话、OCSP Stapling、HSTS）
+//
+// 自定义模板中还可以使用内置的子模板，例如 {{template "ssl" .}}
+type TemplateData struct {
+	*Config
+	Domains       []string
+	ServerName    string
+	ServerAliases []string
+	LegacyChain   bool
+	TLS           *TLSSettings
+}
+
+// templateFuncs 模板中可用的函数
+var templateFuncs = template.FuncMap{
+	"join":       strings.Join,
+	"hasPrefix":  strings.HasPrefix,
+	"trimPrefix": strings.TrimPrefix,
+	"trimSuffix": strings.TrimSuffix,
+}
+
+// BuiltinTemplates 内置模板名称
+func BuiltinTemplates() []string {
+	names := make([]string, 0, len(nginxTemplates))
+	for name := range nginxTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newTemplateData 创建模板数据
+func newTemplateData(config *Config) (*TemplateData, error) {
+	domains := strings.Fields(config.Domain)
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("域名不能为空")
+	}
+
+	tls, err := newTLSSettings(config.Security)
+	if err != nil {
+		return nil, err
+	}
+
+	// 在副本上补充默认值，调用方的配置保持不变
+	cfg := *config
+	if cfg.Template == TemplatePHP && cfg.Upstream == "" {
+		cfg.Upstream = DefaultPHPUpstream
+	}
+
+	domains = exactDomainsFirst(domains)
+	data := &TemplateData{
+		Config:        &cfg,
+		Domains:       domains,
+		ServerAliases: domains,
+		TLS:           tls,
+	}
+	if !strings.HasPrefix(domains[0], "*.") {
+		data.ServerName = domains[0]
+		data.ServerAliases = domains[1:]
+	}
+	return data, nil
+}
+
+// exactDomainsFirst 将精确域名排在泛域名之前，保持各自的顺序
+// Nginx 的 $server_name 和重定向使用第一个名称，不能是泛域名
+func exactDomainsFirst(domains []string) []string {
+	result := make([]string, 0, len(domains))
+	for _, d := range domains {
+		if !strings.HasPrefix(d, "*.") {
+			result = append(result, d)
+		}
+	}
+	for _, d := range domains {
+		if strings.HasPrefix(d, "*.") {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// renderTemplate 渲染站点配置
+// config.Template 为内置模板名称或自定义模板文件路径，为空时使用静态网站模板
+func renderTemplate(server, common string, builtin map[string]string, data *TemplateData) (string, error) {
+	name := data.Template
+	if name == "" {
+		name = TemplateStatic
+	}
+
+	text, ok := builtin[name]
+	if ok {
+		if err := checkTemplateConfig(name, data.Config); err != nil {
+			return "", err
+		}
+	} else {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return "", fmt.Errorf("读取模板失败（内置模板: %s）: %w", strings.Join(BuiltinTemplates(), ", "), err)
+		}
+		text = string(content)
+	}
+
+	t, err := template.New(server).Funcs(templateFuncs).Parse(common)
+	if err != nil {
+		return "", err
+	}
+	if t, err = t.Parse(text); err != nil {
+		return "", fmt.Errorf("解析模板 %s 失败: %w", name, err)
+	}
+
+	var result strings.Builder
+	if err := t.Execute(&result, data); err != nil {
+		return "", fmt.Errorf("渲染模板 %s 失败: %w", name, err)
+	}
+	return result.String(), nil
+}
+
+// checkTemplateConfig 检查内置模板需要的参数
+func checkTemplateConfig(name string, config *Config) error {
+	switch name {
+	case TemplateProxy:
+		if config.Upstream == "" {
+			return fmt.Errorf("反向代理模板需要指定上游地址 (--upstream)")
+		}
+	case TemplateRedirect:
+		if config.Upstream == "" {
+			return fmt.Errorf("跳转模板需要指定跳转目标 (--upstream)")
+		}
+	}
+	return nil
+}
+
+// nginxCommonTemplate Nginx 模板共用的子模板
+const nginxCommonTemplate = `
+{{- define "acme"}}
+    # ACME 挑战目录
+    location ^~ /.well-known/acme-challenge/ {
+        default_type "text/plain";
+        root {{.ChallengeDir}};
+    }
+{{- end}}
+
+{{- define "http"}}
+{{- if .Redirect -}}
+server {
+    listen 80;
+    server_name {{join .Domains " "}};
+{{- if .ChallengeDir}}
+{{template "acme" .}}
+{{- end}}
+
+    # 重定向 HTTP 到 HTTPS
+    location / {
+        return 301 https://$host$request_uri;
+    }
+}
+{{end}}
+{{- end}}
+
+{{- define "ssl"}}
+    # SSL 证书配置
+    ssl_certificate {{.FullchainPath}};
+    ssl_certificate_key {{.KeyPath}};
+
+    # SSL 安全配置（Mozilla {{.TLS.Profile}}）
+    ssl_protocols {{.TLS.NginxProtocols}};
+{{- if .TLS.Ciphers}}
+    ssl_ciphers {{.TLS.Ciphers}};
+{{- end}}
+    ssl_prefer_server_ciphers {{if .TLS.PreferServerCiphers}}on{{else}}off{{end}};
+    ssl_session_cache shared:SSL:10m;
+    ssl_session_timeout {{.TLS.SessionTimeout}}s;
+    ssl_session_tickets {{if .TLS.SessionTickets}}on{{else}}off{{end}};
+{{- if .TLS.OCSPStapling}}
+
+    # OCSP Stapling
+    ssl_stapling on;
+    ssl_stapling_verify on;
+    ssl_trusted_certificate {{.ChainPath}};
+{{- if .TLS.Resolver}}
+    resolver {{.TLS.Resolver}};
+{{- end}}
+{{- end}}
+{{- if .TLS.HSTS}}
+
+    add_header Strict-Transport-Security "{{.TLS.HSTS}}" always;
+{{- end}}
+{{- end}}`
+
+// nginxTemplates Nginx 内置模板
+var nginxTemplates = map[string]string{
+	TemplateStatic: `# AutoCert 自动生成的配置
+{{template "http" .}}
+server {
+    listen 443 ssl http2;
+    server_name {{join .Domains " "}};
+{{template "ssl" .}}
+{{- if .WebRoot}}
+
+    # 网站根目录
+    root {{.WebRoot}};
+    index index.html index.htm;
+{{- end}}
+
+    location / {
+        try_files $uri $uri/ =404;
+    }
+{{- if .ChallengeDir}}
+{{template "acme" .}}
+{{- end}}
+}
+`,
+
+	TemplatePHP: `# AutoCert 自动生成的配置（PHP-FPM）
+{{template "http" .}}
+server {
+    listen 443 ssl http2;
+    server_name {{join .Domains " "}};
+{{template "ssl" .}}
+{{- if .WebRoot}}
+
+    # 网站根目录
+    root {{.WebRoot}};
+{{- end}}
+    index index.php index.html index.htm;
+
+    location / {
+        try_files $uri $uri/ /index.php?$query_string;
+    }
+
+    location ~ \.php$ {
+        try_files $uri =404;
+        fastcgi_split_path_info ^(.+\.php)(/.+)$;
+        fastcgi_pass {{.Upstream}};
+        fastcgi_index index.php;
+        include fastcgi_params;
+        fastcgi_param SCRIPT_FILENAME $document_root$fastcgi_script_name;
+    }
+
+    location ~ /\.(?!well-known) {
+        deny all;
+    }
+{{- if .ChallengeDir}}
+{{template "acme" .}}
+{{- end}}
+}
+`,
+
+	TemplateProxy: `# AutoCert 自动生成的配置（反向代理）
+{{template "http" .}}
+server {
+    listen 443 ssl http2;
+    server_name {{join .Domains " "}};
+{{template "ssl" .}}
+
+    location / {
+        proxy_pass {{.Upstream}};
+        proxy_http_version 1.1;
+        proxy_set_header Host $host;
+        proxy_set_header X-Real-IP $remote_addr;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+        proxy_set_header X-Forwarded-Proto $scheme;
+        proxy_set_header Upgrade $http_upgrade;
+        proxy_set_header Connection "upgrade";
+    }
+{{- if .ChallengeDir}}
+{{template "acme" .}}
+{{- end}}
+}
+`,
+
+	TemplateRedirect: `# AutoCert 自动生成的配置（跳转到 {{.Upstream}}）
+server {
+    listen 80;
+    server_name {{join .Domains " "}};
+{{- if .ChallengeDir}}
+{{template "acme" .}}
+{{- end}}
+
+    location / {
+        return 301 {{trimSuffix .Upstream "/"}}$request_uri;
+    }
+}
+
+server {
+    listen 443 ssl http2;
+    server_name {{join .Domains " "}};
+{{template "ssl" .}}
+
+    location / {
+        return 301 {{trimSuffix .Upstream "/"}}$request_uri;
+    }
+}
+`,
+}
+
+// apacheCommonTemplate Apache 模板共用的子模板
+const apacheCommonTemplate = `
+{{- define "names"}}
+{{- if .ServerName}}
+    ServerName {{.ServerName}}
+{{- end}}
+{{- range .ServerAliases}}
+    ServerAlias {{.}}
+{{- end}}
+{{- end}}
+
+{{- define "acme"}}
+{{- if .ChallengeDir}}
+
+    # ACME 挑战目录
+    Alias /.well-known/acme-challenge/ {{.ChallengeDir}}/.well-known/acme-challenge/
+    <Directory {{.ChallengeDir}}/.well-known/acme-challenge/>
+        Require all granted
+    </Directory>
+{{- end}}
+{{- end}}
+
+{{- define "docroot"}}
+{{- if .WebRoot}}
+
+    DocumentRoot {{.WebRoot}}
+    <Directory {{.WebRoot}}>
+        Require all granted
+    </Directory>
+{{- end}}
+{{- end}}
+
+{{- define "http" -}}
+<VirtualHost *:80>
+{{- template "names" .}}
+{{- if .Redirect}}
+
+    # 重定向 HTTP 到 HTTPS（保留 ACME 挑战路径）
+    <IfModule mod_rewrite.c>
+        RewriteEngine On
+        RewriteCond %{REQUEST_URI} !^/\.well-known/acme-challenge/
+        RewriteRule ^ https://%{HTTP_HOST}%{REQUEST_URI} [END,NE,R=permanent]
+    </IfModule>
+{{- end}}
+{{- if .WebRoot}}
+
+    DocumentRoot {{.WebRoot}}
+{{- end}}
+{{- template "acme" .}}
+</VirtualHost>
+{{- end}}
+
+{{- define "ssl"}}
+    # SSL 证书配置
+    SSLEngine on
+{{- if .LegacyChain}}
+    SSLCertificateFile {{.CertPath}}
+    SSLCertificateChainFile {{.ChainPath}}
+{{- else}}
+    SSLCertificateFile {{.FullchainPath}}
+{{- end}}
+    SSLCertificateKeyFile {{.KeyPath}}
+
+    # SSL 安全配置（Mozilla {{.TLS.Profile}}）
+    SSLProtocol {{.TLS.ApacheProtocols}}
+{{- if .TLS.Ciphers}}
+    SSLCipherSuite {{.TLS.Ciphers}}
+{{- end}}
+    SSLHonorCipherOrder {{if .TLS.PreferServerCiphers}}on{{else}}off{{end}}
+    SSLSessionCacheTimeout {{.TLS.SessionTimeout}}
+    SSLSessionTickets {{if .TLS.SessionTickets}}on{{else}}off{{end}}
+{{- if .TLS.OCSPStapling}}
+    SSLUseStapling on
+{{- end}}
+{{- if .TLS.HSTS}}
+
+    <IfModule mod_headers.c>
+        Header always set Strict-Transport-Security "{{.TLS.HSTS}}"
+    </IfModule>
+{{- end}}
+{{- end}}
+
+{{- define "global"}}
+{{- if .TLS.OCSPStapling -}}
+# OCSP Stapling 缓存只能在全局配置中设置
+SSLStaplingCache "shmcb:logs/ssl_stapling(32768)"
+{{end}}
+{{- end}}`
+
+// apacheTemplates Apache 内置模板
+var apacheTemplates = map[string]string{
+	TemplateStatic: `# AutoCert 自动生成的配置
+{{template "http" .}}
+
+<IfModule mod_ssl.c>
+{{template "global" .}}<VirtualHost *:443>
+{{- template "names" .}}
+{{- template "docroot" .}}
+{{template "ssl" .}}
+</VirtualHost>
+</IfModule>
+`,
+
+	TemplatePHP: `# AutoCert 自动生成的配置（PHP-FPM）
+{{template "http" .}}
+
+<IfModule mod_ssl.c>
+{{template "global" .}}<VirtualHost *:443>
+{{- template "names" .}}
+{{- template "docroot" .}}
+    DirectoryIndex index.php index.html
+
+    <FilesMatch "\.php$">
+{{- if hasPrefix .Upstream "unix:"}}
+        SetHandler "proxy:{{.Upstream}}|fcgi://localhost"
+{{- else}}
+        SetHandler "proxy:fcgi://{{.Upstream}}"
+{{- end}}
+    </FilesMatch>
+{{template "ssl" .}}
+</VirtualHost>
+</IfModule>
+`,
+
+	TemplateProxy: `# AutoCert 自动生成的配置（反向代理）
+{{template "http" .}}
+
+<IfModule mod_ssl.c>
+{{template "global" .}}<VirtualHost *:443>
+{{- template "names" .}}
+
+    ProxyPreserveHost On
+    ProxyPass /.well-known/acme-challenge/ !
+    ProxyPass / {{trimSuffix .Upstream "/"}}/
+    ProxyPassReverse / {{trimSuffix .Upstream "/"}}/
+    RequestHeader set X-Forwarded-Proto "https"
+{{- template "acme" .}}
+{{template "ssl" .}}
+</VirtualHost>
+</IfModule>
+`,
+
+	TemplateRedirect: `# AutoCert 自动生成的配置（跳转到 {{.Upstream}}）
+<VirtualHost *:80>
+{{- template "names" .}}
+{{- if .ChallengeDir}}
+{{- template "acme" .}}
+    RedirectMatch permanent ^/(?!\.well-known/acme-challenge/)(.*)$ {{trimSuffix .Upstream "/"}}/$1
+{{- else}}
+    Redirect permanent / {{trimSuffix .Upstream "/"}}/
+{{- end}}
+</VirtualHost>
+
+<IfModule mod_ssl.c>
+{{template "global" .}}<VirtualHost *:443>
+{{- template "names" .}}
+    Redirect permanent / {{trimSuffix .Upstream "/"}}/
+{{template "ssl" .}}
+</VirtualHost>
+</IfModule>
+`,
+}
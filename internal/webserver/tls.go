@@ -0,0 +1,184 @@
+package webserver
+
+import (
+	"autocert/internal/config"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Mozilla TLS 安全配置
+// 参考 https://wiki.mozilla.org/Security/Server_Side_TLS
+const (
+	TLSProfileModern       = "modern"
+	TLSProfileIntermediate = "intermediate"
+	TLSProfileOld          = "old"
+)
+
+// 默认值
+const (
+	DefaultTLSProfile     = TLSProfileIntermediate
+	DefaultSessionTimeout = 24 * time.Hour
+	DefaultHSTSMaxAge     = 2 * 365 * 24 * time.Hour
+)
+
+// tlsProfile 协议和加密套件
+type tlsProfile struct {
+	protocols           []string
+	ciphers             string // TLS 1.2 及以下的 OpenSSL 加密套件，TLS 1.3 套件由 OpenSSL 自动选择
+	preferServerCiphers bool
+}
+
+// tlsProfiles Mozilla 推荐配置（5.7 版）
+var tlsProfiles = map[string]tlsProfile{
+	TLSProfileModern: {
+		protocols: []string{"TLSv1.3"},
+	},
+	TLSProfileIntermediate: {
+		protocols: []string{"TLSv1.2", "TLSv1.3"},
+		ciphers: "ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384:" +
+			"ECDHE-RSA-AES256-GCM-SHA384:ECDHE-ECDSA-CHACHA20-POLY1305:ECDHE-RSA-CHACHA20-POLY1305:" +
+			"DHE-RSA-AES128-GCM-SHA256:DHE-RSA-AES256-GCM-SHA384:DHE-RSA-CHACHA20-POLY1305",
+	},
+	TLSProfileOld: {
+		protocols: []string{"TLSv1", "TLSv1.1", "TLSv1.2", "TLSv1.3"},
+		ciphers: "ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384:" +
+			"ECDHE-RSA-AES256-GCM-SHA384:ECDHE-ECDSA-CHACHA20-POLY1305:ECDHE-RSA-CHACHA20-POLY1305:" +
+			"DHE-RSA-AES128-GCM-SHA256:DHE-RSA-AES256-GCM-SHA384:DHE-RSA-CHACHA20-POLY1305:" +
+			"ECDHE-ECDSA-AES128-SHA256:ECDHE-RSA-AES128-SHA256:ECDHE-ECDSA-AES128-SHA:ECDHE-RSA-AES128-SHA:" +
+			"ECDHE-ECDSA-AES256-SHA384:ECDHE-RSA-AES256-SHA384:ECDHE-ECDSA-AES256-SHA:ECDHE-RSA-AES256-SHA:" +
+			"DHE-RSA-AES128-SHA256:DHE-RSA-AES256-SHA256:AES128-GCM-SHA256:AES256-GCM-SHA384:" +
+			"AES128-SHA256:AES256-SHA256:AES128-SHA:AES256-SHA:DES-CBC3-SHA",
+		preferServerCiphers: true,
+	},
+}
+
+// TLSProfiles 支持的安全配置名称
+func TLSProfiles() []string {
+	names := make([]string, 0, len(tlsProfiles))
+	for name := range tlsProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TLSSettings 模板中使用的 TLS 配置（.TLS）
+type TLSSettings struct {
+	Profile             string
+	Protocols           []string
+	Ciphers             string
+	PreferServerCiphers bool
+	SessionTimeout      int // 秒
+	SessionTickets      bool
+	OCSPStapling        bool
+	Resolver            string
+	HSTS                string // Strict-Transport-Security 头的值，为空时不启用
+}
+
+// newTLSSettings 根据安全配置生成 TLS 配置
+func newTLSSettings(sec config.SecurityConfig) (*TLSSettings, error) {
+	name := strings.ToLower(sec.Profile)
+	if name == "" {
+		name = DefaultTLSProfile
+	}
+
+	profile, ok := tlsProfiles[name]
+	if !ok {
+		return nil, fmt.Errorf("不支持的 TLS 安全配置: %s（可选: %s）", sec.Profile, strings.Join(TLSProfiles(), ", "))
+	}
+
+	timeout := sec.SessionTimeout
+	if timeout <= 0 {
+		timeout = DefaultSessionTimeout
+	}
+
+	return &TLSSettings{
+		Profile:             name,
+		Protocols:           profile.protocols,
+		Ciphers:             profile.ciphers,
+		PreferServerCiphers: profile.preferServerCiphers,
+		SessionTimeout:      int(timeout / time.Second),
+		SessionTickets:      sec.SessionTickets,
+		OCSPStapling:        sec.OCSPStapling,
+		Resolver:            sec.Resolver,
+		HSTS:                hstsHeader(sec.HSTS),
+	}, nil
+}
+
+// securityConfigured 是否显式配置了安全选项
+func securityConfigured(sec config.SecurityConfig) bool {
+	return sec != (config.SecurityConfig{})
+}
+
+// NginxProtocols ssl_protocols 的参数
+func (t *TLSSettings) NginxProtocols() string {
+	return strings.Join(t.Protocols, " ")
+}
+
+// ApacheProtocols SSLProtocol 的参数
+func (t *TLSSettings) ApacheProtocols() string {
+	parts := []string{"-all"}
+	for _, p := range t.Protocols {
+		parts = append(parts, "+"+p)
+	}
+	return strings.Join(parts, " ")
+}
+
+// nginxDirectives TLS 配置对应的 Nginx 指令，用于更新已有的 server 块
+// 每一项为指令名和参数；add_header 以第一个参数区分
+func (t *TLSSettings) nginxDirectives() [][]string {
+	onOff := func(b bool) string {
+		if b {
+			return "on"
+		}
+		return "off"
+	}
+
+	directives := [][]string{
+		{"ssl_protocols", t.NginxProtocols()},
+	}
+	if t.Ciphers != "" {
+		directives = append(directives, []string{"ssl_ciphers", t.Ciphers})
+	}
+	directives = append(directives,
+		[]string{"ssl_prefer_server_ciphers", onOff(t.PreferServerCiphers)},
+		[]string{"ssl_session_timeout", fmt.Sprintf("%ds", t.SessionTimeout)},
+		[]string{"ssl_session_tickets", onOff(t.SessionTickets)},
+	)
+	if t.OCSPStapling {
+		directives = append(directives,
+			[]string{"ssl_stapling", "on"},
+			[]string{"ssl_stapling_verify", "on"},
+		)
+		if t.Resolver != "" {
+			directives = append(directives, []string{"resolver", t.Resolver})
+		}
+	}
+	if t.HSTS != "" {
+		directives = append(directives, []string{"add_header", "Strict-Transport-Security", `"` + t.HSTS + `"`, "always"})
+	}
+	return directives
+}
+
+// hstsHeader 生成 Strict-Transport-Security 头的值
+func hstsHeader(hsts config.HSTSConfig) string {
+	if !hsts.Enabled {
+		return ""
+	}
+
+	maxAge := hsts.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultHSTSMaxAge
+	}
+
+	value := fmt.Sprintf("max-age=%d", int64(maxAge/time.Second))
+	if hsts.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if hsts.Preload {
+		value += "; preload"
+	}
+	return value
+}
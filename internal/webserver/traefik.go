@@ -0,0 +1,368 @@
+package webserver
+
+import (
+	"autocert/internal/config"
+	"autocert/internal/logger"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultTraefikDynamicFile Traefik 动态配置文件的默认路径
+const DefaultTraefikDynamicFile = "/etc/traefik/dynamic/autocert.yml"
+
+// traefikFileHeader 写入 YAML/TOML 文件开头的说明
+const traefikFileHeader = "# 由 AutoCert 维护的 Traefik 动态配置，证书目录中的条目会被自动更新\n"
+
+// TraefikConfigurator Traefik 文件提供者配置器
+// 在动态配置文件的 tls.certificates 中登记证书，Traefik 监视文件变化，不需要重载
+type TraefikConfigurator struct {
+	dynamicFile string
+	changes     *changeSet
+	reloadOpts  config.ReloadConfig
+}
+
+// traefikCertificate tls.certificates 中的一项
+type traefikCertificate struct {
+	certFile string
+	keyFile  string
+}
+
+// Configure 配置 Traefik
+func (t *TraefikConfigurator) Configure(config *Config) error {
+	logger.Info("开始配置 Traefik", "domain", config.Domain)
+	t.changes = newChangeSet(false)
+	t.setup(config)
+	return t.configure(config)
+}
+
+// Plan 计算 Configure 将执行的修改
+func (t *TraefikConfigurator) Plan(config *Config) (*Plan, error) {
+	t.changes = newChangeSet(true)
+	t.setup(config)
+	if err := t.configure(config); err != nil {
+		return nil, err
+	}
+
+	if t.reloadOpts.Method != "" {
+		r, err := t.reloadStrategy()
+		if err != nil {
+			return nil, err
+		}
+		t.changes.plan.Commands = append(t.changes.plan.Commands, r.planCommands()...)
+	}
+	return t.changes.plan, nil
+}
+
+// setup 保存配置中的路径和重载方式
+func (t *TraefikConfigurator) setup(config *Config) {
+	t.dynamicFile = config.Traefik.DynamicFile
+	if t.dynamicFile == "" {
+		t.dynamicFile = DefaultTraefikDynamicFile
+	}
+	t.reloadOpts = config.Reload
+}
+
+// configure 在动态配置中添加本证书，并移除证书目录已被删除的条目
+func (t *TraefikConfigurator) configure(config *Config) error {
+	if _, err := t.reloadStrategy(); err != nil {
+		return fmt.Errorf("重载配置无效: %w", err)
+	}
+
+	doc, exists, err := t.loadForEdit()
+	if err != nil {
+		return fmt.Errorf("读取 Traefik 动态配置失败: %w", err)
+	}
+
+	entry := traefikCertificate{certFile: config.FullchainPath, keyFile: config.KeyPath}
+	certs, removed := syncTraefikCertificates(traefikCertificates(doc), entry, managedCertDir())
+	for _, r := range removed {
+		logger.Info("移除已删除证书的 Traefik 条目", "certFile", r)
+	}
+	setTraefikCertificates(doc, certs)
+
+	data, err := t.encode(doc)
+	if err != nil {
+		return fmt.Errorf("生成 Traefik 动态配置失败: %w", err)
+	}
+
+	if exists {
+		if old, err := os.ReadFile(t.dynamicFile); err == nil && bytes.Equal(old, data) {
+			logger.Info("Traefik 动态配置中已包含证书", "file", t.dynamicFile)
+			return nil
+		}
+		backupPath, err := t.changes.updateFile(t.dynamicFile, data)
+		if err != nil {
+			return err
+		}
+		logger.Info("更新 Traefik 动态配置", "file", t.dynamicFile, "backup", backupPath)
+	} else {
+		if err := t.changes.mkdirAll(filepath.Dir(t.dynamicFile)); err != nil {
+			return err
+		}
+		if err := t.changes.writeFile(t.dynamicFile, data, 0644); err != nil {
+			return err
+		}
+		logger.Info("创建 Traefik 动态配置", "file", t.dynamicFile)
+	}
+
+	logger.Info("Traefik 配置完成", "domain", config.Domain)
+	return nil
+}
+
+// syncTraefikCertificates 添加或保留本证书的条目，删除证书文件已不存在的 AutoCert 条目
+// 证书目录之外的条目由用户维护，保持不变
+func syncTraefikCertificates(certs []map[string]interface{}, entry traefikCertificate, certDir string) ([]map[string]interface{}, []string) {
+	var result []map[string]interface{}
+	var removed []string
+	found := false
+
+	for _, c := range certs {
+		certFile, _ := c["certFile"].(string)
+		switch {
+		case certFile == entry.certFile:
+			c["keyFile"] = entry.keyFile
+			found = true
+		case isUnderDir(certFile, certDir):
+			if _, err := os.Stat(certFile); errors.Is(err, os.ErrNotExist) {
+				removed = append(removed, certFile)
+				continue
+			}
+		}
+		result = append(result, c)
+	}
+
+	if !found {
+		result = append(result, map[string]interface{}{
+			"certFile": entry.certFile,
+			"keyFile":  entry.keyFile,
+		})
+	}
+	return result, removed
+}
+
+// managedCertDir AutoCert 的证书目录，其中的证书条目由 AutoCert 维护
+func managedCertDir() string {
+	return config.GetCertDir()
+}
+
+// isUnderDir path 是否位于 dir 目录中
+func isUnderDir(path, dir string) bool {
+	if path == "" || dir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// traefikCertificates 读取 tls.certificates
+func traefikCertificates(doc map[string]interface{}) []map[string]interface{} {
+	tlsSection, _ := doc["tls"].(map[string]interface{})
+	list, _ := tlsSection["certificates"].([]interface{})
+
+	var certs []map[string]interface{}
+	for _, item := range list {
+		if c, ok := item.(map[string]interface{}); ok {
+			certs = append(certs, c)
+		}
+	}
+	return certs
+}
+
+// setTraefikCertificates 写回 tls.certificates，保留 tls 中的其他配置（options、stores 等）
+func setTraefikCertificates(doc map[string]interface{}, certs []map[string]interface{}) {
+	tlsSection, ok := doc["tls"].(map[string]interface{})
+	if !ok {
+		tlsSection = make(map[string]interface{})
+		doc["tls"] = tlsSection
+	}
+
+	list := make([]interface{}, 0, len(certs))
+	for _, c := range certs {
+		list = append(list, c)
+	}
+	tlsSection["certificates"] = list
+}
+
+// isTOML 动态配置文件是否为 TOML 格式
+func (t *TraefikConfigurator) isTOML() bool {
+	return strings.EqualFold(filepath.Ext(t.dynamicFile), ".toml")
+}
+
+// load 读取动态配置文件，文件不存在时返回空配置
+func (t *TraefikConfigurator) load() (map[string]interface{}, bool, error) {
+	doc := make(map[string]interface{})
+
+	data, err := os.ReadFile(t.dynamicFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return doc, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if t.isTOML() {
+		err = toml.Unmarshal(data, &doc)
+	} else {
+		err = yaml.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("解析 %s 失败: %w", t.dynamicFile, err)
+	}
+	if doc == nil {
+		doc = make(map[string]interface{})
+	}
+	return doc, true, nil
+}
+
+// loadForEdit 读取将被改写的动态配置
+// 改写时重新编码整个文件，注释、键的顺序和格式都会丢失，所以用户维护的文件只能包含 tls.certificates，
+// 其他配置需要放在文件提供者目录中的其他文件里
+func (t *TraefikConfigurator) loadForEdit() (map[string]interface{}, bool, error) {
+	doc, exists, err := t.load()
+	if err != nil || !exists {
+		return doc, exists, err
+	}
+
+	data, err := os.ReadFile(t.dynamicFile)
+	if err != nil {
+		return nil, true, err
+	}
+	if bytes.HasPrefix(data, []byte(traefikFileHeader)) {
+		return doc, true, nil
+	}
+
+	if keys := traefikForeignKeys(doc); len(keys) > 0 {
+		return nil, true, fmt.Errorf("%s 不是 AutoCert 创建的文件，且包含 tls.certificates 之外的配置（%s），改写会丢失这些内容的格式和注释，"+
+			"请将 webserver.traefik.dynamic_file 设置为文件提供者目录中单独的文件", t.dynamicFile, strings.Join(keys, ", "))
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			return nil, true, fmt.Errorf("%s 不是 AutoCert 创建的文件，且包含注释，改写会丢失注释，"+
+				"请将 webserver.traefik.dynamic_file 设置为文件提供者目录中单独的文件", t.dynamicFile)
+		}
+	}
+	return doc, true, nil
+}
+
+// traefikForeignKeys 动态配置中 tls.certificates 之外的键
+func traefikForeignKeys(doc map[string]interface{}) []string {
+	var keys []string
+	for key, value := range doc {
+		if key != "tls" {
+			keys = append(keys, key)
+			continue
+		}
+		tlsSection, ok := value.(map[string]interface{})
+		if !ok {
+			keys = append(keys, key)
+			continue
+		}
+		for tlsKey := range tlsSection {
+			if tlsKey != "certificates" {
+				keys = append(keys, "tls."+tlsKey)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// encode 按文件格式编码动态配置
+func (t *TraefikConfigurator) encode(doc map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(traefikFileHeader)
+
+	if t.isTOML() {
+		data, err := toml.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+		return buf.Bytes(), nil
+	}
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Test 检查动态配置文件可以解析
+// Traefik 没有离线检查配置的命令
+func (t *TraefikConfigurator) Test() error {
+	if t.dynamicFile == "" {
+		t.dynamicFile = DefaultTraefikDynamicFile
+	}
+	if _, _, err := t.load(); err != nil {
+		return fmt.Errorf("Traefik 动态配置无效: %w", err)
+	}
+	logger.Info("Traefik 配置测试成功")
+	return nil
+}
+
+// Reload Traefik 监视动态配置文件，只有显式配置了重载方式时才执行
+func (t *TraefikConfigurator) Reload() error {
+	if t.reloadOpts.Method == "" {
+		logger.Info("Traefik 会自动加载动态配置的修改，无需重载")
+		return nil
+	}
+
+	r, err := t.reloadStrategy()
+	if err != nil {
+		return err
+	}
+	if err := r.runReload(); err != nil {
+		return fmt.Errorf("重载 Traefik 失败: %w", err)
+	}
+	logger.Info("Traefik 重载成功")
+	return nil
+}
+
+// reloadStrategy 根据配置的重载方式创建重载器
+func (t *TraefikConfigurator) reloadStrategy() (*reloader, error) {
+	return newReloader(t.reloadOpts, "traefik", nil, nil)
+}
+
+// Rollback 撤销最近一次 Configure 对文件的修改
+func (t *TraefikConfigurator) Rollback() ([]string, error) {
+	if t.changes == nil {
+		return nil, nil
+	}
+	return t.changes.rollback()
+}
+
+// GetConfigPath 获取动态配置文件路径
+func (t *TraefikConfigurator) GetConfigPath() string {
+	return t.dynamicFile
+}
+
+// IsSSLEnabled 检查动态配置中是否有覆盖该域名的证书
+func (t *TraefikConfigurator) IsSSLEnabled(domain string) bool {
+	if t.dynamicFile == "" {
+		t.dynamicFile = DefaultTraefikDynamicFile
+	}
+	doc, _, err := t.load()
+	if err != nil {
+		return false
+	}
+
+	for _, c := range traefikCertificates(doc) {
+		if certFile, ok := c["certFile"].(string); ok && pemCoversDomain(certFile, domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package webserver
+
+import (
+	"autocert/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTraefikDynamicFileOwnership(t *testing.T) {
+	const entry = "tls:\n  certificates:\n    - certFile: /etc/ssl/other/fullchain.pem\n      keyFile: /etc/ssl/other/key.pem\n"
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string // 为空时期望可以修改
+	}{
+		{name: "new file", file: "autocert.yml"},
+		{name: "only tls certificates", file: "certs.yml", content: entry},
+		{name: "created by autocert", file: "autocert.yml", content: traefikFileHeader + entry + "http:\n  routers: {}\n"},
+		{name: "user file with routers", file: "dynamic.yml", content: entry + "http:\n  routers: {}\n", wantErr: "http"},
+		{name: "user file with tls options", file: "dynamic.yml", content: entry + "  options:\n    default:\n      minVersion: VersionTLS12\n", wantErr: "tls.options"},
+		{name: "user file with comments", file: "dynamic.yml", content: "# 手写的证书列表\n" + entry, wantErr: "注释"},
+		{name: "user toml file", file: "dynamic.toml", content: "[http.routers]\n[[tls.certificates]]\ncertFile = \"/a.pem\"\nkeyFile = \"/b.pem\"\n", wantErr: "http"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c := &TraefikConfigurator{}
+			plan, err := c.Plan(&Config{
+				Domain:        "example.com",
+				FullchainPath: "/etc/autocert/certs/example.com/fullchain.pem",
+				KeyPath:       "/etc/autocert/certs/example.com/key.pem",
+				Traefik:       config.TraefikConfig{DynamicFile: path},
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Plan: %v", err)
+			}
+			if len(plan.Files) != 1 || !strings.HasPrefix(plan.Files[0].New, traefikFileHeader) ||
+				!strings.Contains(plan.Files[0].New, "/etc/autocert/certs/example.com/fullchain.pem") {
+				t.Fatalf("unexpected plan: %+v", plan.Files)
+			}
+			if tt.content != "" && !strings.Contains(plan.Files[0].New, "/etc/ssl/other/fullchain.pem") {
+				t.Errorf("existing entry dropped:\n%s", plan.Files[0].New)
+			}
+		})
+	}
+}